@@ -0,0 +1,216 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Aggregate is a function computed over the rows matching a Filter, eg. Max("Price"). Use them with
+// Filter.Aggregate.
+type Aggregate struct {
+	fn     string
+	column string
+	alias  string
+}
+
+// Min is the smallest value of the column
+func Min(column string) Aggregate {
+	return Aggregate{fn: "min", column: column}
+}
+
+// Max is the largest value of the column
+func Max(column string) Aggregate {
+	return Aggregate{fn: "max", column: column}
+}
+
+// Sum is the sum of the values of the column
+func Sum(column string) Aggregate {
+	return Aggregate{fn: "sum", column: column}
+}
+
+// Avg is the average of the values of the column. Like in Cassandra, the average of an integer column is an integer.
+func Avg(column string) Aggregate {
+	return Aggregate{fn: "avg", column: column}
+}
+
+// As names the result of the aggregate, which is otherwise named after the function and the column, eg. max_price
+func (a Aggregate) As(alias string) Aggregate {
+	a.alias = alias
+	return a
+}
+
+// Name is the name of the column the result of the aggregate is read as
+func (a Aggregate) Name() string {
+	if a.alias != "" {
+		return strings.ToLower(a.alias)
+	}
+	return a.fn + "_" + strings.ToLower(a.column)
+}
+
+func (a Aggregate) cql() string {
+	return fmt.Sprintf("%s(%s) AS %s", a.fn, strings.ToLower(a.column), a.Name())
+}
+
+// projection replaces the columns selected by a read, for counts, distinct partition keys and aggregates
+type projection struct {
+	distinct bool
+	columns  []string // CQL expressions
+	names    []string // names of the resulting columns
+}
+
+func (p *projection) cql() string {
+	if p.distinct {
+		return "DISTINCT " + strings.Join(p.columns, ", ")
+	}
+	return strings.Join(p.columns, ", ")
+}
+
+// withGroupBy returns the projection selecting the columns rows are grouped by too, so that aggregates can be told
+// apart
+func (p *projection) withGroupBy(groupBy []string) *projection {
+	if len(groupBy) == 0 || p.distinct {
+		return p
+	}
+	ret := &projection{}
+	for _, c := range groupBy {
+		ret.columns = append(ret.columns, strings.ToLower(c))
+		ret.names = append(ret.names, strings.ToLower(c))
+	}
+	ret.columns = append(ret.columns, p.columns...)
+	ret.names = append(ret.names, p.names...)
+	return ret
+}
+
+func countProjection() *projection {
+	return &projection{columns: []string{"COUNT(*)"}, names: []string{"count"}}
+}
+
+func aggregateProjection(aggregates []Aggregate) *projection {
+	p := &projection{}
+	for _, a := range aggregates {
+		p.columns = append(p.columns, a.cql())
+		p.names = append(p.names, a.Name())
+	}
+	return p
+}
+
+// distinctColumns returns the partition keys followed by the given static columns
+func distinctColumns(keys Keys, columns []string) []string {
+	ret := append([]string{}, keys.PartitionKeys...)
+	for _, c := range columns {
+		if !isKey(c, keys.PartitionKeys) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func distinctProjection(keys Keys, columns []string) *projection {
+	p := &projection{distinct: true}
+	for _, c := range distinctColumns(keys, columns) {
+		p.columns = append(p.columns, strings.ToLower(c))
+		p.names = append(p.names, strings.ToLower(c))
+	}
+	return p
+}
+
+// decodeProjected decodes a row of counts or aggregates into result, which can be a scalar if there is only one
+func decodeProjected(row map[string]interface{}, names []string, result interface{}) error {
+	out := reflect.ValueOf(result)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return fmt.Errorf("can not decode into %T, a pointer is required", result)
+	}
+	out = out.Elem()
+	if len(names) == 1 && !isRowStruct(out.Type()) && out.Kind() != reflect.Map {
+		if err := decodeValue(columnValue(row, names[0]), out); err != nil {
+			return fmt.Errorf("column %s: %w", names[0], err)
+		}
+		return nil
+	}
+	return decodeResult(row, result)
+}
+
+func isSlicePointer(pointer interface{}) bool {
+	t := reflect.TypeOf(pointer)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice
+}
+
+// columnValue returns the value of a column of a row, ignoring the case of the column name
+func columnValue(row map[string]interface{}, column string) interface{} {
+	if v, ok := row[column]; ok {
+		return v
+	}
+	for k, v := range row {
+		if strings.EqualFold(k, column) {
+			return v
+		}
+	}
+	return nil
+}
+
+// compute computes the aggregate over rows, the way Cassandra does: nulls are ignored, the minimum and maximum of
+// no values are null and their sum and average are 0
+func (a Aggregate) compute(rows []map[string]interface{}) (interface{}, error) {
+	var (
+		result      interface{}
+		count       int64
+		isum        int64
+		fsum        float64
+		isFloat     bool
+		unsupported = func(v interface{}) error {
+			return ValidationError{Field: a.column, Reason: fmt.Sprintf("can not compute %s of %T", a.fn, v)}
+		}
+	)
+	for _, row := range rows {
+		v := columnValue(row, a.column)
+		if v == nil {
+			continue
+		}
+		count++
+		switch a.fn {
+		case "min", "max":
+			if result == nil {
+				result = v
+				continue
+			}
+			cmp := (&keyPart{Value: v}).Compare(&keyPart{Value: result})
+			if (a.fn == "min" && cmp < 0) || (a.fn == "max" && cmp > 0) {
+				result = v
+			}
+		case "sum", "avg":
+			rv := reflect.ValueOf(v)
+			switch rv.Kind() {
+			case reflect.Float32, reflect.Float64:
+				isFloat = true
+				fsum += rv.Float()
+			default:
+				i, ok := toInt64(rv)
+				if !ok {
+					return nil, unsupported(v)
+				}
+				isum += i
+				fsum += float64(i)
+			}
+		}
+	}
+
+	switch a.fn {
+	case "sum":
+		if isFloat {
+			return fsum, nil
+		}
+		return isum, nil
+	case "avg":
+		switch {
+		case count == 0 && isFloat:
+			return 0.0, nil
+		case count == 0:
+			return int64(0), nil
+		case isFloat:
+			return fsum / float64(count), nil
+		}
+		return isum / count, nil
+	}
+	return result, nil
+}
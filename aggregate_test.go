@@ -0,0 +1,108 @@
+package gocassa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAggregateStatements(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	tbl := ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+		StaticColumns:     []string{"SellerName"},
+	})
+	var (
+		count   int64
+		sellers []sale
+		max     int
+		stats   map[string]interface{}
+	)
+	for _, tc := range []struct {
+		op       Op
+		expected string
+	}{
+		{tbl.Where(Eq("SellerId", "s1")).Count(&count), "SELECT COUNT(*) FROM some_ks.%s  WHERE SellerId = ?"},
+		{tbl.Where().Distinct(&sellers), "SELECT DISTINCT sellerid FROM some_ks.%s"},
+		{tbl.Where(In("SellerId", "s1", "s2")).Distinct(&sellers, "SellerName"), "SELECT DISTINCT sellerid, sellername FROM some_ks.%s  WHERE SellerId IN ?"},
+		{tbl.Where(Eq("SellerId", "s1")).Aggregate(&max, Max("Price")), "SELECT max(price) AS max_price FROM some_ks.%s  WHERE SellerId = ?"},
+		{tbl.Where(Eq("SellerId", "s1")).Aggregate(&stats, Min("Price").As("cheapest"), Avg("Price")), "SELECT min(price) AS cheapest, avg(price) AS avg_price FROM some_ks.%s  WHERE SellerId = ?"},
+	} {
+		stmt, _ := tc.op.GenerateStatement()
+		if expected := strings.Replace(tc.expected, "%s", tbl.Name(), 1); !strings.EqualFold(stmt, expected) {
+			t.Errorf("expected %q, got %q", expected, stmt)
+		}
+		if err := tc.op.Preflight(); err != nil {
+			t.Errorf("%s: unexpected error %v", stmt, err)
+		}
+	}
+
+	if err := tbl.Where(Eq("SellerId", "s1"), Eq("SaleId", "1")).Distinct(&sellers).Preflight(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a ValidationError restricting a clustering column, got %v", err)
+	}
+	if err := tbl.Where().Distinct(&sellers, "Price").Preflight(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a ValidationError selecting a regular column, got %v", err)
+	}
+}
+
+func TestGroupByStatements(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	tbl := ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+	})
+	var (
+		sales  []sale
+		totals []map[string]interface{}
+	)
+	for _, tc := range []struct {
+		op       Op
+		expected string
+	}{
+		{tbl.Where(In("SellerId", "s1", "s2")).Read(&sales).WithOptions(Options{PerPartitionLimit: 2, Limit: 10}),
+			"FROM some_ks.%s  WHERE SellerId IN ? PER PARTITION LIMIT ? LIMIT ?"},
+		{tbl.Where(In("SellerId", "s1", "s2")).Read(&sales).WithOptions(Options{GroupBy: []string{"SellerId", "SaleId"}, ClusteringOrder: []ClusteringOrderColumn{{DESC, "SaleId"}}, PerPartitionLimit: 1}),
+			"FROM some_ks.%s  WHERE SellerId IN ? GROUP BY sellerid, saleid ORDER BY SaleId DESC PER PARTITION LIMIT ?"},
+		{tbl.Where(In("SellerId", "s1", "s2")).Aggregate(&totals, Sum("Price")).WithOptions(Options{GroupBy: []string{"SellerId"}}),
+			"SELECT sellerid, sum(price) AS sum_price FROM some_ks.%s  WHERE SellerId IN ? GROUP BY sellerid"},
+	} {
+		// The order of the columns of reads isn't deterministic
+		stmt, _ := tc.op.GenerateStatement()
+		if i := strings.Index(stmt, "FROM"); !strings.HasPrefix(tc.expected, "SELECT") && i >= 0 {
+			stmt = stmt[i:]
+		}
+		if expected := strings.Replace(tc.expected, "%s", tbl.Name(), 1); !strings.EqualFold(stmt, expected) {
+			t.Errorf("expected %q, got %q", expected, stmt)
+		}
+		if err := tc.op.Preflight(); err != nil {
+			t.Errorf("%s: unexpected error %v", stmt, err)
+		}
+	}
+
+	for _, opts := range []Options{
+		{PerPartitionLimit: -1},
+		{GroupBy: []string{"SaleId"}},
+		{GroupBy: []string{"SellerId", "Price"}},
+		{GroupBy: []string{"SellerId", "SaleId", "Price"}},
+	} {
+		if err := tbl.Where(Eq("SellerId", "s1")).Read(&sales).WithOptions(opts).Preflight(); !errors.Is(err, ErrValidation) {
+			t.Errorf("expected a ValidationError for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestDecodeProjected(t *testing.T) {
+	row := map[string]interface{}{"max_price": 30, "avg_price": 20}
+	var max int64
+	if err := decodeProjected(row, []string{"max_price"}, &max); err != nil || max != 30 {
+		t.Fatalf("expected 30, got %v, %v", max, err)
+	}
+	var stats struct {
+		Max int `cql:"max_price"`
+		Avg int `cql:"avg_price"`
+	}
+	if err := decodeProjected(row, []string{"max_price", "avg_price"}, &stats); err != nil || stats.Max != 30 || stats.Avg != 20 {
+		t.Fatalf("expected both aggregates, got %+v, %v", stats, err)
+	}
+}
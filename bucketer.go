@@ -0,0 +1,86 @@
+package gocassa
+
+import (
+	"strings"
+	"time"
+)
+
+// DayBucketer buckets rows by calendar day in loc, eg. the timezone of a customer. A nil loc is UTC.
+func DayBucketer(loc *time.Location) Bucketer {
+	return newCalendarBucketer("day", loc)
+}
+
+// WeekBucketer buckets rows by ISO week, which starts on Monday, in loc. A nil loc is UTC.
+func WeekBucketer(loc *time.Location) Bucketer {
+	return newCalendarBucketer("week", loc)
+}
+
+// MonthBucketer buckets rows by calendar month in loc. A nil loc is UTC.
+func MonthBucketer(loc *time.Location) Bucketer {
+	return newCalendarBucketer("month", loc)
+}
+
+// calendarBucketer buckets by days, weeks or months, whose length varies with daylight saving time and the calendar.
+// Buckets are the Unix milliseconds of the midnight starting them in loc.
+type calendarBucketer struct {
+	unit string
+	loc  *time.Location
+}
+
+func newCalendarBucketer(unit string, loc *time.Location) *calendarBucketer {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &calendarBucketer{unit: unit, loc: loc}
+}
+
+func (b *calendarBucketer) Bucket(secs int64) int64 {
+	return b.start(time.Unix(secs, 0)).UnixMilli()
+}
+
+func (b *calendarBucketer) Next(bucket int64) int64 {
+	return b.shift(bucket, 1)
+}
+
+func (b *calendarBucketer) Prev(bucket int64) int64 {
+	return b.shift(bucket, -1)
+}
+
+// String names the bucketer in table names, eg. day or week_America_New_York
+func (b *calendarBucketer) String() string {
+	if b.loc == time.UTC {
+		return b.unit
+	}
+	return b.unit + "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, b.loc.String())
+}
+
+// start returns the start of the bucket t is in
+func (b *calendarBucketer) start(t time.Time) time.Time {
+	y, m, d := t.In(b.loc).Date()
+	switch b.unit {
+	case "week":
+		d -= (int(t.In(b.loc).Weekday()) + 6) % 7
+	case "month":
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, b.loc)
+}
+
+// shift returns the bucket n buckets away from bucket
+func (b *calendarBucketer) shift(bucket int64, n int) int64 {
+	y, m, d := time.UnixMilli(bucket).In(b.loc).Date()
+	switch b.unit {
+	case "day":
+		d += n
+	case "week":
+		d += 7 * n
+	case "month":
+		m += time.Month(n)
+	}
+	return b.start(time.Date(y, m, d, 0, 0, 0, 0, b.loc)).UnixMilli()
+}
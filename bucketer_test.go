@@ -0,0 +1,68 @@
+package gocassa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarBuckets(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	at := func(value string, loc *time.Location) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, tc := range []struct {
+		bucketer       Bucketer
+		name           string
+		time           string
+		start          string
+		next, prev     string
+		loc            *time.Location
+		expectedString string
+	}{
+		{DayBucketer(nil), "day", "2021-03-14 18:30", "2021-03-14 00:00", "2021-03-15 00:00", "2021-03-13 00:00", time.UTC, "day"},
+		// Daylight saving time starts on the 14th, which is 23 hours long
+		{DayBucketer(newYork), "day in New York", "2021-03-14 18:30", "2021-03-14 00:00", "2021-03-15 00:00", "2021-03-13 00:00", newYork, "day_America_New_York"},
+		// The 1st of January 2021 was a Friday, in week 53 of 2020
+		{WeekBucketer(nil), "week", "2021-01-01 12:00", "2020-12-28 00:00", "2021-01-04 00:00", "2020-12-21 00:00", time.UTC, "week"},
+		{WeekBucketer(nil), "week from Monday", "2021-01-04 00:00", "2021-01-04 00:00", "2021-01-11 00:00", "2020-12-28 00:00", time.UTC, "week"},
+		{WeekBucketer(nil), "week from Sunday", "2021-01-10 23:59", "2021-01-04 00:00", "2021-01-11 00:00", "2020-12-28 00:00", time.UTC, "week"},
+		{MonthBucketer(newYork), "month", "2021-03-31 23:30", "2021-03-01 00:00", "2021-04-01 00:00", "2021-02-01 00:00", newYork, "month_America_New_York"},
+		{MonthBucketer(nil), "month across years", "2021-01-15 00:00", "2021-01-01 00:00", "2021-02-01 00:00", "2020-12-01 00:00", time.UTC, "month"},
+	} {
+		b := tc.bucketer
+		bucket := b.Bucket(at(tc.time, tc.loc).Unix())
+		if expected := at(tc.start, tc.loc).UnixMilli(); bucket != expected {
+			t.Errorf("%s: expected bucket %v, got %v", tc.name, time.UnixMilli(expected), time.UnixMilli(bucket))
+		}
+		if expected := at(tc.next, tc.loc).UnixMilli(); b.Next(bucket) != expected {
+			t.Errorf("%s: expected next bucket %v, got %v", tc.name, time.UnixMilli(expected), time.UnixMilli(b.Next(bucket)))
+		}
+		if expected := at(tc.prev, tc.loc).UnixMilli(); b.Prev(bucket) != expected {
+			t.Errorf("%s: expected previous bucket %v, got %v", tc.name, time.UnixMilli(expected), time.UnixMilli(b.Prev(bucket)))
+		}
+		if b.Prev(b.Next(bucket)) != bucket {
+			t.Errorf("%s: expected Prev to undo Next", tc.name)
+		}
+		if b.String() != tc.expectedString {
+			t.Errorf("%s: expected the name %s, got %s", tc.name, tc.expectedString, b.String())
+		}
+	}
+}
+
+func TestFixedBuckets(t *testing.T) {
+	b := &tsBucketer{bucketSize: time.Hour}
+	bucket := b.Bucket(parse("2006 Jan 2 15:04:05").Unix())
+	if expected := parse("2006 Jan 2 15:00:00").UnixMilli(); bucket != expected {
+		t.Fatalf("expected %v, got %v", expected, bucket)
+	}
+	if b.Prev(b.Next(bucket)) != bucket || b.Next(bucket)-bucket != time.Hour.Milliseconds() {
+		t.Fatalf("expected hourly buckets, got %v, %v and %v", b.Prev(bucket), bucket, b.Next(bucket))
+	}
+}
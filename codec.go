@@ -0,0 +1,198 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// CQLMarshaler is implemented by types which are stored as a native CQL type, eg. a Money type stored as the
+// bigint number of cents. The column type of the field in CREATE TABLE statements is CQLType, and ToCQL converts
+// the value into one which gocql can marshal into that type.
+type CQLMarshaler interface {
+	CQLType() string
+	ToCQL() (interface{}, error)
+}
+
+// CQLUnmarshaler is implemented by types which decode themselves from the values gocql reads, eg. an int64 for a
+// bigint column or a map[string]interface{} for a user defined type.
+type CQLUnmarshaler interface {
+	FromCQL(value interface{}) error
+}
+
+// Codec declares how the values of a Go type are stored, for types which can't implement CQLMarshaler and
+// CQLUnmarshaler themselves, eg. types from other packages.
+type Codec struct {
+	// CQLType is the type of the column, eg. "bigint" or "blob"
+	CQLType string
+	// Marshal converts a value of the Go type into one which gocql can marshal into CQLType
+	Marshal func(v interface{}) (interface{}, error)
+	// Unmarshal sets the value ptr points to, a pointer to the Go type, from the value gocql read
+	Unmarshal func(value interface{}, ptr interface{}) error
+}
+
+var codecs struct {
+	sync.RWMutex
+	registered map[reflect.Type]Codec
+	cache      map[reflect.Type]*codec
+}
+
+// RegisterCodec registers the codec of the Go type of sample, eg. RegisterCodec(json.RawMessage{}, ...). Codecs are
+// global, and are meant to be registered from init functions before any table using the type is created.
+func RegisterCodec(sample interface{}, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	if codecs.registered == nil {
+		codecs.registered = map[reflect.Type]Codec{}
+	}
+	codecs.registered[reflect.TypeOf(sample)] = c
+	codecs.cache = nil
+}
+
+// codec is how values of a type are converted, from a registered Codec or from the CQLMarshaler and
+// CQLUnmarshaler methods of the type. Either of marshal or unmarshal may be nil.
+type codec struct {
+	cqlType   string
+	marshal   func(v reflect.Value) (interface{}, error)
+	unmarshal func(value interface{}, out reflect.Value) error // out is addressable
+}
+
+var (
+	cqlMarshalerType   = reflect.TypeOf((*CQLMarshaler)(nil)).Elem()
+	cqlUnmarshalerType = reflect.TypeOf((*CQLUnmarshaler)(nil)).Elem()
+)
+
+// codecOf returns the codec of t, or nil if values of t are left to gocql
+func codecOf(t reflect.Type) *codec {
+	codecs.RLock()
+	c, ok := codecs.cache[t]
+	codecs.RUnlock()
+	if ok {
+		return c
+	}
+
+	codecs.Lock()
+	defer codecs.Unlock()
+	c = newCodec(t, codecs.registered)
+	if codecs.cache == nil {
+		codecs.cache = map[reflect.Type]*codec{}
+	}
+	codecs.cache[t] = c
+	return c
+}
+
+func newCodec(t reflect.Type, registered map[reflect.Type]Codec) *codec {
+	if rc, ok := registered[t]; ok {
+		c := &codec{cqlType: rc.CQLType}
+		if rc.Marshal != nil {
+			c.marshal = func(v reflect.Value) (interface{}, error) {
+				return rc.Marshal(v.Interface())
+			}
+		}
+		if rc.Unmarshal != nil {
+			c.unmarshal = func(value interface{}, out reflect.Value) error {
+				return rc.Unmarshal(value, out.Addr().Interface())
+			}
+		}
+		return c
+	}
+
+	ptr := reflect.PtrTo(t)
+	if !ptr.Implements(cqlMarshalerType) && !ptr.Implements(cqlUnmarshalerType) {
+		return nil
+	}
+	c := &codec{}
+	if ptr.Implements(cqlMarshalerType) {
+		c.cqlType = reflect.New(t).Interface().(CQLMarshaler).CQLType()
+		c.marshal = func(v reflect.Value) (interface{}, error) {
+			if !t.Implements(cqlMarshalerType) {
+				// Pointer receiver
+				p := reflect.New(t)
+				p.Elem().Set(v)
+				v = p
+			}
+			return v.Interface().(CQLMarshaler).ToCQL()
+		}
+	}
+	if ptr.Implements(cqlUnmarshalerType) {
+		c.unmarshal = func(value interface{}, out reflect.Value) error {
+			return out.Addr().Interface().(CQLUnmarshaler).FromCQL(value)
+		}
+	}
+	return c
+}
+
+// marshalCodec converts v with the codec of its type, descending into slices and maps. It returns false if neither
+// v nor its elements have a codec.
+func marshalCodec(v interface{}) (interface{}, bool, error) {
+	if v == nil {
+		return nil, false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if c := codecOf(rv.Type()); c != nil && c.marshal != nil {
+		mv, err := c.marshal(rv)
+		if err != nil {
+			return nil, true, fmt.Errorf("can not marshal %T: %w", v, err)
+		}
+		return mv, true, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !hasCodec(rv.Type().Elem()) {
+			return v, false, nil
+		}
+		if rv.IsNil() {
+			return nil, true, nil
+		}
+		return marshalCodec(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 || !hasCodec(rv.Type().Elem()) {
+			return v, false, nil
+		}
+		elems := make([]interface{}, rv.Len())
+		for i := range elems {
+			elem, _, err := marshalCodec(rv.Index(i).Interface())
+			if err != nil {
+				return nil, true, err
+			}
+			elems[i] = elem
+		}
+		return elems, true, nil
+	case reflect.Map:
+		if !hasCodec(rv.Type().Key()) && !hasCodec(rv.Type().Elem()) {
+			return v, false, nil
+		}
+		m := make(map[interface{}]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, _, err := marshalCodec(iter.Key().Interface())
+			if err != nil {
+				return nil, true, err
+			}
+			e, _, err := marshalCodec(iter.Value().Interface())
+			if err != nil {
+				return nil, true, err
+			}
+			m[k] = e
+		}
+		return m, true, nil
+	}
+	return v, false, nil
+}
+
+func hasCodec(t reflect.Type) bool {
+	c := codecOf(t)
+	return c != nil && c.marshal != nil
+}
+
+// marshalFailure carries an error converting a value to gocql, which returns it when running the statement
+type marshalFailure struct {
+	err error
+}
+
+func (f marshalFailure) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
+	return nil, f.err
+}
@@ -0,0 +1,188 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+// codecTestPriority is stored as an int, so that it sorts by urgency rather than by name
+type codecTestPriority string
+
+var codecTestPriorities = []codecTestPriority{"low", "medium", "high"}
+
+func init() {
+	RegisterCodec(codecTestPriority(""), Codec{
+		CQLType: "int",
+		Marshal: func(v interface{}) (interface{}, error) {
+			for i, p := range codecTestPriorities {
+				if p == v.(codecTestPriority) {
+					return i, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown priority %q", v)
+		},
+		Unmarshal: func(value interface{}, ptr interface{}) error {
+			i, ok := value.(int)
+			if !ok || i < 0 || i >= len(codecTestPriorities) {
+				return fmt.Errorf("can not decode %v into a priority", value)
+			}
+			*ptr.(*codecTestPriority) = codecTestPriorities[i]
+			return nil
+		},
+	})
+}
+
+// OpTestMoney is stored as the bigint number of cents
+type OpTestMoney struct {
+	Cents int64
+}
+
+func (m OpTestMoney) CQLType() string {
+	return "bigint"
+}
+
+func (m OpTestMoney) ToCQL() (interface{}, error) {
+	return m.Cents, nil
+}
+
+func (m *OpTestMoney) FromCQL(value interface{}) error {
+	cents, ok := value.(int64)
+	if !ok {
+		return fmt.Errorf("can not decode %T into money", value)
+	}
+	m.Cents = cents
+	return nil
+}
+
+type codecTestTask struct {
+	Queue    string
+	Priority codecTestPriority
+	Name     string
+	Budget   OpTestMoney
+	Refunds  []OpTestMoney
+	Limit    *OpTestMoney
+}
+
+func TestCodecCreateStatement(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	tbl := ks.Table("tasks", codecTestTask{}, Keys{
+		PartitionKeys:     []string{"Queue"},
+		ClusteringColumns: []string{"Priority"},
+	})
+	str, err := tbl.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{
+		"priority int",
+		"budget bigint",
+		"refunds list<bigint>",
+		"limit bigint",
+	} {
+		if !strings.Contains(str, "    "+col+",") {
+			t.Errorf("Expected column %q in %s", col, str)
+		}
+	}
+}
+
+func TestCodecMarshal(t *testing.T) {
+	if v := marshalValue(OpTestMoney{Cents: 5}); v != int64(5) {
+		t.Errorf("Expected money to be marshalled as its cents, got %#v", v)
+	}
+	if v := marshalValue(&OpTestMoney{Cents: 6}); v != int64(6) {
+		t.Errorf("Expected a pointer to money to be marshalled as its cents, got %#v", v)
+	}
+	if v := marshalValue((*OpTestMoney)(nil)); v != nil {
+		t.Errorf("Expected a nil pointer to be marshalled as null, got %#v", v)
+	}
+	if v := marshalValue([]OpTestMoney{{1}, {2}}); !reflect.DeepEqual(v, []interface{}{int64(1), int64(2)}) {
+		t.Errorf("Expected the elements of lists to be marshalled, got %#v", v)
+	}
+	if v := marshalValue(codecTestPriority("high")); v != 2 {
+		t.Errorf("Expected the registered codec to be used, got %#v", v)
+	}
+
+	v := marshalValue(codecTestPriority("urgent"))
+	m, ok := v.(gocql.Marshaler)
+	if !ok {
+		t.Fatalf("Expected a failed conversion to be left for gocql to report, got %#v", v)
+	}
+	if _, err := m.MarshalCQL(nil); err == nil || !strings.Contains(err.Error(), "unknown priority") {
+		t.Errorf("Expected the conversion error, got %v", err)
+	}
+}
+
+func TestCodecDecode(t *testing.T) {
+	var result codecTestTask
+	row := map[string]interface{}{
+		"queue":    "q",
+		"priority": 1,
+		"budget":   int64(100),
+		"refunds":  []int64{1, 2},
+		"limit":    int64(3),
+	}
+	if err := decodeResult(row, &result); err != nil {
+		t.Fatal(err)
+	}
+	expected := codecTestTask{
+		Queue:    "q",
+		Priority: "medium",
+		Budget:   OpTestMoney{100},
+		Refunds:  []OpTestMoney{{1}, {2}},
+		Limit:    &OpTestMoney{3},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, result)
+	}
+
+	row["priority"] = 7
+	if err := decodeResult(row, &result); err == nil || !strings.Contains(err.Error(), "column priority") {
+		t.Fatalf("Expected the codec error, got %v", err)
+	}
+	row["priority"], row["budget"] = 1, "2.50"
+	if err := decodeResult(row, &result); err == nil || !strings.Contains(err.Error(), "into money") {
+		t.Fatalf("Expected the error of FromCQL, got %v", err)
+	}
+}
+
+func TestCodecMockKeyOrdering(t *testing.T) {
+	tbl := NewMockKeySpace().Table("tasks", codecTestTask{}, Keys{
+		PartitionKeys:     []string{"Queue"},
+		ClusteringColumns: []string{"Priority"},
+	})
+	for _, p := range []codecTestPriority{"high", "low", "medium"} {
+		if err := tbl.Set(codecTestTask{Queue: "q", Priority: p, Name: string(p)}).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tasks []codecTestTask
+	if err := tbl.Where(Eq("Queue", "q")).Read(&tasks).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"low", "medium", "high"}) {
+		t.Fatalf("Expected the tasks in the order of their stored priority, got %v", names)
+	}
+
+	tasks = nil
+	if err := tbl.Where(Eq("Queue", "q"), GT("Priority", codecTestPriority("low"))).Read(&tasks).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Priority != "medium" {
+		t.Fatalf("Expected ranges to compare stored values, got %+v", tasks)
+	}
+
+	err := tbl.Set(codecTestTask{Queue: "q", Priority: "urgent"}).Run()
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected an error writing a value the codec rejects, got %v", err)
+	}
+}
@@ -66,6 +66,7 @@ func (c *connection) KeySpace(name string) KeySpace {
 		qe:    c.q,
 		name:  name,
 		types: map[string]string{},
+		udts:  map[string]Type{},
 	}
 	k.tableFactory = k
 	k.typeFactory = k
@@ -0,0 +1,173 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+type counterT struct {
+	Table
+	keyFields     []string
+	counterFields []string
+}
+
+// keyRelations returns the relations selecting the row of key, the value of the key field or a map of the values
+// of the key fields
+func (c *counterT) keyRelations(key interface{}) ([]Relation, error) {
+	m, ok := key.(map[string]interface{})
+	if !ok {
+		if len(c.keyFields) != 1 {
+			return nil, ValidationError{Table: c.Name(), Reason: fmt.Sprintf("the key of a table with %d key fields has to be a map", len(c.keyFields))}
+		}
+		m = map[string]interface{}{c.keyFields[0]: key}
+	}
+	var relations []Relation
+	for _, f := range c.keyFields {
+		v, ok := m[f]
+		if !ok {
+			return nil, ValidationError{Table: c.Name(), Field: f, Reason: "missing key field"}
+		}
+		relations = append(relations, Eq(f, v))
+	}
+	return relations, nil
+}
+
+func (c *counterT) isCounter(field string) bool {
+	for _, f := range c.counterFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *counterT) Incr(key interface{}, counter string, delta int) Op {
+	return c.IncrMany(key, map[string]int{counter: delta})
+}
+
+func (c *counterT) Decr(key interface{}, counter string, delta int) Op {
+	return c.IncrMany(key, map[string]int{counter: -delta})
+}
+
+func (c *counterT) IncrMany(key interface{}, deltas map[string]int) Op {
+	relations, err := c.keyRelations(key)
+	if err != nil {
+		return &badOp{err}
+	}
+	m := map[string]interface{}{}
+	for counter, delta := range deltas {
+		if !c.isCounter(counter) {
+			return &badOp{ValidationError{Table: c.Name(), Field: counter, Reason: "not a counter of the table"}}
+		}
+		m[counter] = CounterIncrement(delta)
+	}
+	if len(m) == 0 {
+		return Noop()
+	}
+	return &counterOp{c.Where(relations...).Update(m)}
+}
+
+func (c *counterT) Get(key, pointer interface{}) Op {
+	relations, err := c.keyRelations(key)
+	if err != nil {
+		return &badOp{err}
+	}
+	return &counterGetOp{
+		Op:      c.Where(relations...).ReadOne(pointer),
+		key:     relations,
+		pointer: pointer,
+	}
+}
+
+func (c *counterT) GetMany(keys []interface{}, pointerToASlice interface{}) Op {
+	if len(keys) == 0 {
+		return Noop()
+	}
+	relations, err := c.keyRelations(keys[0])
+	if err != nil {
+		return &badOp{err}
+	}
+	last := c.keyFields[len(c.keyFields)-1]
+	values := []interface{}{}
+	for _, key := range keys {
+		rs, err := c.keyRelations(key)
+		if err != nil {
+			return &badOp{err}
+		}
+		for i, r := range rs[:len(rs)-1] {
+			if !reflect.DeepEqual(r.terms, relations[i].terms) {
+				return &badOp{ValidationError{Table: c.Name(), Field: r.key, Reason: fmt.Sprintf("the keys read together can only differ by %s", last)}}
+			}
+		}
+		values = append(values, rs[len(rs)-1].terms[0])
+	}
+	relations[len(relations)-1] = In(last, values...)
+	return c.Where(relations...).Read(pointerToASlice)
+}
+
+func (c *counterT) WithOptions(o Options) CounterTable {
+	return &counterT{
+		Table:         c.Table.WithOptions(o),
+		keyFields:     c.keyFields,
+		counterFields: c.counterFields,
+	}
+}
+
+// counterOp is a counter update. Counter updates can only be batched with other counter updates, in a counter batch.
+type counterOp struct {
+	Op
+}
+
+func (o *counterOp) counterUpdate() {}
+
+func (o *counterOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *counterOp) WithOptions(opts Options) Op {
+	return &counterOp{o.Op.WithOptions(opts)}
+}
+
+// RunAtomically runs the update on its own, as a single statement is atomic and can't be part of a logged batch
+func (o *counterOp) RunAtomically() error {
+	return o.Op.Run()
+}
+
+// counterGetOp reads the counters of a row, which are all 0 if the row has never been incremented
+type counterGetOp struct {
+	Op
+	key     []Relation
+	pointer interface{}
+}
+
+func (o *counterGetOp) Run() error {
+	err := o.Op.Run()
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	out := reflect.ValueOf(o.pointer)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return fmt.Errorf("can not decode into %T, a pointer is required", o.pointer)
+	}
+	out.Elem().Set(reflect.Zero(out.Elem().Type()))
+	key := map[string]interface{}{}
+	for _, r := range o.key {
+		key[r.key] = r.terms[0]
+	}
+	return decodeResult(key, o.pointer)
+}
+
+func (o *counterGetOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *counterGetOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *counterGetOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.Op = o.Op.WithOptions(opts)
+	return &ret
+}
@@ -0,0 +1,148 @@
+package gocassa
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type pageViews struct {
+	Page     string
+	Day      string
+	Views    Counter
+	Visitors Counter
+}
+
+func TestCounterTable(t *testing.T) {
+	tbl := NewMockKeySpace().CounterTable("views", []string{"Page", "Day"}, pageViews{})
+	key := map[string]interface{}{"Page": "home", "Day": "2021-01-01"}
+
+	var views pageViews
+	if err := tbl.Get(key, &views).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (pageViews{Page: "home", Day: "2021-01-01"}); views != expected {
+		t.Fatalf("expected the counters of a new row to be 0, got %+v", views)
+	}
+
+	ops := []Op{
+		tbl.Incr(key, "Views", 3),
+		tbl.Decr(key, "Views", 1),
+		tbl.IncrMany(key, map[string]int{"Views": 2, "Visitors": 1}),
+	}
+	for _, op := range ops {
+		if err := op.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tbl.Incr(key, "Visitors", 1).Add(tbl.Incr(key, "Visitors", 1)).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Get(key, &views).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (pageViews{Page: "home", Day: "2021-01-01", Views: 4, Visitors: 3}); views != expected {
+		t.Fatalf("expected %+v, got %+v", expected, views)
+	}
+
+	other := map[string]interface{}{"Page": "home", "Day": "2021-01-02"}
+	if err := tbl.Incr(other, "Views", 1).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var all []pageViews
+	missing := map[string]interface{}{"Page": "home", "Day": "2021-01-03"}
+	if err := tbl.GetMany([]interface{}{key, other, missing}, &all).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Views+all[1].Views != 5 {
+		t.Fatalf("expected the counters of the rows incremented, got %+v", all)
+	}
+	err := tbl.GetMany([]interface{}{key, map[string]interface{}{"Page": "about", "Day": "2021-01-01"}}, &all).Run()
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError reading keys which differ by their first key field, got %v", err)
+	}
+
+	if err := tbl.Incr(key, "Page", 1).Run(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError incrementing a key field, got %v", err)
+	}
+	if err := tbl.Incr("home", "Views", 1).Run(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a key which isn't a map, got %v", err)
+	}
+	if err := tbl.Incr(key, "Views", 1).Add(NewMockKeySpace().MapTable("pages", "Page", pageViews{}).Set(views)).RunAtomically(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError batching counter updates with other statements, got %v", err)
+	}
+}
+
+func TestCounterTableE(t *testing.T) {
+	ks := NewMockKeySpace()
+	if _, err := ks.CounterTableE("views", []string{"Page"}, pageViews{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a regular column, got %v", err)
+	}
+	tbl, err := ks.CounterTableE("views", []string{"Page"}, pageViews{}, "Views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Incr("home", "Views", 1).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var views pageViews
+	if err := tbl.Get("home", &views).Run(); err != nil || views.Views != 1 {
+		t.Fatalf("expected 1 view, got %+v, %v", views, err)
+	}
+	if _, err := ks.CounterTableE("views", []string{"Page", "Day"}, pageViews{}, "Day"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a key field counter, got %v", err)
+	}
+	if _, err := ks.CounterTableE("views", nil, pageViews{}, "Views"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError without key fields, got %v", err)
+	}
+}
+
+// counterBatchRecordingQE records the counter batches it runs
+type counterBatchRecordingQE struct {
+	StatementRecordingQE
+	batches *[][]string
+}
+
+func (qe counterBatchRecordingQE) ExecuteCounterBatch(stmts []string, params [][]interface{}) error {
+	*qe.batches = append(*qe.batches, stmts)
+	return nil
+}
+
+func TestCounterTableStatements(t *testing.T) {
+	qe := counterBatchRecordingQE{StatementRecordingQE: newStatementRecordingQE(), batches: &[][]string{}}
+	tbl := (&connection{q: qe}).KeySpace("some_ks").CounterTable("views", []string{"Page"}, pageViews{}, "Views", "Visitors")
+
+	stmt, err := tbl.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stmt, "views counter,") || !strings.Contains(stmt, "visitors counter,") || strings.Contains(stmt, "day") {
+		t.Fatalf("expected only the counter columns besides the keys, got %s", stmt)
+	}
+
+	stmt, values := tbl.IncrMany("home", map[string]int{"Views": 1}).GenerateStatement()
+	if expected := "UPDATE some_ks.views_counter_Page SET Views = Views + ? WHERE page = ?"; stmt != expected {
+		t.Fatalf("expected %q, got %q", expected, stmt)
+	}
+	if !reflect.DeepEqual(values, []interface{}{1, "home"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	stmt, values = tbl.Decr("home", "Views", 2).GenerateStatement()
+	if expected := "UPDATE some_ks.views_counter_Page SET Views = Views - ? WHERE page = ?"; stmt != expected || values[0] != 2 {
+		t.Fatalf("expected %q with 2, got %q with %v", expected, stmt, values)
+	}
+
+	if err := tbl.Incr("home", "Views", 1).Add(tbl.Incr("about", "Views", 1)).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*qe.batches) != 1 || len((*qe.batches)[0]) != 2 {
+		t.Fatalf("expected a counter batch of 2 statements, got %v", *qe.batches)
+	}
+	if err := tbl.Incr("home", "Views", 1).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*qe.stmts) != 1 || len(*qe.batches) != 1 {
+		t.Fatalf("expected a single update to run on its own, got %v and %v", *qe.stmts, *qe.batches)
+	}
+}
@@ -0,0 +1,457 @@
+package gocassa
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+
+	rreflect "github.com/gocassa/gocassa/reflect"
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+var (
+	rowType    = reflect.TypeOf(map[string]interface{}{})
+	bigIntType = reflect.TypeOf(big.Int{})
+	infDecType = reflect.TypeOf(inf.Dec{})
+)
+
+// decodeDirect decodes rows straight into structs, or slices of structs, setting each field by its index instead of
+// going through mapstructure. Conversions are strict: a value which does not fit its field is an error. It returns
+// false if it can not decode into result, which is then left to decodeWithMapstructure.
+func decodeDirect(rows, result interface{}) (bool, error) {
+	out := reflect.ValueOf(result)
+	in := reflect.ValueOf(rows)
+	if out.Kind() != reflect.Ptr || out.IsNil() || !in.IsValid() {
+		return false, nil
+	}
+	out = out.Elem()
+	if in.Type().AssignableTo(out.Type()) {
+		out.Set(in)
+		return true, nil
+	}
+
+	switch {
+	case isRowStruct(out.Type()) && in.Type() == rowType:
+		return true, decodeRow(rows.(map[string]interface{}), out)
+	case out.Kind() == reflect.Slice && isRowStruct(elemType(out.Type().Elem())):
+		maps, ok := rows.([]map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		slice := reflect.MakeSlice(out.Type(), len(maps), len(maps))
+		for i, m := range maps {
+			elem := slice.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				elem.Set(reflect.New(elem.Type().Elem()))
+				elem = elem.Elem()
+			}
+			if err := decodeRow(m, elem); err != nil {
+				return true, err
+			}
+		}
+		out.Set(slice)
+		return true, nil
+	}
+	return false, nil
+}
+
+// rowDecoder decodes the rows of a query as a rowScanner scans them, without a map per row
+type rowDecoder interface {
+	// setColumns is called with the columns of the result before the first row is scanned
+	setColumns(columns []gocql.ColumnInfo)
+	// dest returns the values to scan the next row into, one per column and per element of tuple columns
+	dest() []interface{}
+	// row decodes the row scanned into dest, and tells whether to scan more rows
+	row() (bool, error)
+}
+
+// structDecoder decodes scanned rows into a struct, or a slice of structs. Columns are scanned straight into the
+// fields which have the type gocql reads them as, and the others into a value of that type which decodeValue then
+// sets the field from, as it does from maps.
+type structDecoder struct {
+	out     reflect.Value
+	one     bool         // whether out is a struct rather than a slice of structs
+	t       reflect.Type // the type of the struct
+	columns []scannedColumn
+	dests   []interface{}
+	cur     reflect.Value // the struct the row is scanned into
+	rows    reflect.Value
+	n       int
+	err     error // the error decoding a row, as opposed to reading it
+}
+
+type scannedColumn struct {
+	name string
+	// index is the index of the field of the column, nil if it has none
+	index []int
+	// value is where the column is scanned into if its field has another type, and elems where the elements of a
+	// tuple column are
+	value reflect.Value
+	elems []reflect.Value
+}
+
+// newStructDecoder returns the decoder of rows into result, which is a pointer to a struct if one is true, or to a
+// slice of structs. It returns nil if rows can not be decoded into result field by field.
+func newStructDecoder(result interface{}, one bool) *structDecoder {
+	out := reflect.ValueOf(result)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return nil
+	}
+	out = out.Elem()
+	d := &structDecoder{out: out, one: one}
+	switch {
+	case one && isRowStruct(out.Type()):
+		d.t = out.Type()
+	case !one && out.Kind() == reflect.Slice && isRowStruct(elemType(out.Type().Elem())):
+		d.t = elemType(out.Type().Elem())
+		d.rows = reflect.MakeSlice(out.Type(), 0, 0)
+	default:
+		return nil
+	}
+	return d
+}
+
+func (d *structDecoder) setColumns(columns []gocql.ColumnInfo) {
+	idx := rreflect.FieldIndexes(d.t)
+	d.columns = make([]scannedColumn, len(columns))
+	n := 0
+	for i, col := range columns {
+		c := scannedColumn{name: col.Name, index: idx[strings.ToLower(col.Name)]}
+		if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+			// Tuples are scanned element by element, even without a field, for the destinations to line up
+			for _, elem := range tuple.Elems {
+				c.elems = append(c.elems, reflect.ValueOf(elem.New()).Elem())
+			}
+			n += len(c.elems)
+		} else {
+			if c.index != nil {
+				if value := reflect.ValueOf(col.TypeInfo.New()).Elem(); d.t.FieldByIndex(c.index).Type != value.Type() {
+					c.value = value
+				}
+			}
+			n++
+		}
+		d.columns[i] = c
+	}
+	d.dests = make([]interface{}, n)
+	d.cur = reflect.New(d.t).Elem()
+}
+
+func (d *structDecoder) dest() []interface{} {
+	if !d.one && d.out.Type().Elem().Kind() == reflect.Ptr {
+		d.cur = reflect.New(d.t).Elem()
+	} else {
+		d.cur.Set(reflect.Zero(d.t))
+	}
+	i := 0
+	for _, c := range d.columns {
+		switch {
+		case c.elems != nil:
+			for _, elem := range c.elems {
+				elem.Set(reflect.Zero(elem.Type()))
+				d.dests[i] = elem.Addr().Interface()
+				i++
+			}
+			continue
+		case c.index == nil:
+			d.dests[i] = nil
+		case c.value.IsValid():
+			c.value.Set(reflect.Zero(c.value.Type()))
+			d.dests[i] = c.value.Addr().Interface()
+		default:
+			d.dests[i] = rreflect.FieldByIndex(d.cur, c.index).Addr().Interface()
+		}
+		i++
+	}
+	return d.dests
+}
+
+func (d *structDecoder) row() (bool, error) {
+	for _, c := range d.columns {
+		var value interface{}
+		switch {
+		case c.index == nil:
+			continue
+		case c.elems != nil:
+			elems := make([]interface{}, len(c.elems))
+			for i, elem := range c.elems {
+				elems[i] = elem.Interface()
+			}
+			value = elems
+		case c.value.IsValid():
+			value = c.value.Interface()
+		default:
+			continue
+		}
+		if err := decodeValue(value, rreflect.FieldByIndex(d.cur, c.index)); err != nil {
+			d.err = fmt.Errorf("column %s: %w", c.name, err)
+			return false, d.err
+		}
+	}
+	d.n++
+	if d.one {
+		// Only the fields of the columns read are set, as decodeRow does
+		for _, c := range d.columns {
+			if c.index != nil {
+				rreflect.FieldByIndex(d.out, c.index).Set(rreflect.FieldByIndex(d.cur, c.index))
+			}
+		}
+		return false, nil
+	}
+	if d.out.Type().Elem().Kind() == reflect.Ptr {
+		d.rows = reflect.Append(d.rows, d.cur.Addr())
+	} else {
+		d.rows = reflect.Append(d.rows, d.cur)
+	}
+	return true, nil
+}
+
+// finish sets a slice result to the rows decoded
+func (d *structDecoder) finish() {
+	if !d.one {
+		d.out.Set(d.rows)
+	}
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// isRowStruct tells if t is a struct which rows can be decoded into field by field
+func isRowStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !isTupleStruct(t) && codecOf(t) == nil
+}
+
+// decodeRow sets the fields of the struct out from the columns of a row, ignoring columns without a field
+func decodeRow(m map[string]interface{}, out reflect.Value) error {
+	idx := rreflect.FieldIndexes(out.Type())
+	for column, value := range m {
+		index, ok := idx[strings.ToLower(column)]
+		if !ok {
+			continue
+		}
+		field := rreflect.FieldByIndex(out, index)
+		if !field.IsValid() {
+			continue
+		}
+		if err := decodeValue(value, field); err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// decodeValue sets out, which has to be settable, from a value read by gocql
+func decodeValue(value interface{}, out reflect.Value) error {
+	if value == nil {
+		out.Set(reflect.Zero(out.Type()))
+		return nil
+	}
+	in := reflect.ValueOf(value)
+	t := out.Type()
+	if in.Type().AssignableTo(t) {
+		out.Set(in)
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		if in.Kind() == reflect.Ptr && in.IsNil() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		elem := reflect.New(t.Elem())
+		if err := decodeValue(value, elem.Elem()); err != nil {
+			return err
+		}
+		out.Set(elem)
+		return nil
+	}
+	if c := codecOf(t); c != nil && c.unmarshal != nil {
+		if !out.CanAddr() {
+			elem := reflect.New(t).Elem()
+			if err := c.unmarshal(value, elem); err != nil {
+				return err
+			}
+			out.Set(elem)
+			return nil
+		}
+		return c.unmarshal(value, out)
+	}
+
+	cantDecode := func() error {
+		return fmt.Errorf("can not decode %T into %v", value, t)
+	}
+	switch t {
+	case bigFloatType:
+		f, ok := new(big.Float), false
+		switch v := value.(type) {
+		case *inf.Dec:
+			_, ok = f.SetString(v.String())
+		case float32, float64:
+			f.SetFloat64(in.Float())
+			ok = true
+		}
+		if !ok {
+			return cantDecode()
+		}
+		out.Set(reflect.ValueOf(f).Elem())
+		return nil
+	case bigIntType:
+		i, ok := value.(*big.Int)
+		if !ok {
+			return cantDecode()
+		}
+		out.Set(reflect.ValueOf(i).Elem())
+		return nil
+	case infDecType:
+		d, ok := value.(*inf.Dec)
+		if !ok {
+			return cantDecode()
+		}
+		out.Set(reflect.ValueOf(d).Elem())
+		return nil
+	case netIPType:
+		s, ok := value.(string)
+		if !ok {
+			return cantDecode()
+		}
+		if s == "" {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("can not decode %q into %v", s, t)
+		}
+		out.Set(reflect.ValueOf(ip))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(in)
+		if !ok || out.OverflowInt(i) {
+			return cantDecode()
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := toUint64(in)
+		if !ok || out.OverflowUint(u) {
+			return cantDecode()
+		}
+		out.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if in.Kind() != reflect.Float32 && in.Kind() != reflect.Float64 {
+			return cantDecode()
+		}
+		out.SetFloat(in.Float())
+	case reflect.String:
+		if in.Kind() != reflect.String {
+			return cantDecode()
+		}
+		out.SetString(in.String())
+	case reflect.Bool:
+		if in.Kind() != reflect.Bool {
+			return cantDecode()
+		}
+		out.SetBool(in.Bool())
+	case reflect.Slice:
+		if in.Kind() != reflect.Slice && in.Kind() != reflect.Array {
+			return cantDecode()
+		}
+		if in.Kind() == reflect.Slice && in.IsNil() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		slice := reflect.MakeSlice(t, in.Len(), in.Len())
+		for i := 0; i < in.Len(); i++ {
+			if err := decodeValue(in.Index(i).Interface(), slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.Set(slice)
+	case reflect.Array:
+		if (in.Kind() != reflect.Slice && in.Kind() != reflect.Array) || in.Len() != t.Len() {
+			return cantDecode()
+		}
+		for i := 0; i < in.Len(); i++ {
+			if err := decodeValue(in.Index(i).Interface(), out.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if in.Kind() != reflect.Map {
+			return cantDecode()
+		}
+		if in.IsNil() {
+			out.Set(reflect.Zero(t))
+			return nil
+		}
+		m := reflect.MakeMapWithSize(t, in.Len())
+		iter := in.MapRange()
+		for iter.Next() {
+			k, v := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			if err := decodeValue(iter.Key().Interface(), k); err != nil {
+				return err
+			}
+			if err := decodeValue(iter.Value().Interface(), v); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		out.Set(m)
+	case reflect.Struct:
+		switch {
+		case isTupleStruct(t) && (in.Kind() == reflect.Slice || in.Kind() == reflect.Array):
+			if in.Len() != t.NumField() {
+				return fmt.Errorf("can not decode tuple of %d elements into %v with %d fields", in.Len(), t, t.NumField())
+			}
+			for i := 0; i < t.NumField(); i++ {
+				if err := decodeValue(in.Index(i).Interface(), out.Field(i)); err != nil {
+					return err
+				}
+			}
+		case in.Type() == rowType:
+			// User defined types are read as maps of their fields
+			return decodeRow(value.(map[string]interface{}), out)
+		default:
+			return cantDecode()
+		}
+	default:
+		return cantDecode()
+	}
+	return nil
+}
+
+func toInt64(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		return int64(u), u <= 1<<63-1
+	}
+	if i, ok := v.Interface().(*big.Int); ok && i != nil && i.IsInt64() {
+		return i.Int64(), true
+	}
+	return 0, false
+}
+
+func toUint64(v reflect.Value) (uint64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		return uint64(i), i >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	}
+	if i, ok := v.Interface().(*big.Int); ok && i != nil && i.IsUint64() {
+		return i.Uint64(), true
+	}
+	return 0, false
+}
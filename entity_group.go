@@ -0,0 +1,358 @@
+package gocassa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// EntityGroup keeps the denormalised views of one row type in sync: a MapTable holding the rows by id, and any
+// number of MultimapTables, MultimapMkTables, TimeSeriesTables and MultiTimeSeriesTables indexing them by other
+// fields. Writes read the row from the MapTable first, so that rows whose indexed fields change are moved between
+// the partitions of the views instead of leaving stale entries behind.
+//
+// The writes to the views of the Ops of an EntityGroup run concurrently with Run, and in a single logged batch with
+// RunAtomically.
+type EntityGroup interface {
+	// Set writes the row to every view, and removes it from the views where it was stored under other keys
+	Set(v interface{}) Op
+	// Update updates the row in every view, moving it to its new keys in the views whose keys it changes. Updating
+	// a row which does not exist fails with ErrNotFound.
+	Update(id interface{}, m map[string]interface{}) Op
+	// Delete deletes the row from every view
+	Delete(id interface{}) Op
+	// Read reads the row from the MapTable
+	Read(id, pointer interface{}) Op
+	WithOptions(Options) EntityGroup
+}
+
+// NewEntityGroup creates the EntityGroup of the rows of primary, which are also written to the given views. The
+// views have to be recipe tables of the same row type.
+func NewEntityGroup(primary MapTable, views ...TableChanger) (EntityGroup, error) {
+	m, ok := primary.(*mapT)
+	if !ok {
+		return nil, ValidationError{Table: primary.Name(), Reason: fmt.Sprintf("%T is not a MapTable created by a KeySpace", primary)}
+	}
+	g := &entityGroup{primary: m, rowType: entityType(m.Table)}
+	for _, tbl := range append([]TableChanger{primary}, views...) {
+		v, err := newGroupView(tbl)
+		if err != nil {
+			return nil, err
+		}
+		g.views = append(g.views, v)
+	}
+	return g, nil
+}
+
+// groupView is a recipe table of an entity group, and the fields identifying a row in it
+type groupView struct {
+	set      func(v interface{}) Op
+	where    func(relations ...Relation) Filter
+	fields   []string
+	bucket   func(row map[string]interface{}) int64 // for time series
+	shard    func(row map[string]interface{}) int   // for sharded multimaps
+	withOpts func(Options) TableChanger
+}
+
+func newGroupView(tbl TableChanger) (*groupView, error) {
+	switch v := tbl.(type) {
+	case *mapT:
+		return &groupView{set: v.Set, where: v.Where, fields: []string{v.idField},
+			withOpts: func(o Options) TableChanger { return v.WithOptions(o) }}, nil
+	case *multimapT:
+		view := &groupView{set: v.Set, where: v.Where, fields: []string{v.fieldToIndexBy, v.idField},
+			withOpts: func(o Options) TableChanger { return v.WithOptions(o) }}
+		if v.shards > 0 {
+			view.shard = func(row map[string]interface{}) int {
+				// The ids of the rows of the group are of the type of the id column, which always serialise
+				shard, _ := v.shard(row[v.idField])
+				return shard
+			}
+		}
+		return view, nil
+	case *multimapMkT:
+		return &groupView{set: v.Set, where: v.Where, fields: append(append([]string{}, v.fieldsToIndexBy...), v.idField...),
+			withOpts: func(o Options) TableChanger { return v.WithOptions(o) }}, nil
+	case *timeSeriesT:
+		return &groupView{set: v.Set, where: v.Where, fields: []string{v.timeField, v.idField},
+			bucket:   timeBucket(v.timeField, v.bucketer),
+			withOpts: func(o Options) TableChanger { return v.WithOptions(o) }}, nil
+	case *multiTimeSeriesT:
+		return &groupView{set: v.Set, where: v.Where, fields: append(append([]string{}, v.indexFields...), v.timeField, v.idField),
+			bucket:   timeBucket(v.timeField, v.bucketer),
+			withOpts: func(o Options) TableChanger { return v.WithOptions(o) }}, nil
+	}
+	return nil, ValidationError{Table: tbl.Name(), Reason: fmt.Sprintf("%T can not be a view of an entity group", tbl)}
+}
+
+func timeBucket(timeField string, bucketer Bucketer) func(row map[string]interface{}) int64 {
+	return func(row map[string]interface{}) int64 {
+		tim, _ := row[timeField].(time.Time)
+		return bucketer.Bucket(tim.Unix())
+	}
+}
+
+// filter returns the filter selecting row in the view
+func (v *groupView) filter(row map[string]interface{}) Filter {
+	var relations []Relation
+	if v.bucket != nil {
+		relations = append(relations, Eq(bucketFieldName, v.bucket(row)))
+	}
+	if v.shard != nil {
+		relations = append(relations, Eq(shardFieldName, v.shard(row)))
+	}
+	for _, f := range v.fields {
+		relations = append(relations, Eq(f, row[f]))
+	}
+	return v.where(relations...)
+}
+
+// moved tells whether the row is stored under other keys in the view once updated
+func (v *groupView) moved(previous, updated map[string]interface{}) bool {
+	for _, f := range v.fields {
+		if !sameKey(previous[f], updated[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKey tells whether two values of a key column are stored as the same key. The values read back from Cassandra
+// differ from the ones written, eg. times are in UTC with a millisecond precision, so they are compared as stored.
+func sameKey(a, b interface{}) (same bool) {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if cmp, ok := compareValues(storedValue(a), storedValue(b)); ok && cmp == 0 {
+		return true
+	}
+	defer func() {
+		if recover() != nil {
+			same = false // values which can't be serialised as keys are not the same key
+		}
+	}()
+	return bytes.Equal(keyBytes(gocql.TypeCustom, a), keyBytes(gocql.TypeCustom, b))
+}
+
+// withoutKeys returns m without the fields identifying the row in the view, which updates can't set
+func (v *groupView) withoutKeys(m map[string]interface{}) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for k, value := range m {
+		if !isKey(k, v.fields) {
+			ret[k] = value
+		}
+	}
+	return ret
+}
+
+type entityGroup struct {
+	primary *mapT
+	rowType reflect.Type
+	views   []*groupView
+	options Options
+}
+
+// entityType returns the type of the rows of a table
+func entityType(tbl Table) reflect.Type {
+	var entity interface{}
+	switch tb := tbl.(type) {
+	case *t:
+		entity = tb.info.marshalSource
+	case *MockTable:
+		entity = tb.entity
+	}
+	rt := reflect.TypeOf(entity)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt
+}
+
+func (g *entityGroup) Set(v interface{}) Op {
+	row, ok := toMap(v)
+	if !ok {
+		return &badOp{unrecognizedRowError(g.primary.Name(), v)}
+	}
+	var sets []Op
+	for _, view := range g.views {
+		sets = append(sets, view.set(v))
+	}
+	op := g.newOp(row[g.primary.idField], func(previous map[string]interface{}) ([]Op, error) {
+		var ops []Op
+		for i, view := range g.views {
+			if previous != nil && view.moved(previous, row) {
+				ops = append(ops, view.filter(previous).Delete())
+			}
+			ops = append(ops, sets[i])
+		}
+		return ops, nil
+	})
+	op.checks = sets
+	return op
+}
+
+func (g *entityGroup) Update(id interface{}, m map[string]interface{}) Op {
+	return g.newOp(id, func(previous map[string]interface{}) ([]Op, error) {
+		if previous == nil {
+			return nil, newRowNotFoundError(g.primary.Name(), fmt.Sprint(id))
+		}
+		updated := map[string]interface{}{}
+		for k, v := range previous {
+			updated[k] = v
+		}
+		for k, v := range withoutUnset(m) {
+			updated[k] = v
+		}
+		var ops []Op
+		for _, view := range g.views {
+			switch {
+			case view.moved(previous, updated):
+				ops = append(ops, view.filter(previous).Delete(), view.set(updated))
+			case len(view.withoutKeys(m)) > 0:
+				ops = append(ops, view.filter(previous).Update(view.withoutKeys(m)))
+			}
+		}
+		return ops, nil
+	})
+}
+
+func (g *entityGroup) Delete(id interface{}) Op {
+	return g.newOp(id, func(previous map[string]interface{}) ([]Op, error) {
+		if previous == nil {
+			return nil, nil
+		}
+		var ops []Op
+		for _, view := range g.views {
+			ops = append(ops, view.filter(previous).Delete())
+		}
+		return ops, nil
+	})
+}
+
+func (g *entityGroup) Read(id, pointer interface{}) Op {
+	return g.primary.Read(id, pointer).WithOptions(g.options)
+}
+
+func (g *entityGroup) WithOptions(o Options) EntityGroup {
+	ret := &entityGroup{primary: g.primary.WithOptions(o).(*mapT), rowType: g.rowType, options: g.options.Merge(o)}
+	for _, v := range g.views {
+		view, _ := newGroupView(v.withOpts(o))
+		ret.views = append(ret.views, view)
+	}
+	return ret
+}
+
+func (g *entityGroup) newOp(id interface{}, writes func(previous map[string]interface{}) ([]Op, error)) *groupOp {
+	return &groupOp{group: g, id: id, writes: writes}
+}
+
+// groupOp reads a row from the MapTable of a group, and then runs the writes to the views which depend on it
+type groupOp struct {
+	group   *entityGroup
+	id      interface{}
+	writes  func(previous map[string]interface{}) ([]Op, error)
+	checks  []Op // run by Preflight, for the writes known beforehand
+	options Options
+}
+
+func (o *groupOp) read() (map[string]interface{}, error) {
+	previous := reflect.New(o.group.rowType)
+	err := o.group.primary.Read(o.id, previous.Interface()).WithOptions(o.group.options.Merge(o.options)).Run()
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	m, _ := toMap(previous.Elem().Interface())
+	return m, nil
+}
+
+func (o *groupOp) ops() ([]Op, error) {
+	previous, err := o.read()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := o.writes(previous)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		ops[i] = op.WithOptions(o.options)
+	}
+	return ops, nil
+}
+
+// Run runs the writes to the views concurrently
+func (o *groupOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	ops, err := o.ops()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(ops))
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(1)
+		go func(i int, op Op) {
+			defer wg.Done()
+			errs[i] = op.Run()
+		}(i, op)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAtomically runs the writes to the views in a logged batch
+func (o *groupOp) RunAtomically() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	ops, err := o.ops()
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	return multiOp(ops).RunAtomically()
+}
+
+func (o *groupOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *groupOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.options = o.options.Merge(opts)
+	return &ret
+}
+
+func (o *groupOp) Preflight() error {
+	if o.group.rowType == nil {
+		return ValidationError{Table: o.group.primary.Name(), Reason: "the row type of the table is unknown"}
+	}
+	for _, op := range o.checks {
+		if err := op.Preflight(); err != nil {
+			return err
+		}
+	}
+	return o.group.primary.Read(o.id, reflect.New(o.group.rowType).Interface()).Preflight()
+}
+
+// GenerateStatement returns the statement reading the row, as the writes depend on it
+func (o *groupOp) GenerateStatement() (string, []interface{}) {
+	return o.group.primary.Read(o.id, nil).GenerateStatement()
+}
+
+func (o *groupOp) QueryExecutor() QueryExecutor {
+	return o.group.primary.Read(o.id, nil).QueryExecutor()
+}
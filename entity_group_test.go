@@ -0,0 +1,221 @@
+package gocassa
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type groupOrder struct {
+	Id         string
+	SellerId   string
+	CustomerId string
+	Created    time.Time
+	Price      int
+}
+
+type groupOrderTables struct {
+	byId       MapTable
+	bySeller   MultimapTable
+	byCustomer MultiTimeSeriesTable
+	group      EntityGroup
+}
+
+func newGroupOrderTables(t *testing.T, ks KeySpace) groupOrderTables {
+	tables := groupOrderTables{
+		byId:       ks.MapTable("orders", "Id", groupOrder{}),
+		bySeller:   ks.MultimapTable("orders", "SellerId", "Id", groupOrder{}),
+		byCustomer: ks.MultiTimeSeriesTable("orders", "CustomerId", "Created", "Id", 24*time.Hour, groupOrder{}),
+	}
+	group, err := NewEntityGroup(tables.byId, tables.bySeller, tables.byCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables.group = group
+	return tables
+}
+
+func (tables groupOrderTables) bySellerId(t *testing.T, sellerId string) []groupOrder {
+	var orders []groupOrder
+	if err := tables.bySeller.List(sellerId, nil, 0, &orders).Run(); err != nil {
+		t.Fatal(err)
+	}
+	return orders
+}
+
+func TestEntityGroup(t *testing.T) {
+	tables := newGroupOrderTables(t, NewMockKeySpace())
+	created := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	order := groupOrder{Id: "o1", SellerId: "s1", CustomerId: "c1", Created: created, Price: 10}
+	if err := tables.group.Set(order).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var read groupOrder
+	if err := tables.group.Read("o1", &read).Run(); err != nil || read != order {
+		t.Fatalf("expected %+v, got %+v, %v", order, read, err)
+	}
+	if orders := tables.bySellerId(t, "s1"); !reflect.DeepEqual(orders, []groupOrder{order}) {
+		t.Fatalf("expected the order by seller, got %+v", orders)
+	}
+
+	// Updates of indexed fields move the row
+	if err := tables.group.Update("o1", map[string]interface{}{"SellerId": "s2", "Price": 20}).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	order.SellerId, order.Price = "s2", 20
+	if orders := tables.bySellerId(t, "s1"); len(orders) != 0 {
+		t.Fatalf("expected the order to be moved away from its old seller, got %+v", orders)
+	}
+	if orders := tables.bySellerId(t, "s2"); !reflect.DeepEqual(orders, []groupOrder{order}) {
+		t.Fatalf("expected the order to be moved to its new seller, got %+v", orders)
+	}
+	if err := tables.byCustomer.Read("c1", created, "o1", &read).Run(); err != nil || read != order {
+		t.Fatalf("expected the order by customer to be updated, got %+v, %v", read, err)
+	}
+
+	// So do sets
+	order.SellerId = "s3"
+	if err := tables.group.Set(order).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if orders := tables.bySellerId(t, "s2"); len(orders) != 0 {
+		t.Fatalf("expected the order to be moved away from its old seller, got %+v", orders)
+	}
+
+	if err := tables.group.Delete("o1").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if orders := tables.bySellerId(t, "s3"); len(orders) != 0 {
+		t.Fatalf("expected the order to be deleted by seller, got %+v", orders)
+	}
+	if err := tables.byCustomer.Read("c1", created, "o1", &read).Run(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the order to be deleted by customer, got %v", err)
+	}
+
+	if err := tables.group.Update("o1", map[string]interface{}{"Price": 30}).Run(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound updating a missing row, got %v", err)
+	}
+	if err := tables.group.Delete("o1").Run(); err != nil {
+		t.Fatalf("expected deleting a missing row to do nothing, got %v", err)
+	}
+}
+
+// batchRecordingQE records the statements of batches apart from the others, which may be run concurrently
+type batchRecordingQE struct {
+	StatementRecordingQE
+	batches *[][]string
+	mtx     *sync.Mutex
+}
+
+func (qe batchRecordingQE) ExecuteWithOptions(opts Options, stmt string, params ...interface{}) error {
+	qe.mtx.Lock()
+	defer qe.mtx.Unlock()
+	return qe.StatementRecordingQE.ExecuteWithOptions(opts, stmt, params...)
+}
+
+func (qe batchRecordingQE) Execute(stmt string, params ...interface{}) error {
+	return qe.ExecuteWithOptions(Options{}, stmt, params...)
+}
+
+func (qe batchRecordingQE) ExecuteAtomically(stmts []string, params [][]interface{}) error {
+	*qe.batches = append(*qe.batches, stmts)
+	return nil
+}
+
+// storedRowQE is a batchRecordingQE whose reads return a row as Cassandra stores it
+type storedRowQE struct {
+	batchRecordingQE
+	row map[string]interface{}
+}
+
+func (qe storedRowQE) QueryWithOptions(opts Options, stmt string, params ...interface{}) ([]map[string]interface{}, error) {
+	return []map[string]interface{}{qe.row}, nil
+}
+
+func TestEntityGroupStoredTimeKeys(t *testing.T) {
+	// The time read back is in UTC with a millisecond precision, unlike the one written
+	created := time.Now().In(time.FixedZone("CET", 3600))
+	qe := storedRowQE{
+		batchRecordingQE: batchRecordingQE{StatementRecordingQE: newStatementRecordingQE(), batches: &[][]string{}, mtx: &sync.Mutex{}},
+		row: map[string]interface{}{
+			"id": "o1", "sellerid": "s1", "customerid": "c1", "created": created.UTC().Truncate(time.Millisecond), "price": 10,
+		},
+	}
+	tables := newGroupOrderTables(t, (&connection{q: qe}).KeySpace("some_ks"))
+	order := groupOrder{Id: "o1", SellerId: "s1", CustomerId: "c1", Created: created, Price: 20}
+
+	if err := tables.group.Set(order).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tables.group.Update("o1", map[string]interface{}{"Created": created, "Price": 30}).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	for _, batch := range *qe.batches {
+		for _, stmt := range batch {
+			if strings.HasPrefix(stmt, "DELETE") {
+				t.Fatalf("expected a row whose keys are unchanged not to be deleted, got %v", batch)
+			}
+		}
+	}
+	if len(*qe.batches) != 2 {
+		t.Fatalf("expected 2 batches, got %v", *qe.batches)
+	}
+}
+
+func TestEntityGroupShardedMultimap(t *testing.T) {
+	ks := NewMockKeySpace()
+	byId := ks.MapTable("orders", "Id", groupOrder{})
+	bySeller := ks.ShardedMultimapTable("orders", "SellerId", "Id", 4, groupOrder{})
+	group, err := NewEntityGroup(byId, bySeller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := groupOrderTables{byId: byId, bySeller: bySeller, group: group}
+
+	order := groupOrder{Id: "o1", SellerId: "s1", Price: 10}
+	if err := group.Set(order).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := group.Update("o1", map[string]interface{}{"SellerId": "s2", "Price": 20}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	order.SellerId, order.Price = "s2", 20
+	if orders := tables.bySellerId(t, "s1"); len(orders) != 0 {
+		t.Fatalf("expected the order to be moved away from its old seller, got %+v", orders)
+	}
+	if orders := tables.bySellerId(t, "s2"); !reflect.DeepEqual(orders, []groupOrder{order}) {
+		t.Fatalf("expected the order to be moved to its new seller, got %+v", orders)
+	}
+	if err := group.Delete("o1").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if orders := tables.bySellerId(t, "s2"); len(orders) != 0 {
+		t.Fatalf("expected the order to be deleted, got %+v", orders)
+	}
+}
+
+func TestEntityGroupExecution(t *testing.T) {
+	qe := batchRecordingQE{StatementRecordingQE: newStatementRecordingQE(), batches: &[][]string{}, mtx: &sync.Mutex{}}
+	tables := newGroupOrderTables(t, (&connection{q: qe}).KeySpace("some_ks"))
+	order := groupOrder{Id: "o1", SellerId: "s1", CustomerId: "c1", Created: time.Now()}
+
+	if err := tables.group.Set(order).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*qe.stmts) != 3 || len(*qe.batches) != 0 {
+		t.Fatalf("expected 3 statements run on their own, got %v and %v", *qe.stmts, *qe.batches)
+	}
+	if err := tables.group.Set(order).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*qe.stmts) != 3 || len(*qe.batches) != 1 || len((*qe.batches)[0]) != 3 {
+		t.Fatalf("expected a batch of 3 statements, got %v", *qe.batches)
+	}
+
+	if _, err := NewEntityGroup(tables.byId, (&connection{q: qe}).KeySpace("some_ks").Table("orders", groupOrder{}, Keys{PartitionKeys: []string{"Id"}})); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a table which isn't a recipe, got %v", err)
+	}
+}
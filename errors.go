@@ -1,14 +1,50 @@
 package gocassa
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
 	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// Errors returned by gocassa can be matched against these with errors.Is, eg. errors.Is(err, ErrNotFound). Use
+// errors.As with the error types below to get the details.
+var (
+	ErrNotFound        = errors.New("gocassa: not found")
+	ErrNotApplied      = errors.New("gocassa: not applied")
+	ErrValidation      = errors.New("gocassa: validation failed")
+	ErrUnsupportedType = errors.New("gocassa: unsupported type")
+	ErrTimeout         = errors.New("gocassa: timeout")
+	ErrUnavailable     = errors.New("gocassa: unavailable")
+	ErrBatchTooLarge   = errors.New("gocassa: batch too large")
 )
 
 // RowNotFoundError is returned by Reads if the Row is not found.
 type RowNotFoundError struct {
-	file string
-	line int
+	Table     string
+	Statement string
+	file      string
+	line      int
+}
+
+// newRowNotFoundError records the location of the code which ran the read, skipping the frames in gocassa itself
+func newRowNotFoundError(table, stmt string) RowNotFoundError {
+	err := RowNotFoundError{Table: table, Statement: stmt}
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/gocassa/gocassa.") || strings.HasSuffix(frame.File, "_test.go") {
+			err.file, err.line = frame.File, frame.Line
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return err
 }
 
 func (r RowNotFoundError) Error() string {
@@ -17,5 +53,253 @@ func (r RowNotFoundError) Error() string {
 	if len(ss) > 0 {
 		f = ss[len(ss)-1]
 	}
+	if r.Table != "" {
+		return fmt.Sprintf("%v:%v: No rows returned from %v", f, r.line, r.Table)
+	}
 	return fmt.Sprintf("%v:%v: No rows returned", f, r.line)
 }
+
+func (r RowNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// NotAppliedError is returned when the condition of a lightweight transaction does not hold.
+type NotAppliedError struct {
+	Table     string
+	Statement string
+	// Current holds the values of the existing row, if Cassandra returned them
+	Current map[string]interface{}
+}
+
+func (e NotAppliedError) Error() string {
+	return fmt.Sprintf("%v: not applied", e.Table)
+}
+
+func (e NotAppliedError) Is(target error) bool {
+	return target == ErrNotApplied
+}
+
+// ValidationError is returned when a row, a value or a table definition can't be used, before anything is sent to
+// Cassandra.
+type ValidationError struct {
+	// Table is the name of the table or type, if known
+	Table string
+	// Field is the field at fault, if any
+	Field  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	msg := e.Reason
+	if e.Field != "" {
+		msg = fmt.Sprintf("field %s: %s", e.Field, msg)
+	}
+	if e.Table != "" {
+		msg = fmt.Sprintf("%s: %s", e.Table, msg)
+	}
+	return msg
+}
+
+func (e ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func unrecognizedRowError(table string, row interface{}) error {
+	return ValidationError{Table: table, Reason: fmt.Sprintf("unrecognized row type %T", row)}
+}
+
+// UnsupportedTypeError is returned when a Go type has no CQL equivalent.
+type UnsupportedTypeError struct {
+	Table string
+	Field string
+	// Type is the Go type
+	Type string
+	// Part tells which part of Type is unsupported, eg. "map key", if not all of it
+	Part string
+}
+
+func (e UnsupportedTypeError) Error() string {
+	msg := "Unsupported type " + e.Type
+	if e.Part != "" {
+		msg = fmt.Sprintf("Unsupported %v type %v", e.Part, e.Type)
+	}
+	if e.Field != "" {
+		msg = fmt.Sprintf("field %s: %s", e.Field, msg)
+	}
+	if e.Table != "" {
+		msg = fmt.Sprintf("%s: %s", e.Table, msg)
+	}
+	return msg
+}
+
+func (e UnsupportedTypeError) Is(target error) bool {
+	return target == ErrUnsupportedType
+}
+
+// withColumn fills in the table and field of an UnsupportedTypeError
+func withColumn(err error, table, field string) error {
+	if e, ok := err.(UnsupportedTypeError); ok {
+		e.Table, e.Field = table, field
+		return e
+	}
+	return err
+}
+
+// TimeoutError is returned when Cassandra, or the connection to it, timed out.
+type TimeoutError struct {
+	Table     string
+	Statement string
+	// Consistency, Received and BlockFor are only known for read and write timeouts reported by Cassandra
+	Consistency gocql.Consistency
+	Received    int
+	BlockFor    int
+	// WriteType is set for write timeouts, eg. "SIMPLE" or "BATCH"
+	WriteType string
+	Err       error
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("%v: %v (consistency %v, %d of %d replicas responded): %v", e.Table, e.Err, e.Consistency, e.Received, e.BlockFor, e.Statement)
+}
+
+func (e TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// UnavailableError is returned when not enough replicas are alive to meet the requested consistency.
+type UnavailableError struct {
+	Table       string
+	Statement   string
+	Consistency gocql.Consistency
+	Required    int
+	Alive       int
+	Err         error
+}
+
+func (e UnavailableError) Error() string {
+	return fmt.Sprintf("%v: %v (consistency %v, %d of %d replicas alive): %v", e.Table, e.Err, e.Consistency, e.Alive, e.Required, e.Statement)
+}
+
+func (e UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// BatchTooLargeError is returned when a batch has too many statements, or is too large for Cassandra to accept.
+type BatchTooLargeError struct {
+	Statements []string
+	Err        error
+}
+
+func (e BatchTooLargeError) Error() string {
+	return fmt.Sprintf("batch of %d statements: %v", len(e.Statements), e.Err)
+}
+
+func (e BatchTooLargeError) Is(target error) bool {
+	return target == ErrBatchTooLarge
+}
+
+func (e BatchTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// QueryError wraps any other error returned when running a statement.
+type QueryError struct {
+	Table     string
+	Statement string
+	Err       error
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Table, e.Err, e.Statement)
+}
+
+func (e QueryError) Unwrap() error {
+	return e.Err
+}
+
+// MissingEventError is returned by EventStream.Read when an event below the version of its aggregate is missing, as
+// happens when an Append takes the versions of its events and fails to write them. It matches ErrNotFound.
+type MissingEventError struct {
+	Table       string
+	AggregateId string
+	Version     int64
+}
+
+func (e MissingEventError) Error() string {
+	return fmt.Sprintf("%v: the event of version %d of %v is missing", e.Table, e.Version, e.AggregateId)
+}
+
+func (e MissingEventError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ReleaseError is returned by UniqueIndex.ClaimAnd when its op failed and releasing the claim made for it failed too,
+// leaving the value claimed. It unwraps to the error of the op, and matches the error of the release with errors.Is.
+type ReleaseError struct {
+	Err        error
+	ReleaseErr error
+}
+
+func (e ReleaseError) Error() string {
+	return fmt.Sprintf("%v (releasing the claim failed too: %v)", e.Err, e.ReleaseErr)
+}
+
+func (e ReleaseError) Is(target error) bool {
+	return errors.Is(e.ReleaseErr, target)
+}
+
+func (e ReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// wrapQueryError gives an error returned by the QueryExecutor its type in the taxonomy above, along with the table
+// and statement it happened on
+func wrapQueryError(table, stmt string, err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case RowNotFoundError, NotAppliedError, ValidationError, UnsupportedTypeError, TimeoutError, UnavailableError,
+		BatchTooLargeError, QueryError:
+		return err
+	case *gocql.RequestErrReadTimeout:
+		return TimeoutError{Table: table, Statement: stmt, Consistency: e.Consistency, Received: e.Received, BlockFor: e.BlockFor, Err: err}
+	case *gocql.RequestErrWriteTimeout:
+		return TimeoutError{Table: table, Statement: stmt, Consistency: e.Consistency, Received: e.Received, BlockFor: e.BlockFor, WriteType: e.WriteType, Err: err}
+	case *gocql.RequestErrUnavailable:
+		return UnavailableError{Table: table, Statement: stmt, Consistency: e.Consistency, Required: e.Required, Alive: e.Alive, Err: err}
+	}
+	if err == gocql.ErrTimeoutNoResponse {
+		return TimeoutError{Table: table, Statement: stmt, Err: err}
+	}
+	if isBatchTooLarge(err) {
+		return BatchTooLargeError{Statements: []string{stmt}, Err: err}
+	}
+	return QueryError{Table: table, Statement: stmt, Err: err}
+}
+
+// wrapBatchError is wrapQueryError for batches, which can span several tables
+func wrapBatchError(stmts []string, err error) error {
+	if isBatchTooLarge(err) {
+		return BatchTooLargeError{Statements: stmts, Err: err}
+	}
+	return wrapQueryError("", strings.Join(stmts, "; "), err)
+}
+
+func isBatchTooLarge(err error) bool {
+	if err == gocql.ErrTooManyStmts {
+		return true
+	}
+	// Cassandra rejects batches over batch_size_fail_threshold_in_kb as invalid requests
+	if rerr, ok := err.(gocql.RequestError); ok {
+		return strings.Contains(rerr.Message(), "Batch too large")
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package gocassa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+func TestWrapQueryError(t *testing.T) {
+	readTimeout := &gocql.RequestErrReadTimeout{Consistency: gocql.Quorum, Received: 1, BlockFor: 2}
+	unavailable := &gocql.RequestErrUnavailable{Consistency: gocql.All, Required: 3, Alive: 2}
+	other := errors.New("boom")
+
+	for _, tc := range []struct {
+		err      error
+		sentinel error
+	}{
+		{readTimeout, ErrTimeout},
+		{&gocql.RequestErrWriteTimeout{WriteType: "SIMPLE"}, ErrTimeout},
+		{gocql.ErrTimeoutNoResponse, ErrTimeout},
+		{unavailable, ErrUnavailable},
+		{gocql.ErrTooManyStmts, ErrBatchTooLarge},
+	} {
+		err := wrapQueryError("tbl", "SELECT", tc.err)
+		if !errors.Is(err, tc.sentinel) {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.sentinel, err)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%v: expected the gocql error to be wrapped", tc.err)
+		}
+	}
+
+	var terr TimeoutError
+	if !errors.As(wrapQueryError("tbl", "SELECT", readTimeout), &terr) {
+		t.Fatal("expected a TimeoutError")
+	}
+	if terr.Table != "tbl" || terr.Statement != "SELECT" || terr.Consistency != gocql.Quorum || terr.BlockFor != 2 {
+		t.Errorf("unexpected details in %+v", terr)
+	}
+
+	var uerr UnavailableError
+	if !errors.As(wrapQueryError("tbl", "SELECT", unavailable), &uerr) || uerr.Alive != 2 || uerr.Required != 3 {
+		t.Errorf("unexpected details in %+v", uerr)
+	}
+
+	var qerr QueryError
+	err := wrapQueryError("tbl", "SELECT", other)
+	if !errors.As(err, &qerr) || !errors.Is(err, other) || qerr.Table != "tbl" {
+		t.Errorf("expected a QueryError wrapping %v, got %v", other, err)
+	}
+
+	if wrapQueryError("tbl", "SELECT", nil) != nil {
+		t.Error("expected no error")
+	}
+}
+
+func TestErrorsOnBothBackends(t *testing.T) {
+	for name, ks := range map[string]KeySpace{
+		"mock":  NewMockKeySpace(),
+		"gocql": (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks"),
+	} {
+		tbl := ks.MapTable("users", "Pk1", user{})
+		err := tbl.Read(1, &user{}).Run()
+		var nf RowNotFoundError
+		if !errors.Is(err, ErrNotFound) || !errors.As(err, &nf) {
+			t.Errorf("%s: expected a RowNotFoundError, got %v", name, err)
+		} else if nf.Table != tbl.Name() || !strings.Contains(nf.Error(), "errors_test.go") {
+			t.Errorf("%s: expected the table and the caller in %q", name, nf.Error())
+		}
+
+		if err := tbl.Set(42).Run(); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a ValidationError, got %v", name, err)
+		}
+	}
+
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	_, err := ks.Table("chans", struct {
+		Id string
+		C  chan int
+	}{}, Keys{PartitionKeys: []string{"Id"}}).CreateStatement()
+	var uerr UnsupportedTypeError
+	if !errors.Is(err, ErrUnsupportedType) || !errors.As(err, &uerr) || uerr.Field != "C" {
+		t.Errorf("expected an UnsupportedTypeError on C, got %v", err)
+	}
+}
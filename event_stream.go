@@ -0,0 +1,261 @@
+package gocassa
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Event is an event of an aggregate. Its version is its position in the stream of the aggregate, starting at 1.
+type Event struct {
+	AggregateId string
+	Version     int64
+	Type        string
+	Data        []byte
+	Created     time.Time
+}
+
+// Snapshot is the state of an aggregate once the events up to its version have been applied
+type Snapshot struct {
+	AggregateId string
+	Version     int64
+	Data        []byte
+	Created     time.Time
+}
+
+// eventRow is an event as it is stored, in the bucket of its version
+type eventRow struct {
+	AggregateId string
+	Bucket      int64
+	Version     int64
+	Type        string
+	Data        []byte
+	Created     time.Time
+}
+
+// streamVersion is the row holding the version of the stream of an aggregate
+type streamVersion struct {
+	AggregateId string
+	Version     int64
+}
+
+// eventPageSize is the most events Read reads with a single query
+const eventPageSize = 1000
+
+type eventStreamT struct {
+	events    Table
+	versions  Table
+	snapshots Table
+	// bucketSize is the number of versions of a bucket, or 0 if the events of an aggregate are in a single partition
+	bucketSize int64
+}
+
+func (s *eventStreamT) Append(aggregateId string, expectedVersion int64, events ...Event) (int64, error) {
+	if expectedVersion < 0 {
+		return 0, ValidationError{Table: s.Name(), Field: "Version", Reason: "the expected version can not be negative"}
+	}
+	if len(events) == 0 {
+		return expectedVersion, nil
+	}
+	version := expectedVersion + int64(len(events))
+
+	// Taking the versions of the events is conditional on nobody else having taken them
+	var err error
+	if expectedVersion == 0 {
+		err = s.versions.Set(streamVersion{AggregateId: aggregateId, Version: version}).
+			WithOptions(Options{IfNotExists: true}).Run()
+	} else {
+		err = s.versions.Where(Eq("AggregateId", aggregateId)).
+			Update(map[string]interface{}{"Version": version}).
+			WithOptions(Options{Conditions: []Relation{Eq("Version", expectedVersion)}}).Run()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	writes := make([]Op, len(events))
+	for i, e := range events {
+		row := eventRow{
+			AggregateId: aggregateId,
+			Version:     expectedVersion + int64(i) + 1,
+			Type:        e.Type,
+			Data:        e.Data,
+			Created:     e.Created,
+		}
+		row.Bucket = s.bucket(row.Version)
+		if row.Created.IsZero() {
+			row.Created = time.Now()
+		}
+		writes[i] = s.events.Set(row)
+	}
+	if err := multiOp(writes).RunAtomically(); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *eventStreamT) Version(aggregateId string) (int64, error) {
+	v := streamVersion{}
+	err := s.versions.Where(Eq("AggregateId", aggregateId)).ReadOne(&v).Run()
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return v.Version, err
+}
+
+func (s *eventStreamT) Read(aggregateId string, fromVersion int64, fn func(Event) error) error {
+	version, err := s.Version(aggregateId)
+	if err != nil {
+		return err
+	}
+	from := fromVersion
+	if from < 1 {
+		from = 1
+	}
+	for from <= version {
+		page := []eventRow{}
+		err := s.events.Where(
+			Eq("AggregateId", aggregateId),
+			Eq("Bucket", s.bucket(from)),
+			GTE("Version", from),
+			LTE("Version", version),
+		).Read(&page).WithOptions(Options{Limit: eventPageSize}).Run()
+		if err != nil {
+			return err
+		}
+		// Every version up to the version of the aggregate has an event, unless the Append which took it failed to
+		// write it, which is not skipped silently
+		if len(page) == 0 || page[0].Version != from {
+			return MissingEventError{Table: s.events.Name(), AggregateId: aggregateId, Version: from}
+		}
+		for i, row := range page {
+			if row.Version != from+int64(i) {
+				return MissingEventError{Table: s.events.Name(), AggregateId: aggregateId, Version: from + int64(i)}
+			}
+			event := Event{AggregateId: row.AggregateId, Version: row.Version, Type: row.Type, Data: row.Data, Created: row.Created}
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		from = page[len(page)-1].Version + 1
+	}
+	return nil
+}
+
+func (s *eventStreamT) SaveSnapshot(snapshot Snapshot) error {
+	if snapshot.Version < 1 {
+		return ValidationError{Table: s.snapshots.Name(), Field: "Version", Reason: "a snapshot has to be of a version of at least 1"}
+	}
+	if snapshot.Created.IsZero() {
+		snapshot.Created = time.Now()
+	}
+	return s.snapshots.Set(snapshot).Run()
+}
+
+func (s *eventStreamT) LatestSnapshot(aggregateId string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	err := s.snapshots.Where(Eq("AggregateId", aggregateId)).ReadOne(&snapshot).
+		WithOptions(Options{Limit: 1, ClusteringOrder: []ClusteringOrderColumn{{DESC, "Version"}}}).Run()
+	return snapshot, err
+}
+
+func (s *eventStreamT) WithOptions(o Options) EventStream {
+	return &eventStreamT{
+		events:     s.events.WithOptions(o),
+		versions:   s.versions.WithOptions(o),
+		snapshots:  s.snapshots.WithOptions(o),
+		bucketSize: s.bucketSize,
+	}
+}
+
+// bucket returns the bucket of the event of a version
+func (s *eventStreamT) bucket(version int64) int64 {
+	if s.bucketSize <= 0 {
+		return 0
+	}
+	return (version - 1) / s.bucketSize
+}
+
+func (s *eventStreamT) tables() tableGroup {
+	return tableGroup{s.events, s.versions, s.snapshots}
+}
+
+func (s *eventStreamT) Create() error {
+	return s.tables().Create()
+}
+
+func (s *eventStreamT) CreateStatement() (string, error) {
+	return s.tables().CreateStatement()
+}
+
+func (s *eventStreamT) CreateIfNotExist() error {
+	return s.tables().CreateIfNotExist()
+}
+
+func (s *eventStreamT) CreateIfNotExistStatement() (string, error) {
+	return s.tables().CreateIfNotExistStatement()
+}
+
+func (s *eventStreamT) Recreate() error {
+	return s.tables().Recreate()
+}
+
+func (s *eventStreamT) Name() string {
+	return s.tables().Name()
+}
+
+// tableGroup is the TableChanger of the tables of a recipe which needs several of them. Its name is the name of the
+// first table.
+type tableGroup []Table
+
+func (g tableGroup) Create() error {
+	for _, t := range g {
+		if err := t.Create(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g tableGroup) CreateStatement() (string, error) {
+	return g.statements(Table.CreateStatement)
+}
+
+func (g tableGroup) CreateIfNotExist() error {
+	for _, t := range g {
+		if err := t.CreateIfNotExist(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g tableGroup) CreateIfNotExistStatement() (string, error) {
+	return g.statements(Table.CreateIfNotExistStatement)
+}
+
+func (g tableGroup) Recreate() error {
+	for _, t := range g {
+		if err := t.Recreate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g tableGroup) Name() string {
+	return g[0].Name()
+}
+
+// statements returns the statements of the tables, one after the other
+func (g tableGroup) statements(statement func(Table) (string, error)) (string, error) {
+	stmts := make([]string, len(g))
+	for i, t := range g {
+		stmt, err := statement(t)
+		if err != nil {
+			return "", err
+		}
+		stmts[i] = strings.TrimRight(strings.TrimSpace(stmt), ";") + ";"
+	}
+	return strings.Join(stmts, "\n"), nil
+}
@@ -0,0 +1,156 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readEvents(t *testing.T, stream EventStream, aggregateId string, fromVersion int64) []string {
+	var read []string
+	err := stream.Read(aggregateId, fromVersion, func(e Event) error {
+		read = append(read, fmt.Sprintf("%d:%s", e.Version, e.Type))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return read
+}
+
+func TestEventStream(t *testing.T) {
+	for _, bucketSize := range []int64{0, 2} {
+		stream := NewMockKeySpace().EventStream("orders", bucketSize)
+
+		version, err := stream.Append("o1", 0, Event{Type: "created"}, Event{Type: "paid"})
+		if err != nil || version != 2 {
+			t.Fatalf("expected version 2, got %d, %v", version, err)
+		}
+		if _, err := stream.Append("o1", 0, Event{Type: "created"}); !errors.Is(err, ErrNotApplied) {
+			t.Fatalf("expected appending to a new aggregate which exists not to be applied, got %v", err)
+		}
+		if _, err := stream.Append("o1", 1, Event{Type: "cancelled"}); !errors.Is(err, ErrNotApplied) {
+			t.Fatalf("expected appending at an old version not to be applied, got %v", err)
+		}
+		if version, err = stream.Append("o1", 2, Event{Type: "shipped"}, Event{Type: "delivered"}, Event{Type: "returned"}); err != nil || version != 5 {
+			t.Fatalf("expected version 5, got %d, %v", version, err)
+		}
+		if version, err := stream.Version("o1"); err != nil || version != 5 {
+			t.Fatalf("expected version 5, got %d, %v", version, err)
+		}
+
+		expected := []string{"1:created", "2:paid", "3:shipped", "4:delivered", "5:returned"}
+		if read := readEvents(t, stream, "o1", 0); !reflect.DeepEqual(read, expected) {
+			t.Fatalf("bucket size %d: expected %v, got %v", bucketSize, expected, read)
+		}
+		if read := readEvents(t, stream, "o1", 4); !reflect.DeepEqual(read, expected[3:]) {
+			t.Fatalf("bucket size %d: expected %v, got %v", bucketSize, expected[3:], read)
+		}
+		if read := readEvents(t, stream, "o2", 0); len(read) != 0 {
+			t.Fatalf("expected no events for a new aggregate, got %v", read)
+		}
+		stop := errors.New("stop")
+		if err := stream.Read("o1", 0, func(Event) error { return stop }); err != stop {
+			t.Fatalf("expected Read to stop at the error of fn, got %v", err)
+		}
+	}
+}
+
+// failingSetTable is a Table whose writes fail
+type failingSetTable struct {
+	Table
+}
+
+func (t failingSetTable) Set(v interface{}) Op {
+	return &badOp{errors.New("write failed")}
+}
+
+func TestEventStreamFailedAppend(t *testing.T) {
+	for _, bucketSize := range []int64{0, 2} {
+		stream := NewMockKeySpace().EventStream("orders", bucketSize).(*eventStreamT)
+		if _, err := stream.Append("o1", 0, Event{Type: "created"}, Event{Type: "paid"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The versions are taken, and then writing the events fails
+		failing := *stream
+		failing.events = failingSetTable{stream.events}
+		if _, err := failing.Append("o1", 2, Event{Type: "shipped"}, Event{Type: "delivered"}); err == nil {
+			t.Fatal("expected the append to fail")
+		}
+		if version, err := stream.Version("o1"); err != nil || version != 4 {
+			t.Fatalf("expected the versions to be taken, got %d, %v", version, err)
+		}
+
+		// Readers get the events before the missing ones, and then an error rather than a stream with holes
+		var read []int64
+		err := stream.Read("o1", 0, func(e Event) error {
+			read = append(read, e.Version)
+			return nil
+		})
+		var missing MissingEventError
+		if !errors.As(err, &missing) || missing.Version != 3 || !errors.Is(err, ErrNotFound) {
+			t.Fatalf("bucket size %d: expected a MissingEventError at version 3, got %v", bucketSize, err)
+		}
+		if !reflect.DeepEqual(read, []int64{1, 2}) {
+			t.Fatalf("bucket size %d: expected the events before the missing ones, got %v", bucketSize, read)
+		}
+
+		// An event missing in the middle of a page is reported too
+		if _, err := stream.Append("o2", 0, Event{Type: "created"}, Event{Type: "paid"}, Event{Type: "shipped"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.events.Where(Eq("AggregateId", "o2"), Eq("Bucket", stream.bucket(2)), Eq("Version", 2)).Delete().Run(); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Read("o2", 0, func(Event) error { return nil }); !errors.As(err, &missing) || missing.Version != 2 {
+			t.Fatalf("bucket size %d: expected a MissingEventError at version 2, got %v", bucketSize, err)
+		}
+	}
+}
+
+func TestEventStreamSnapshots(t *testing.T) {
+	stream := NewMockKeySpace().EventStream("orders", 0)
+	if _, err := stream.LatestSnapshot("o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without snapshots, got %v", err)
+	}
+	for _, version := range []int64{2, 4, 3} {
+		if err := stream.SaveSnapshot(Snapshot{AggregateId: "o1", Version: version, Data: []byte(fmt.Sprint(version))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := stream.LatestSnapshot("o1")
+	if err != nil || snapshot.Version != 4 || string(snapshot.Data) != "4" {
+		t.Fatalf("expected the snapshot of version 4, got %+v, %v", snapshot, err)
+	}
+	if err := stream.SaveSnapshot(Snapshot{AggregateId: "o1"}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a snapshot without a version, got %v", err)
+	}
+}
+
+func TestEventStreamStatements(t *testing.T) {
+	qe := lwtQE{StatementRecordingQE: newStatementRecordingQE(), result: map[string]interface{}{"[applied]": true}}
+	stream := (&connection{q: qe}).KeySpace("some_ks").EventStream("orders", 100)
+
+	stmt, err := stream.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"orders_events", "orders_eventVersions", "orders_eventSnapshots"} {
+		if !strings.Contains(stmt, "CREATE TABLE some_ks."+table+" (") {
+			t.Fatalf("expected the creation of %s, got %s", table, stmt)
+		}
+	}
+	if !strings.Contains(stmt, "PRIMARY KEY ((aggregateid, bucket), version)") {
+		t.Fatalf("expected the events to be bucketed, got %s", stmt)
+	}
+
+	if _, err := stream.Append("o1", 3, Event{Type: "paid"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "UPDATE some_ks.orders_eventVersions SET Version = ? WHERE aggregateid = ? IF version = ?"; (*qe.stmts)[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, (*qe.stmts)[0])
+	}
+}
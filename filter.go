@@ -6,13 +6,39 @@ type filter struct {
 }
 
 func (f filter) Update(m map[string]interface{}) Op {
-	return newWriteOp(f.t.keySpace.qe, f, updateOpType, m)
+	cols := withoutUnset(m)
+	if f.t.options.PartialSet {
+		cols = withoutEmpty(cols, f.t.info.keys)
+	}
+	if len(cols) == 0 && len(m) > 0 {
+		// Every column was left out, so there is nothing to write
+		return Noop()
+	}
+	return newWriteOp(f.t.keySpace.qe, f, updateOpType, cols)
 }
 
 func (f filter) Delete() Op {
 	return newWriteOp(f.t.keySpace.qe, f, deleteOpType, nil)
 }
 
+func (f filter) DeleteColumns(columns ...string) Op {
+	op := newWriteOp(f.t.keySpace.qe, f, deleteOpType, columnSet(columns))
+	op.columns = columns
+	return op
+}
+
+// columnSet returns the columns as the keys of a map, which is how the columns written by an Op are validated
+func columnSet(columns []string) map[string]interface{} {
+	if len(columns) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(columns))
+	for _, c := range columns {
+		m[c] = nil
+	}
+	return m
+}
+
 //
 // Reads
 //
@@ -32,3 +58,35 @@ func (f filter) ReadOne(pointer interface{}) Op {
 		opType: singleReadOpType,
 		result: pointer}
 }
+
+func (f filter) Count(count *int64) Op {
+	return &singleOp{
+		qe:         f.t.keySpace.qe,
+		f:          f,
+		opType:     singleReadOpType,
+		result:     count,
+		projection: countProjection()}
+}
+
+func (f filter) Distinct(pointerToASlice interface{}, columns ...string) Op {
+	return &singleOp{
+		qe:         f.t.keySpace.qe,
+		f:          f,
+		opType:     readOpType,
+		result:     pointerToASlice,
+		projection: distinctProjection(f.t.info.keys, columns)}
+}
+
+func (f filter) Aggregate(pointer interface{}, aggregates ...Aggregate) Op {
+	opType := singleReadOpType
+	if isSlicePointer(pointer) {
+		// One row per group
+		opType = readOpType
+	}
+	return &singleOp{
+		qe:         f.t.keySpace.qe,
+		f:          f,
+		opType:     opType,
+		result:     pointer,
+		projection: aggregateProjection(aggregates)}
+}
@@ -26,6 +26,10 @@ func (b *myBucketer) Next(secs int64) int64 {
 	return secs + int64(b.bucketSize/time.Second)*1000
 }
 
+func (b *myBucketer) Prev(secs int64) int64 {
+	return secs - int64(b.bucketSize/time.Second)*1000
+}
+
 func (b *myBucketer) String() string {
 	return BucketerString(b)
 }
@@ -3,11 +3,15 @@ package gocassa
 import (
 	"errors"
 	"fmt"
+	"math/big"
+	"net"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
 )
 
 // CREATE TABLE users (
@@ -28,21 +32,33 @@ import (
 // );
 //
 
-func createTableIfNotExist(keySpace string, types map[string]string, cf string, partitionKeys, colKeys []string, fields []string, values []interface{}, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
-	return createTableStmt("CREATE TABLE IF NOT EXISTS", keySpace, types, cf, partitionKeys, colKeys, fields, values, order, compoundKey, compact, compressor)
+func createTableIfNotExist(keySpace string, types map[string]string, cf string, partitionKeys, colKeys, staticCols []string, fields []string, values []interface{}, fieldTypes map[string]string, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
+	return createTableStmt("CREATE TABLE IF NOT EXISTS", keySpace, types, cf, partitionKeys, colKeys, staticCols, fields, values, fieldTypes, order, compoundKey, compact, compressor)
 }
 
-func createTable(keySpace string, types map[string]string, cf string, partitionKeys, colKeys []string, fields []string, values []interface{}, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
-	return createTableStmt("CREATE TABLE", keySpace, types, cf, partitionKeys, colKeys, fields, values, order, compoundKey, compact, compressor)
+func createTable(keySpace string, types map[string]string, cf string, partitionKeys, colKeys, staticCols []string, fields []string, values []interface{}, fieldTypes map[string]string, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
+	return createTableStmt("CREATE TABLE", keySpace, types, cf, partitionKeys, colKeys, staticCols, fields, values, fieldTypes, order, compoundKey, compact, compressor)
 }
 
-func createTableStmt(createStmt, keySpace string, types map[string]string, cf string, partitionKeys, colKeys []string, fields []string, values []interface{}, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
+func createTableStmt(createStmt, keySpace string, types map[string]string, cf string, partitionKeys, colKeys, staticCols []string, fields []string, values []interface{}, fieldTypes map[string]string, order []ClusteringOrderColumn, compoundKey, compact bool, compressor string) (string, error) {
+	if len(staticCols) > 0 && len(colKeys) == 0 {
+		return "", ValidationError{Table: cf, Reason: "static columns need a table with clustering columns"}
+	}
+	for _, c := range staticCols {
+		if isKey(c, partitionKeys, colKeys) {
+			return "", ValidationError{Table: cf, Field: c, Reason: "a static column can't be part of the primary key"}
+		}
+	}
 	firstLine := fmt.Sprintf("%s %v.%v (", createStmt, keySpace, cf)
 	fieldLines := []string{}
 	for i := range fields {
-		typeStr, err := stringTypeOf(types, values[i])
+		typeStr, err := columnType(types, fieldTypes, fields[i], values[i])
 		if err != nil {
-			return "", err
+			return "", withColumn(err, cf, fields[i])
+		}
+		typeStr = frozenIf(isKey(fields[i], partitionKeys, colKeys), typeStr)
+		if isKey(fields[i], staticCols) {
+			typeStr += " STATIC"
 		}
 		l := "    " + strings.ToLower(fields[i]) + " " + typeStr
 		fieldLines = append(fieldLines, l)
@@ -97,21 +113,32 @@ func createTableStmt(createStmt, keySpace string, types map[string]string, cf st
 	return stmt, nil
 }
 
-func createTypeIfNotExist(keySpace, cf string, fields []string, values []interface{}) (string, error) {
-	return createTypeStmt("CREATE TYPE IF NOT EXISTS", keySpace, cf, fields, values)
+func isKey(field string, keys ...[]string) bool {
+	for _, ks := range keys {
+		for _, k := range ks {
+			if strings.EqualFold(k, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func createTypeIfNotExist(keySpace string, types map[string]string, cf string, fields []string, values []interface{}, fieldTypes map[string]string) (string, error) {
+	return createTypeStmt("CREATE TYPE IF NOT EXISTS", keySpace, types, cf, fields, values, fieldTypes)
 }
 
-func createType(keySpace, cf string, fields []string, values []interface{}) (string, error) {
-	return createTypeStmt("CREATE TYPE", keySpace, cf, fields, values)
+func createType(keySpace string, types map[string]string, cf string, fields []string, values []interface{}, fieldTypes map[string]string) (string, error) {
+	return createTypeStmt("CREATE TYPE", keySpace, types, cf, fields, values, fieldTypes)
 }
 
-func createTypeStmt(createStmt, keySpace, cf string, fields []string, values []interface{}) (string, error) {
+func createTypeStmt(createStmt, keySpace string, types map[string]string, cf string, fields []string, values []interface{}, fieldTypes map[string]string) (string, error) {
 	firstLine := fmt.Sprintf("%s %v.%v (", createStmt, keySpace, cf)
 	fieldLines := []string{}
 	for i := range fields {
-		typeStr, err := stringTypeOf(nil, values[i])
+		typeStr, err := columnType(types, fieldTypes, fields[i], values[i])
 		if err != nil {
-			return "", err
+			return "", withColumn(err, cf, fields[i])
 		}
 		l := "    " + strings.ToLower(fields[i]) + " " + typeStr
 		fieldLines = append(fieldLines, l)
@@ -128,6 +155,25 @@ func createTypeStmt(createStmt, keySpace, cf string, fields []string, values []i
 	return stmt, nil
 }
 
+// ALTER TYPE keyspace.address ADD country varchar
+//
+// One statement is returned for each field which is not amongst the existing fields of the type.
+func alterTypeAdd(keySpace string, types map[string]string, cf string, existingFields, fields []string, values []interface{}, fieldTypes map[string]string) ([]string, error) {
+	stmts := []string{}
+	for i := range fields {
+		if isKey(fields[i], existingFields) {
+			continue
+		}
+		typeStr, err := columnType(types, fieldTypes, fields[i], values[i])
+		if err != nil {
+			return nil, withColumn(err, cf, fields[i])
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TYPE %v.%v ADD %v %v", keySpace, cf, strings.ToLower(fields[i]), typeStr))
+	}
+	sort.Strings(stmts)
+	return stmts, nil
+}
+
 func j(s []string) string {
 	s1 := []string{}
 	for _, v := range s {
@@ -142,12 +188,18 @@ func createKeyspace(keyspaceName string) string {
 
 func cassaType(i interface{}) gocql.Type {
 	switch i.(type) {
-	case int, int32:
+	case int, int32, uint16:
 		return gocql.TypeInt
-	case int64:
+	case int64, uint32:
 		return gocql.TypeBigInt
-	case int8, int16, uint, uint8, uint16, uint32, uint64:
+	case int16, uint8:
+		return gocql.TypeSmallInt
+	case int8:
+		return gocql.TypeTinyInt
+	case uint, uint64, big.Int, *big.Int:
 		return gocql.TypeVarint
+	case inf.Dec, *inf.Dec, big.Float, *big.Float:
+		return gocql.TypeDecimal
 	case string:
 		return gocql.TypeVarchar
 	case float32:
@@ -158,8 +210,12 @@ func cassaType(i interface{}) gocql.Type {
 		return gocql.TypeBoolean
 	case time.Time:
 		return gocql.TypeTimestamp
+	case gocql.Duration:
+		return gocql.TypeDuration
 	case gocql.UUID:
 		return gocql.TypeUUID
+	case net.IP:
+		return gocql.TypeInet
 	case []byte:
 		return gocql.TypeBlob
 	case Counter:
@@ -168,11 +224,20 @@ func cassaType(i interface{}) gocql.Type {
 
 	// Fallback to using reflection if type not recognised
 	typ := reflect.TypeOf(i)
+	if typ == nil {
+		return gocql.TypeCustom
+	}
 	switch typ.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+	case reflect.Int, reflect.Int32, reflect.Uint16:
 		return gocql.TypeInt
-	case reflect.Int64:
+	case reflect.Int64, reflect.Uint32:
 		return gocql.TypeBigInt
+	case reflect.Int16, reflect.Uint8:
+		return gocql.TypeSmallInt
+	case reflect.Int8:
+		return gocql.TypeTinyInt
+	case reflect.Uint, reflect.Uint64:
+		return gocql.TypeVarint
 	case reflect.String:
 		return gocql.TypeVarchar
 	case reflect.Float32:
@@ -181,96 +246,151 @@ func cassaType(i interface{}) gocql.Type {
 		return gocql.TypeDouble
 	case reflect.Bool:
 		return gocql.TypeBoolean
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return gocql.TypeBlob
+		}
+	case reflect.Ptr:
+		// Pointers are nullable columns of the type they point to
+		return cassaType(reflect.Zero(typ.Elem()).Interface())
 	}
 
 	return gocql.TypeCustom
 }
 
+// columnType returns the CQL type of a column, preferring the type declared in the struct tag (eg.
+// `cql:"created,type=timeuuid"`) over the one derived from the Go type of the field.
+func columnType(types map[string]string, fieldTypes map[string]string, field string, i interface{}) (string, error) {
+	if typ := strings.TrimSpace(fieldTypes[field]); typ != "" {
+		return strings.ToLower(typ), nil
+	}
+	return stringTypeOf(types, i)
+}
+
 func stringTypeOf(types map[string]string, i interface{}) (string, error) {
-	_, isByteSlice := i.([]byte)
-	if !isByteSlice {
-		// Check if we found a higher kinded type
-		switch reflect.ValueOf(i).Kind() {
-		case reflect.Slice:
-			elemVal := reflect.Indirect(reflect.New(reflect.TypeOf(i).Elem())).Interface()
-			ct := cassaType(elemVal)
-			if ct == gocql.TypeCustom {
-				if types != nil {
-					if udt := stringUdtOf(types, elemVal, "list<frozen<%v>>"); udt != "" {
-						return udt, nil
-					}
-				}
-				return "", fmt.Errorf("Unsupported type %T", i)
-			}
-			return fmt.Sprintf("list<%v>", ct), nil
-		case reflect.Map:
-			keyVal := reflect.Indirect(reflect.New(reflect.TypeOf(i).Key())).Interface()
-			keyCt := cassaType(keyVal)
-			if keyCt == gocql.TypeCustom {
-				return "", fmt.Errorf("Unsupported map key type %T", i)
+	if i == nil {
+		return "", UnsupportedTypeError{Type: fmt.Sprintf("%T", i)}
+	}
+	return stringTypeOfType(types, reflect.TypeOf(i), false)
+}
+
+// stringTypeOfType returns the CQL type of a Go type, descending into collections, tuples and user defined types.
+// Types nested inside another type are frozen where CQL requires it.
+func stringTypeOfType(types map[string]string, t reflect.Type, nested bool) (string, error) {
+	if c := codecOf(t); c != nil && c.cqlType != "" {
+		return c.cqlType, nil
+	}
+	if ct := cassaType(reflect.Zero(t).Interface()); ct != gocql.TypeCustom {
+		return cassaTypeToString(ct)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return stringTypeOfType(types, t.Elem(), nested)
+	case reflect.Slice:
+		elem, err := stringTypeOfType(types, t.Elem(), true)
+		if err != nil {
+			return "", err
+		}
+		return frozenIf(nested, fmt.Sprintf("list<%v>", elem)), nil
+	case reflect.Map:
+		key, err := stringTypeOfType(types, t.Key(), true)
+		if err != nil {
+			return "", UnsupportedTypeError{Type: t.String(), Part: "map key"}
+		}
+		elem, err := stringTypeOfType(types, t.Elem(), true)
+		if err != nil {
+			return "", UnsupportedTypeError{Type: t.String(), Part: "map value"}
+		}
+		return frozenIf(nested, fmt.Sprintf("map<%v, %v>", key, elem)), nil
+	case reflect.Array:
+		// Fixed size arrays are tuples with all elements of the same type
+		elems := make([]string, t.Len())
+		for i := range elems {
+			elem, err := stringTypeOfType(types, t.Elem(), true)
+			if err != nil {
+				return "", err
 			}
-			elemVal := reflect.Indirect(reflect.New(reflect.TypeOf(i).Elem())).Interface()
-			elemCt := cassaType(elemVal)
-			if elemCt == gocql.TypeCustom {
-				if types != nil {
-					if udt := stringUdtOf(types, elemVal, "map<%v, frozen<%v>>", keyCt); udt != "" {
-						return udt, nil
-					}
+			elems[i] = elem
+		}
+		return fmt.Sprintf("tuple<%v>", strings.Join(elems, ", ")), nil
+	case reflect.Struct:
+		if isTupleStruct(t) {
+			elems := make([]string, t.NumField())
+			for i := range elems {
+				elem, err := stringTypeOfType(types, t.Field(i).Type, true)
+				if err != nil {
+					return "", err
 				}
-				return "", fmt.Errorf("Unsupported map value type %T", i)
+				elems[i] = elem
 			}
-			return fmt.Sprintf("map<%v, %v>", keyCt, elemCt), nil
+			return fmt.Sprintf("tuple<%v>", strings.Join(elems, ", ")), nil
 		}
-	}
-	ct := cassaType(i)
-	if ct == gocql.TypeCustom {
-		if types != nil {
-			if udt := stringUdtOf(types, i, "frozen<%v>"); udt != "" {
-				return udt, nil
-			}
+		if udt := types[t.String()]; udt != "" {
+			return fmt.Sprintf("frozen<%v>", udt), nil
 		}
-		return "", fmt.Errorf("Unsupported type %T", i)
 	}
-	return cassaTypeToString(ct)
+	return "", UnsupportedTypeError{Type: t.String()}
 }
 
-func stringUdtOf(types map[string]string, i interface{}, format string, a ...interface{}) string {
-	t := reflect.ValueOf(i).Type().String()
-	udt := types[t]
-	if udt == "" {
-		return ""
-	}
-	if a != nil {
-		return fmt.Sprintf(format, udt, a)
+var tupleType = reflect.TypeOf((*Tuple)(nil)).Elem()
+
+func isTupleStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && (t.Implements(tupleType) || reflect.PtrTo(t).Implements(tupleType))
+}
+
+// frozenIf freezes a collection type, which CQL requires for collections nested in other types and for
+// collections which are part of the primary key.
+func frozenIf(freeze bool, typ string) string {
+	if !freeze || !isCollectionType(typ) {
+		return typ
 	}
-	return fmt.Sprintf(format, udt)
+	return fmt.Sprintf("frozen<%v>", typ)
+}
+
+func isCollectionType(typ string) bool {
+	return strings.HasPrefix(typ, "list<") || strings.HasPrefix(typ, "set<") || strings.HasPrefix(typ, "map<")
+}
+
+var cassaTypeNames = map[gocql.Type]string{
+	gocql.TypeAscii:     "ascii",
+	gocql.TypeText:      "text",
+	gocql.TypeVarchar:   "varchar",
+	gocql.TypeTinyInt:   "tinyint",
+	gocql.TypeSmallInt:  "smallint",
+	gocql.TypeInt:       "int",
+	gocql.TypeBigInt:    "bigint",
+	gocql.TypeVarint:    "varint",
+	gocql.TypeDecimal:   "decimal",
+	gocql.TypeFloat:     "float",
+	gocql.TypeDouble:    "double",
+	gocql.TypeBoolean:   "boolean",
+	gocql.TypeTimestamp: "timestamp",
+	gocql.TypeDate:      "date",
+	gocql.TypeTime:      "time",
+	gocql.TypeDuration:  "duration",
+	gocql.TypeUUID:      "uuid",
+	gocql.TypeTimeUUID:  "timeuuid",
+	gocql.TypeInet:      "inet",
+	gocql.TypeBlob:      "blob",
+	gocql.TypeCounter:   "counter",
 }
 
 func cassaTypeToString(t gocql.Type) (string, error) {
-	switch t {
-	case gocql.TypeInt:
-		return "int", nil
-	case gocql.TypeBigInt:
-		return "bigint", nil
-	case gocql.TypeVarint:
-		return "varint", nil
-	case gocql.TypeVarchar:
-		return "varchar", nil
-	case gocql.TypeFloat:
-		return "float", nil
-	case gocql.TypeDouble:
-		return "double", nil
-	case gocql.TypeBoolean:
-		return "boolean", nil
-	case gocql.TypeTimestamp:
-		return "timestamp", nil
-	case gocql.TypeUUID:
-		return "uuid", nil
-	case gocql.TypeBlob:
-		return "blob", nil
-	case gocql.TypeCounter:
-		return "counter", nil
-	default:
-		return "", errors.New("unkown cassandra type")
+	if s, ok := cassaTypeNames[t]; ok {
+		return s, nil
 	}
+	return "", errors.New("unkown cassandra type")
+}
+
+// cassaTypeFromString is the inverse of cassaTypeToString. It returns gocql.TypeCustom for anything which is
+// not a native CQL type, eg. collections or user defined types.
+func cassaTypeFromString(s string) gocql.Type {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for t, name := range cassaTypeNames {
+		if name == s {
+			return t
+		}
+	}
+	return gocql.TypeCustom
 }
@@ -16,15 +16,63 @@ func (cb goCQLBackend) QueryWithOptions(opts Options, stmt string, vals ...inter
 		qu = qu.Consistency(*opts.Consistency)
 	}
 	iter := qu.Iter()
+	tuples := tupleColumns(iter.Columns())
 	ret := []map[string]interface{}{}
 	m := &map[string]interface{}{}
 	for iter.MapScan(*m) {
+		collapseTuples(*m, tuples)
 		ret = append(ret, *m)
 		m = &map[string]interface{}{}
 	}
 	return ret, iter.Close()
 }
 
+// scanWithOptions runs a query, scanning its rows straight into the destinations of d instead of maps
+func (cb goCQLBackend) scanWithOptions(opts Options, stmt string, vals []interface{}, d rowDecoder) error {
+	qu := cb.session.Query(stmt, vals...)
+	if opts.Consistency != nil {
+		qu = qu.Consistency(*opts.Consistency)
+	}
+	iter := qu.Iter()
+	d.setColumns(iter.Columns())
+	for iter.Scan(d.dest()...) {
+		more, err := d.row()
+		if err != nil || !more {
+			closeErr := iter.Close()
+			if err == nil {
+				err = closeErr
+			}
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+// tupleColumns returns the number of elements of each tuple column in a result
+func tupleColumns(columns []gocql.ColumnInfo) map[string]int {
+	tuples := map[string]int{}
+	for _, c := range columns {
+		if t, ok := c.TypeInfo.(gocql.TupleTypeInfo); ok {
+			tuples[c.Name] = len(t.Elems)
+		}
+	}
+	return tuples
+}
+
+// collapseTuples gathers the elements of tuple columns, which gocql scans into separate "column[i]" entries, back
+// into a single slice under the column's name
+func collapseTuples(m map[string]interface{}, tuples map[string]int) {
+	for name, n := range tuples {
+		elems := make([]interface{}, n)
+		for i := range elems {
+			col := gocql.TupleColumnName(name, i)
+			elems[i] = m[col]
+			delete(m, col)
+		}
+		m[name] = elems
+	}
+}
+
 func (cb goCQLBackend) Query(stmt string, vals ...interface{}) ([]map[string]interface{}, error) {
 	return cb.QueryWithOptions(Options{}, stmt, vals...)
 }
@@ -56,6 +104,21 @@ func (cb goCQLBackend) ExecuteAtomically(stmts []string, vals [][]interface{}) e
 	return cb.session.ExecuteBatch(batch)
 }
 
+func (cb goCQLBackend) ExecuteCounterBatch(stmts []string, vals [][]interface{}) error {
+	if len(stmts) != len(vals) {
+		return errors.New("executeCounterBatch: stmts length != param length")
+	}
+
+	if len(stmts) == 0 {
+		return nil
+	}
+	batch := cb.session.NewBatch(gocql.CounterBatch)
+	for i := range stmts {
+		batch.Query(stmts[i], vals[i]...)
+	}
+	return cb.session.ExecuteBatch(batch)
+}
+
 func (cb goCQLBackend) Close() {
 	cb.session.Close()
 }
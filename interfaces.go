@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"context"
 	"time"
 )
 
@@ -18,12 +19,42 @@ type Connection interface {
 type KeySpace interface {
 	MapTable(tableName, id string, row interface{}) MapTable
 	MultimapTable(tableName, fieldToIndexBy, uniqueKey string, row interface{}) MultimapTable
+	// ShardedMultimapTable is like MultimapTable, but spreads the rows of each value over shards partitions, picked by
+	// the rows' ids, so that values with many rows do not make partitions too large. List reads all the shards.
+	ShardedMultimapTable(tableName, fieldToIndexBy, uniqueKey string, shards int, row interface{}) MultimapTable
 	MultimapMultiKeyTable(tableName string, fieldToIndexBy, uniqueKey []string, row interface{}) MultimapMkTable
 	TimeSeriesTable(tableName, timeField, uniqueKey string, bucketSize time.Duration, row interface{}) TimeSeriesTable
+	FlexTimeSeriesTable(name, timeField, idField string, bucketer Bucketer, row interface{}) TimeSeriesTable
 	MultiTimeSeriesTable(tableName, fieldToIndexByField, timeField, uniqueKey string, bucketSize time.Duration, row interface{}) MultiTimeSeriesTable
 	FlexMultiTimeSeriesTable(name, timeField, idField string, indexFields []string, bucketer Bucketer, row interface{}) MultiTimeSeriesTable
+	CounterTable(tableName string, keyFields []string, row interface{}, counterFields ...string) CounterTable
+	UniqueIndex(tableName, field, ownerField string, row interface{}) UniqueIndex
+	LeaseTable(tableName string) LeaseTable
+	EventStream(tableName string, bucketSize int64) EventStream
+	Queue(tableName string, shards int, bucketSize time.Duration) Queue
 	Table(tableName string, row interface{}, keys Keys) Table
+	// TableFromTags is like Table, but reads the keys from the `partition`, `clustering`, `desc` and `static` options
+	// of the row's struct tags, eg. `cql:"id,partition"` or `cql:"created,clustering=1,desc"`.
+	TableFromTags(tableName string, row interface{}) Table
 	Type(typeName string, row interface{}) Type
+
+	// The E variants of the table and type constructors return a ValidationError instead of panicking when the row
+	// type is not understood or the keys are not fields of it.
+	MapTableE(tableName, id string, row interface{}) (MapTable, error)
+	MultimapTableE(tableName, fieldToIndexBy, uniqueKey string, row interface{}) (MultimapTable, error)
+	ShardedMultimapTableE(tableName, fieldToIndexBy, uniqueKey string, shards int, row interface{}) (MultimapTable, error)
+	MultimapMultiKeyTableE(tableName string, fieldToIndexBy, uniqueKey []string, row interface{}) (MultimapMkTable, error)
+	TimeSeriesTableE(tableName, timeField, uniqueKey string, bucketSize time.Duration, row interface{}) (TimeSeriesTable, error)
+	FlexTimeSeriesTableE(name, timeField, idField string, bucketer Bucketer, row interface{}) (TimeSeriesTable, error)
+	MultiTimeSeriesTableE(tableName, fieldToIndexByField, timeField, uniqueKey string, bucketSize time.Duration, row interface{}) (MultiTimeSeriesTable, error)
+	FlexMultiTimeSeriesTableE(name, timeField, idField string, indexFields []string, bucketer Bucketer, row interface{}) (MultiTimeSeriesTable, error)
+	CounterTableE(tableName string, keyFields []string, row interface{}, counterFields ...string) (CounterTable, error)
+	UniqueIndexE(tableName, field, ownerField string, row interface{}) (UniqueIndex, error)
+	QueueE(tableName string, shards int, bucketSize time.Duration) (Queue, error)
+	TableE(tableName string, row interface{}, keys Keys) (Table, error)
+	TableFromTagsE(tableName string, row interface{}) (Table, error)
+	TypeE(typeName string, row interface{}) (Type, error)
+
 	// DebugMode enables/disables debug mode depending on the value of the input boolean.
 	// When DebugMode is enabled, all built CQL statements are printe to stdout.
 	DebugMode(bool)
@@ -96,6 +127,16 @@ type TimeSeriesTable interface {
 	Delete(timeStamp time.Time, id interface{}) Op
 	Read(timeStamp time.Time, id, pointer interface{}) Op
 	List(start, end time.Time, pointerToASlice interface{}) Op
+	// ListRange lists the rows between start and end, both inclusive, in the given order of time. It reads the buckets
+	// one at a time and stops once it has read limit rows, or all of them if limit is 0. A non empty cursor resumes
+	// the listing after the row it points to. If next is not nil, it is set to the cursor of the last row once limit
+	// rows have been read, and emptied when the range has been read to the end. If Options.MaxBuckets is set, the
+	// listing also stops after reading that many buckets, setting next to the cursor of the following bucket, or
+	// failing with a ValidationError if next is nil.
+	ListRange(start, end time.Time, order ColumnDirection, limit int, cursor Cursor, pointerToASlice interface{}, next *Cursor) Op
+	// ListLatest lists the latest limit rows since the given time, newest first. It fails with a ValidationError if it
+	// reads Options.MaxBuckets buckets, when that is set, without reaching since or limit rows.
+	ListLatest(since time.Time, limit int, pointerToASlice interface{}) Op
 	WithOptions(Options) TimeSeriesTable
 	TableChanger
 }
@@ -112,10 +153,136 @@ type MultiTimeSeriesTable interface {
 	Delete(v interface{}, timeStamp time.Time, id interface{}) Op
 	Read(v interface{}, timeStamp time.Time, id, pointer interface{}) Op
 	List(v interface{}, start, end time.Time, pointerToASlice interface{}) Op
+	// ListRange and ListLatest are TimeSeriesTable.ListRange and TimeSeriesTable.ListLatest, for the rows of v
+	ListRange(v interface{}, start, end time.Time, order ColumnDirection, limit int, cursor Cursor, pointerToASlice interface{}, next *Cursor) Op
+	ListLatest(v interface{}, since time.Time, limit int, pointerToASlice interface{}) Op
 	WithOptions(Options) MultiTimeSeriesTable
 	TableChanger
 }
 
+//
+// Counter recipe
+//
+
+// CounterTable holds counters, eg. 'the number of views of a page per day'. A key is the value of the key field, or a
+// map of the values of the key fields when there are several of them.
+type CounterTable interface {
+	Incr(key interface{}, counter string, delta int) Op
+	Decr(key interface{}, counter string, delta int) Op
+	// IncrMany increments several counters of a row with a single statement
+	IncrMany(key interface{}, deltas map[string]int) Op
+	// Get reads the counters of a row, which are all 0 if they have never been incremented
+	Get(key, pointer interface{}) Op
+	// GetMany reads the counters of several rows, whose keys can only differ by their last key field. Rows whose
+	// counters have never been incremented are left out.
+	GetMany(keys []interface{}, pointerToASlice interface{}) Op
+	WithOptions(Options) CounterTable
+	TableChanger
+}
+
+//
+// Unique index recipe
+//
+
+// UniqueIndex makes the values of a field unique across rows, eg. the emails of users, by recording which row owns
+// each value with lightweight transactions. Ops which find the value owned by another row fail with a
+// NotAppliedError.
+type UniqueIndex interface {
+	// Claim claims value for owner with INSERT IF NOT EXISTS. Claiming a value again for its owner succeeds.
+	Claim(value, owner interface{}) Op
+	// Release releases the claim of owner on value. Releasing a value which is not claimed succeeds.
+	Release(value, owner interface{}) Op
+	// Transfer moves the claim on value from one owner to another
+	Transfer(value, from, to interface{}) Op
+	// Owner reads the owner of value into pointer, failing with ErrNotFound if the value is not claimed
+	Owner(value, pointer interface{}) Op
+	// ClaimAnd claims value for owner and then runs op, eg. the MapTable.Set of the owner, releasing the claim if op
+	// fails and the value was not claimed by owner already. It fails with a ReleaseError if the release fails too. The
+	// claim can't be part of a batch, so RunAtomically only runs op atomically.
+	ClaimAnd(value, owner interface{}, op Op) Op
+	WithOptions(Options) UniqueIndex
+	TableChanger
+}
+
+//
+// Lease recipe
+//
+
+// LeaseTable holds leases on names, eg. to run a job on a single process at a time. A lease is held until it expires,
+// and the holder's columns are written with the TTL of the lease. Ops which find the lease held by another holder, or
+// lost, fail with a NotAppliedError.
+type LeaseTable interface {
+	// Acquire acquires the lease on name for holder, with a new fencing token. Acquiring a lease again for its holder
+	// succeeds.
+	Acquire(name, holder string, ttl time.Duration) (Lease, error)
+	// Renew extends a lease which is still held by ttl from now
+	Renew(lease Lease, ttl time.Duration) (Lease, error)
+	// Release releases a lease. Releasing a lease which has been lost does nothing.
+	Release(lease Lease) error
+	// Read reads the current lease on name, whose holder is empty if it is not held
+	Read(name string) (Lease, error)
+	// Keep renews lease every third of ttl, as told by the clock of the table. Renewals which fail with errors other
+	// than ErrNotApplied are retried until the lease expires. The returned context is cancelled when the lease is
+	// lost, when ctx is done, or when the returned function, which stops the renewals and releases the lease, is called.
+	Keep(ctx context.Context, lease Lease, ttl time.Duration) (context.Context, func())
+	// WithClock returns the table reading the time from now, which otherwise is time.Now
+	WithClock(now func() time.Time) LeaseTable
+	WithOptions(Options) LeaseTable
+	TableChanger
+}
+
+//
+// Event stream recipe
+//
+
+// EventStream stores the events of aggregates in order, eg. for event sourcing. The events of an aggregate are in
+// its own partition, or in partitions of bucketSize versions each if the stream was created with a bucket size. The
+// version of each aggregate is held in a row of its own, which appends update with a lightweight transaction.
+type EventStream interface {
+	// Append appends events to the stream of an aggregate if its version is expectedVersion, which is 0 for a new
+	// aggregate, and returns its new version. It fails with a NotAppliedError if the version is another. The events
+	// are written once their versions have been taken, so if writing them fails their versions stay taken without
+	// events, which Read reports.
+	Append(aggregateId string, expectedVersion int64, events ...Event) (int64, error)
+	// Version returns the version of an aggregate, which is 0 if it has no events
+	Version(aggregateId string) (int64, error)
+	// Read calls fn with the events of an aggregate from fromVersion on, in order, reading them a page at a time. It
+	// stops at the first error fn returns, and fails with a MissingEventError at the first version without an event
+	// instead of skipping it.
+	Read(aggregateId string, fromVersion int64, fn func(Event) error) error
+	SaveSnapshot(snapshot Snapshot) error
+	// LatestSnapshot reads the snapshot of an aggregate with the greatest version, failing with ErrNotFound if there
+	// is none. Events are replayed on it by reading them from its version + 1.
+	LatestSnapshot(aggregateId string) (Snapshot, error)
+	WithOptions(Options) EventStream
+	TableChanger
+}
+
+//
+// Queue recipe
+//
+
+// Queue holds work items until they are acknowledged. Items are spread over shards by their id, and stored in the
+// bucket of their due time. Polls only read the buckets of a shard which are recorded as having items, and forget
+// the ones found empty once they are over, so that they do not read the tombstones of acknowledged items again.
+type Queue interface {
+	// Enqueue adds an item which can be polled from due on, or right away if due is zero. Enqueueing an id again
+	// while its item is queued adds another item if due is another.
+	Enqueue(id string, payload []byte, due time.Time) Op
+	// Poll claims up to n items which are due, starting from a random shard, and hides them from other polls for
+	// visibilityTimeout. Items which are not acknowledged in time are polled again.
+	Poll(n int, visibilityTimeout time.Duration) ([]QueueItem, error)
+	// Ack removes a polled item. It fails with a NotAppliedError if the item has been polled again since.
+	Ack(item QueueItem) Op
+	// Nack makes a polled item visible again after delay. It fails with a NotAppliedError if the item has been polled
+	// again since.
+	Nack(item QueueItem, delay time.Duration) Op
+	// WithClock returns the queue reading the time from now, which otherwise is time.Now
+	WithClock(now func() time.Time) Queue
+	WithOptions(Options) Queue
+	TableChanger
+}
+
 //
 // Raw CQL
 //
@@ -125,12 +292,25 @@ type MultiTimeSeriesTable interface {
 type Filter interface {
 	// Updates does a partial update. Use this if you don't want to overwrite your whole row, but you want to modify fields atomically.
 	Update(m map[string]interface{}) Op // Probably this is danger zone (can't be implemented efficiently) on a selectuinb with more than 1 document
-	// Delete all rows matching the filter.
+	// Delete all rows matching the filter. The clustering columns can be restricted by a range to delete a slice of
+	// a partition.
 	Delete() Op
+	// DeleteColumns deletes the given columns of the rows matching the filter, which has to restrict every
+	// clustering column by EQ or IN, unless all the columns are static.
+	DeleteColumns(columns ...string) Op
 	// Read the results. Make sure you pass in a pointer to a slice.
 	Read(pointerToASlice interface{}) Op
 	// Read one result. Make sure you pass in a pointer.
 	ReadOne(pointer interface{}) Op
+	// Count reads the number of rows matching the filter.
+	Count(count *int64) Op
+	// Distinct reads the partition keys of the partitions matching the filter, with the given static columns, into a
+	// slice. Only the partition keys can be restricted.
+	Distinct(pointerToASlice interface{}, staticColumns ...string) Op
+	// Aggregate computes aggregates over the rows matching the filter, eg. Max("Price"). The results are read into a
+	// struct or map by the names of the aggregates, or into a scalar if there is only one. With Options.GroupBy, they
+	// are computed per group and read into a slice, along with the columns the rows are grouped by.
+	Aggregate(pointer interface{}, aggregates ...Aggregate) Op
 }
 
 // Keys is used with the raw CQL Table type. It is implicit when using recipe tables.
@@ -138,6 +318,9 @@ type Keys struct {
 	PartitionKeys     []string
 	ClusteringColumns []string
 	Compound          bool //indicates if the partitions keys are gereated as compound key when no clustering columns are set
+	// StaticColumns are shared by all rows of a partition. They can be written with only the partition keys, eg. by
+	// a Set without the clustering columns or an Update filtered on the partition keys.
+	StaticColumns []string
 }
 
 // Op is returned by both read and write methods, you have to run them explicitly to take effect.
@@ -173,11 +356,13 @@ type Op interface {
 type TableChanger interface {
 	// Create creates the table in the keySpace, but only if it does not exist already.
 	// If the table already exists, it returns an error.
+	// User defined types used by the table are created beforehand if they do not exist.
 	Create() error
 	// CreateStatement returns you the CQL query which can be used to create the table manually in cqlsh
 	CreateStatement() (string, error)
 	// Create creates the table in the keySpace, but only if it does not exist already.
 	// If the table already exists, then nothing is created.
+	// User defined types used by the table are created beforehand if they do not exist.
 	CreateIfNotExist() error
 	// CreateStatement returns you the CQL query which can be used to create the table manually in cqlsh
 	CreateIfNotExistStatement() (string, error)
@@ -219,6 +404,11 @@ type TypeChanger interface {
 	// Recreate drops the type if exists and creates it again.
 	// This is useful for test purposes only.
 	Recreate() error
+	// Alter adds the fields which are missing from the existing type in the keySpace.
+	// Fields are never dropped or changed.
+	Alter() error
+	// AlterStatements returns you the CQL queries which add the fields missing from a type having the given fields
+	AlterStatements(existingFields []string) ([]string, error)
 	// Name returns the name of the type, as in C*
 	Name() string
 }
@@ -244,4 +434,23 @@ type QueryExecutor interface {
 	Close()
 }
 
+// CounterBatchExecutor is implemented by the QueryExecutors which can run counter updates atomically, in a counter
+// batch. Counter updates can't be part of a logged batch.
+type CounterBatchExecutor interface {
+	ExecuteCounterBatch(stmts []string, params [][]interface{}) error
+}
+
 type Counter int
+
+// Tuple is implemented by structs which are stored as CQL tuples rather than user defined types. The fields of the
+// struct, which must all be exported, are the elements of the tuple in declaration order. Fixed size arrays are
+// stored as tuples too.
+//
+//	type GeoPoint struct {
+//	    Lat, Lng float64
+//	}
+//
+//	func (GeoPoint) CQLTuple() {}
+type Tuple interface {
+	CQLTuple()
+}
@@ -3,8 +3,12 @@ package gocassa
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	r "github.com/gocassa/gocassa/reflect"
 )
 
 type typeFactory interface {
@@ -19,7 +23,8 @@ type k struct {
 	qe           QueryExecutor
 	name         string
 	debugMode    bool
-	types        map[string]string
+	types        map[string]string // UDT names by Go type
+	udts         map[string]Type   // UDTs by Go type
 	typeFactory  typeFactory
 	tableFactory tableFactory
 }
@@ -38,12 +43,63 @@ func (k *k) DebugMode(b bool) {
 }
 
 func (k *k) Type(name string, entity interface{}) Type {
+	typ, err := k.TypeE(name, entity)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+func (k *k) TypeE(name string, entity interface{}) (Type, error) {
 	m, ok := toMap(entity)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, entity)
+	}
+	goType := reflect.ValueOf(entity).Type().String()
+	k.types[goType] = name
+	typ := k.NewType(name, entity, m)
+	k.udts[goType] = typ
+	return typ, nil
+}
+
+// typesUsedBy returns the registered user defined types which the given values refer to, directly or through other
+// user defined types. Every type comes after the types it depends on, so they can be created in order.
+func (k *k) typesUsedBy(values []interface{}) []Type {
+	ret := []Type{}
+	visited := map[reflect.Type]bool{}
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		if t == nil || visited[t] {
+			return
+		}
+		visited[t] = true
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			visit(t.Elem())
+		case reflect.Map:
+			visit(t.Key())
+			visit(t.Elem())
+		case reflect.Struct:
+			typ, isUdt := k.udts[t.String()]
+			if !isUdt && !isTupleStruct(t) {
+				return
+			}
+			if m, ok := toMap(reflect.Zero(t).Interface()); ok {
+				fields, _ := keyValues(m)
+				sort.Strings(fields)
+				for _, f := range fields {
+					visit(reflect.TypeOf(m[f]))
+				}
+			}
+			if isUdt {
+				ret = append(ret, typ)
+			}
+		}
+	}
+	for _, v := range values {
+		visit(reflect.TypeOf(v))
 	}
-	k.types[reflect.ValueOf(entity).Type().String()] = name
-	return k.NewType(name, entity, m)
+	return ret
 }
 
 func (k *k) NewType(name string, entity interface{}, fields map[string]interface{}) Type {
@@ -61,12 +117,103 @@ func (k *k) NewType(name string, entity interface{}, fields map[string]interface
 }
 
 func (k *k) Table(name string, entity interface{}, keys Keys) Table {
+	tbl, err := k.TableE(name, entity, keys)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) TableE(name string, entity interface{}, keys Keys) (Table, error) {
 	n := name + "__" + strings.Join(keys.PartitionKeys, "_") + "__" + strings.Join(keys.ClusteringColumns, "_")
 	m, ok := toMap(entity)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, entity)
+	}
+	keyFields := append(append([]string{}, keys.PartitionKeys...), keys.ClusteringColumns...)
+	if err := validateKeyFields(name, entity, m, append(keyFields, keys.StaticColumns...)...); err != nil {
+		return nil, err
 	}
-	return k.NewTable(n, entity, m, keys)
+	return k.NewTable(n, entity, m, keys), nil
+}
+
+func (k *k) TableFromTags(name string, entity interface{}) Table {
+	tbl, err := k.TableFromTagsE(name, entity)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) TableFromTagsE(name string, entity interface{}) (Table, error) {
+	pk, err := r.PrimaryKeyOf(entity)
+	if err != nil {
+		return nil, ValidationError{Table: name, Reason: err.Error()}
+	}
+	if len(pk.PartitionKeys) == 0 {
+		return nil, ValidationError{Table: name, Reason: fmt.Sprintf("no partition key declared in the tags of %T", entity)}
+	}
+	tbl, err := k.TableE(name, entity, Keys{
+		PartitionKeys:     pk.PartitionKeys,
+		ClusteringColumns: pk.ClusteringColumns,
+		StaticColumns:     pk.StaticColumns,
+	})
+	if err != nil || len(pk.Descending) == 0 {
+		return tbl, err
+	}
+	// A clustering order has to list the clustering columns in order, so list the ascending ones too
+	opts := Options{}
+	for _, c := range pk.ClusteringColumns {
+		dir := ASC
+		if pk.Descending[c] {
+			dir = DESC
+		}
+		opts = opts.AppendClusteringOrder(c, dir)
+	}
+	return tbl.WithOptions(opts), nil
+}
+
+// validateKeyFields checks that the named key fields are fields of the row, named by their column or their Go field
+// name in any case, as Cassandra does not tell them apart
+func validateKeyFields(table string, entity interface{}, fields map[string]interface{}, keyFields ...string) error {
+	for _, f := range keyFields {
+		if !isKeyField(entity, fields, f) {
+			return ValidationError{Table: table, Field: f, Reason: fmt.Sprintf("not a field of %T", entity)}
+		}
+	}
+	return nil
+}
+
+// isKeyField tells whether name is a column of fields, or the Go name of one of the fields of entity, in any case
+func isKeyField(entity interface{}, fields map[string]interface{}, name string) bool {
+	for column := range fields {
+		if strings.EqualFold(column, name) {
+			return true
+		}
+	}
+	_, ok := keyFieldIndex(reflect.TypeOf(entity), name)
+	return ok
+}
+
+// keyFieldIndex returns the index of the field of the struct type t, or of the struct t points to, named name by its
+// column or its Go field name in any case
+func keyFieldIndex(t reflect.Type, name string) ([]int, bool) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	idx := r.FieldIndexes(t)
+	if index, ok := idx[strings.ToLower(name)]; ok {
+		return index, true
+	}
+	for _, index := range idx {
+		if strings.EqualFold(t.FieldByIndex(index).Name, name) {
+			return index, true
+		}
+	}
+	return nil, false
 }
 
 func (k *k) NewTable(name string, entity interface{}, fields map[string]interface{}, keys Keys) Table {
@@ -85,16 +232,27 @@ func (k *k) NewTable(name string, entity interface{}, fields map[string]interfac
 }
 
 func (k *k) MapTable(name, id string, row interface{}) MapTable {
+	tbl, err := k.MapTableE(name, id, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) MapTableE(name, id string, row interface{}) (MapTable, error) {
 	m, ok := toMap(row)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, id); err != nil {
+		return nil, err
 	}
 	return &mapT{
 		Table: k.NewTable(fmt.Sprintf("%s_map_%s", name, id), row, m, Keys{
 			PartitionKeys: []string{id},
 		}),
 		idField: id,
-	}
+	}, nil
 }
 
 func (k *k) SetKeysSpaceName(name string) {
@@ -102,9 +260,20 @@ func (k *k) SetKeysSpaceName(name string) {
 }
 
 func (k *k) MultimapTable(name, fieldToIndexBy, id string, row interface{}) MultimapTable {
+	tbl, err := k.MultimapTableE(name, fieldToIndexBy, id, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) MultimapTableE(name, fieldToIndexBy, id string, row interface{}) (MultimapTable, error) {
 	m, ok := toMap(row)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, fieldToIndexBy, id); err != nil {
+		return nil, err
 	}
 	return &multimapT{
 		Table: k.NewTable(fmt.Sprintf("%s_multimap_%s_%s", name, fieldToIndexBy, id), row, m, Keys{
@@ -113,13 +282,61 @@ func (k *k) MultimapTable(name, fieldToIndexBy, id string, row interface{}) Mult
 		}),
 		idField:        id,
 		fieldToIndexBy: fieldToIndexBy,
+	}, nil
+}
+
+func (k *k) ShardedMultimapTable(name, fieldToIndexBy, id string, shards int, row interface{}) MultimapTable {
+	tbl, err := k.ShardedMultimapTableE(name, fieldToIndexBy, id, shards, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) ShardedMultimapTableE(name, fieldToIndexBy, id string, shards int, row interface{}) (MultimapTable, error) {
+	m, ok := toMap(row)
+	if !ok {
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, fieldToIndexBy, id); err != nil {
+		return nil, err
+	}
+	if shards < 1 {
+		return nil, ValidationError{Table: name, Reason: "a sharded multimap needs at least one shard"}
+	}
+	for f := range m {
+		if isKey(f, []string{shardFieldName}) {
+			return nil, ValidationError{Table: name, Field: f, Reason: fmt.Sprintf("%s is the column of the shards of a sharded multimap", shardFieldName)}
+		}
 	}
+	m[shardFieldName] = 0
+	return &multimapT{
+		Table: k.NewTable(fmt.Sprintf("%s_multimap_%s_%s_%dshards", name, fieldToIndexBy, id, shards), row, m, Keys{
+			PartitionKeys:     []string{fieldToIndexBy, shardFieldName},
+			ClusteringColumns: []string{id},
+		}),
+		idField:        id,
+		fieldToIndexBy: fieldToIndexBy,
+		shards:         shards,
+		idType:         cassaType(m[id]),
+	}, nil
 }
 
 func (k *k) MultimapMultiKeyTable(name string, fieldToIndexBy, id []string, row interface{}) MultimapMkTable {
+	tbl, err := k.MultimapMultiKeyTableE(name, fieldToIndexBy, id, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) MultimapMultiKeyTableE(name string, fieldToIndexBy, id []string, row interface{}) (MultimapMkTable, error) {
 	m, ok := toMap(row)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, append(append([]string{}, fieldToIndexBy...), id...)...); err != nil {
+		return nil, err
 	}
 	return &multimapMkT{
 		Table: k.NewTable(fmt.Sprintf("%s_multimapMk", name), row, m, Keys{
@@ -128,34 +345,72 @@ func (k *k) MultimapMultiKeyTable(name string, fieldToIndexBy, id []string, row
 		}),
 		idField:         id,
 		fieldsToIndexBy: fieldToIndexBy,
-	}
+	}, nil
 }
 
 func (k *k) TimeSeriesTable(name, timeField, idField string, bucketSize time.Duration, row interface{}) TimeSeriesTable {
+	tbl, err := k.TimeSeriesTableE(name, timeField, idField, bucketSize, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) TimeSeriesTableE(name, timeField, idField string, bucketSize time.Duration, row interface{}) (TimeSeriesTable, error) {
+	return k.FlexTimeSeriesTableE(name, timeField, idField, &tsBucketer{bucketSize: bucketSize}, row)
+}
+
+func (k *k) FlexTimeSeriesTable(name, timeField, idField string, bucketer Bucketer, row interface{}) TimeSeriesTable {
+	tbl, err := k.FlexTimeSeriesTableE(name, timeField, idField, bucketer, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) FlexTimeSeriesTableE(name, timeField, idField string, bucketer Bucketer, row interface{}) (TimeSeriesTable, error) {
 	m, ok := toMap(row)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, timeField, idField); err != nil {
+		return nil, err
 	}
 	m[bucketFieldName] = time.Now()
 	return &timeSeriesT{
-		Table: k.NewTable(fmt.Sprintf("%s_timeSeries_%s_%s_%s", name, timeField, idField, bucketSize), row, m, Keys{
+		Table: k.NewTable(fmt.Sprintf("%s_timeSeries_%s_%s_%s", name, timeField, idField, bucketer.String()), row, m, Keys{
 			PartitionKeys:     []string{bucketFieldName},
 			ClusteringColumns: []string{timeField, idField},
 		}),
-		timeField:  timeField,
-		idField:    idField,
-		bucketSize: bucketSize,
-	}
+		timeField: timeField,
+		idField:   idField,
+		bucketer:  bucketer,
+	}, nil
 }
 
 func (k *k) MultiTimeSeriesTable(name, indexField, timeField, idField string, bucketSize time.Duration, row interface{}) MultiTimeSeriesTable {
 	return k.FlexMultiTimeSeriesTable(name, timeField, idField, []string{indexField}, &tsBucketer{bucketSize: bucketSize}, row)
 }
 
+func (k *k) MultiTimeSeriesTableE(name, indexField, timeField, idField string, bucketSize time.Duration, row interface{}) (MultiTimeSeriesTable, error) {
+	return k.FlexMultiTimeSeriesTableE(name, timeField, idField, []string{indexField}, &tsBucketer{bucketSize: bucketSize}, row)
+}
+
 func (k *k) FlexMultiTimeSeriesTable(name, timeField, idField string, indexFields []string, bucketer Bucketer, row interface{}) MultiTimeSeriesTable {
+	tbl, err := k.FlexMultiTimeSeriesTableE(name, timeField, idField, indexFields, bucketer, row)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+func (k *k) FlexMultiTimeSeriesTableE(name, timeField, idField string, indexFields []string, bucketer Bucketer, row interface{}) (MultiTimeSeriesTable, error) {
 	m, ok := toMap(row)
 	if !ok {
-		panic("Unrecognized row type")
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, append([]string{timeField, idField}, indexFields...)...); err != nil {
+		return nil, err
 	}
 	m[bucketFieldName] = time.Now()
 	pk := append([]string{}, indexFields...)
@@ -169,7 +424,197 @@ func (k *k) FlexMultiTimeSeriesTable(name, timeField, idField string, indexField
 		timeField:   timeField,
 		idField:     idField,
 		bucketer:    bucketer,
+	}, nil
+}
+
+func (k *k) CounterTable(name string, keyFields []string, row interface{}, counterFields ...string) CounterTable {
+	tbl, err := k.CounterTableE(name, keyFields, row, counterFields...)
+	if err != nil {
+		panic(err)
+	}
+	return tbl
+}
+
+// CounterTableE creates a table of the counterFields of row, or of all the fields which are not key fields if none
+// are given. Counter tables can only have counter columns besides their keys, so the counter fields have to be
+// Counters.
+func (k *k) CounterTableE(name string, keyFields []string, row interface{}, counterFields ...string) (CounterTable, error) {
+	m, ok := toMap(row)
+	if !ok {
+		return nil, unrecognizedRowError(name, row)
+	}
+	if len(keyFields) == 0 {
+		return nil, ValidationError{Table: name, Reason: "a counter table needs at least one key field"}
+	}
+	if err := validateKeyFields(name, row, m, append(append([]string{}, keyFields...), counterFields...)...); err != nil {
+		return nil, err
+	}
+	if len(counterFields) == 0 {
+		for f := range m {
+			if !isKey(f, keyFields) {
+				counterFields = append(counterFields, f)
+			}
+		}
+		sort.Strings(counterFields)
+	}
+	if len(counterFields) == 0 {
+		return nil, ValidationError{Table: name, Reason: "a counter table needs at least one counter field"}
+	}
+	fields := map[string]interface{}{}
+	for _, f := range keyFields {
+		fields[f] = m[f]
+	}
+	for _, f := range counterFields {
+		if isKey(f, keyFields) {
+			return nil, ValidationError{Table: name, Field: f, Reason: "a key field can not be a counter"}
+		}
+		if _, ok := m[f].(Counter); !ok {
+			return nil, ValidationError{Table: name, Field: f, Reason: fmt.Sprintf("counter tables can only have counter columns, not %T", m[f])}
+		}
+		fields[f] = m[f]
+	}
+	return &counterT{
+		Table: k.NewTable(fmt.Sprintf("%s_counter_%s", name, strings.Join(keyFields, "_")), row, fields, Keys{
+			PartitionKeys: keyFields,
+		}),
+		keyFields:     keyFields,
+		counterFields: counterFields,
+	}, nil
+}
+
+func (k *k) UniqueIndex(name, field, ownerField string, row interface{}) UniqueIndex {
+	idx, err := k.UniqueIndexE(name, field, ownerField, row)
+	if err != nil {
+		panic(err)
+	}
+	return idx
+}
+
+// UniqueIndexE creates the table recording the owner of each value of field, identified by its ownerField
+func (k *k) UniqueIndexE(name, field, ownerField string, row interface{}) (UniqueIndex, error) {
+	m, ok := toMap(row)
+	if !ok {
+		return nil, unrecognizedRowError(name, row)
+	}
+	if err := validateKeyFields(name, row, m, field, ownerField); err != nil {
+		return nil, err
+	}
+	if field == ownerField {
+		return nil, ValidationError{Table: name, Field: field, Reason: "the owner field has to be another field"}
+	}
+	return &uniqueT{
+		Table: k.NewTable(fmt.Sprintf("%s_unique_%s", name, field), row, map[string]interface{}{
+			field:      m[field],
+			ownerField: m[ownerField],
+		}, Keys{
+			PartitionKeys: []string{field},
+		}),
+		field:      field,
+		ownerField: ownerField,
+	}, nil
+}
+
+// LeaseTable creates the table of the leases on names
+func (k *k) LeaseTable(name string) LeaseTable {
+	row := Lease{}
+	m, _ := toMap(row)
+	return &leaseT{
+		Table: k.NewTable(fmt.Sprintf("%s_lease", name), row, m, Keys{
+			PartitionKeys: []string{"Name"},
+		}),
+		now: time.Now,
+	}
+}
+
+// EventStream creates the tables of the events of aggregates, their versions and their snapshots. If bucketSize is
+// not 0, the events of an aggregate are stored in partitions of bucketSize versions.
+func (k *k) EventStream(name string, bucketSize int64) EventStream {
+	newTable := func(suffix string, row interface{}, keys Keys) Table {
+		m, _ := toMap(row)
+		return k.NewTable(fmt.Sprintf("%s_%s", name, suffix), row, m, keys)
+	}
+	return &eventStreamT{
+		events: newTable("events", eventRow{}, Keys{
+			PartitionKeys:     []string{"AggregateId", "Bucket"},
+			ClusteringColumns: []string{"Version"},
+		}),
+		versions: newTable("eventVersions", streamVersion{}, Keys{
+			PartitionKeys: []string{"AggregateId"},
+		}),
+		snapshots: newTable("eventSnapshots", Snapshot{}, Keys{
+			PartitionKeys:     []string{"AggregateId"},
+			ClusteringColumns: []string{"Version"},
+		}).WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{DESC, "Version"}}}),
+		bucketSize: bucketSize,
+	}
+}
+
+// Queue creates the tables of a queue of work items, of the buckets holding them and of the lowest bucket of each shard
+// which may have items. A shards value under 1 means a single shard.
+func (k *k) Queue(name string, shards int, bucketSize time.Duration) Queue {
+	q, err := k.QueueE(name, shards, bucketSize)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (k *k) QueueE(name string, shards int, bucketSize time.Duration) (Queue, error) {
+	if bucketSize < time.Second {
+		return nil, ValidationError{Table: name, Reason: fmt.Sprintf("the bucket size of a queue has to be at least a second, not %v", bucketSize)}
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	newTable := func(suffix string, row interface{}, keys Keys) Table {
+		m, _ := toMap(row)
+		return k.NewTable(fmt.Sprintf("%s_%s", name, suffix), row, m, keys)
+	}
+	return &queueT{
+		items: newTable("queue", QueueItem{}, Keys{
+			PartitionKeys:     []string{"Shard", "Bucket"},
+			ClusteringColumns: []string{"Due", "Id"},
+		}),
+		buckets: newTable("queueBuckets", queueBucket{}, Keys{
+			PartitionKeys:     []string{"Shard"},
+			ClusteringColumns: []string{"Bucket"},
+		}),
+		marks: newTable("queueShards", queueShard{}, Keys{
+			PartitionKeys: []string{"Shard"},
+		}),
+		shards:   shards,
+		bucketer: &tsBucketer{bucketSize: bucketSize},
+		now:      time.Now,
+		low:      &sync.Map{},
+	}, nil
+}
+
+// createTypes creates, if they do not exist yet, the user defined types which the given values refer to
+func (k *k) createTypes(values []interface{}) error {
+	for _, typ := range k.typesUsedBy(values) {
+		stmt, err := typ.CreateIfNotExistStatement()
+		if err != nil {
+			return err
+		}
+		if err := k.qe.Execute(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeFields returns the field names of a type as it exists in the keyspace
+func (k *k) typeFields(udt string) ([]string, error) {
+	const stmt = "SELECT field_names FROM system_schema.types WHERE keyspace_name = ? AND type_name = ?"
+	maps, err := k.qe.Query(stmt, k.name, strings.ToLower(udt))
+	if err != nil {
+		return nil, err
+	}
+	if len(maps) == 0 {
+		return nil, fmt.Errorf("type %s does not exist in keyspace %s", udt, k.name)
 	}
+	fields, _ := maps[0]["field_names"].([]string)
+	return fields, nil
 }
 
 // Types returns type names in a keyspace
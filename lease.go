@@ -0,0 +1,198 @@
+package gocassa
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Lease is a lease on a name, held by a holder until it expires
+type Lease struct {
+	Name   string
+	Holder string
+	// Token is the fencing token of the lease. Every acquisition of a name gets a greater token than the ones before,
+	// so that the systems a holder writes to can turn away the writes of holders which have lost the lease since.
+	Token   int64
+	Expires time.Time
+}
+
+// leaseCheckInterval is the longest Keep waits before looking whether a renewal is due
+const leaseCheckInterval = 100 * time.Millisecond
+
+const (
+	leaseHolderField  = "Holder"
+	leaseTokenField   = "Token"
+	leaseExpiresField = "Expires"
+)
+
+type leaseT struct {
+	Table
+	now func() time.Time
+}
+
+func (l *leaseT) Acquire(name, holder string, ttl time.Duration) (Lease, error) {
+	if err := l.validate(holder, ttl); err != nil {
+		return Lease{}, err
+	}
+	current, err := l.read(name)
+	if errors.Is(err, ErrNotFound) {
+		// The token outlives the leases, so the row holding it is created once, without a TTL
+		err = l.Set(Lease{Name: name}).WithOptions(Options{IfNotExists: true}).Run()
+		if err != nil && !errors.Is(err, ErrNotApplied) {
+			return Lease{}, err
+		}
+		current, err = l.read(name)
+	}
+	if err != nil {
+		return Lease{}, err
+	}
+	if current.Holder != "" && current.Holder != holder {
+		return Lease{}, NotAppliedError{Table: l.Name(), Current: leaseColumns(current)}
+	}
+
+	// Taking the next token is conditional on nobody else having taken it, and the holder is then written with the
+	// TTL of the lease by a renewal
+	lease := Lease{Name: name, Holder: holder, Token: current.Token + 1, Expires: l.now().Add(ttl)}
+	err = l.Where(Eq("Name", name)).Update(map[string]interface{}{
+		leaseHolderField:  lease.Holder,
+		leaseTokenField:   lease.Token,
+		leaseExpiresField: lease.Expires,
+	}).WithOptions(Options{Conditions: []Relation{Eq(leaseTokenField, current.Token)}}).Run()
+	if err != nil {
+		return Lease{}, err
+	}
+	return l.Renew(lease, ttl)
+}
+
+func (l *leaseT) Renew(lease Lease, ttl time.Duration) (Lease, error) {
+	if err := l.validate(lease.Holder, ttl); err != nil {
+		return Lease{}, err
+	}
+	now := l.now()
+	if !lease.Expires.After(now) {
+		return Lease{}, NotAppliedError{Table: l.Name()}
+	}
+	lease.Expires = now.Add(ttl)
+	err := l.Where(Eq("Name", lease.Name)).Update(map[string]interface{}{
+		leaseHolderField:  lease.Holder,
+		leaseExpiresField: lease.Expires,
+	}).WithOptions(Options{TTL: ttl, Conditions: l.heldBy(lease)}).Run()
+	if err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+func (l *leaseT) Release(lease Lease) error {
+	err := l.Where(Eq("Name", lease.Name)).
+		DeleteColumns(leaseHolderField, leaseExpiresField).
+		WithOptions(Options{Conditions: l.heldBy(lease)}).
+		Run()
+	if errors.Is(err, ErrNotApplied) {
+		return nil
+	}
+	return err
+}
+
+func (l *leaseT) Read(name string) (Lease, error) {
+	lease, err := l.read(name)
+	if errors.Is(err, ErrNotFound) {
+		return Lease{Name: name}, nil
+	}
+	return lease, err
+}
+
+func (l *leaseT) Keep(ctx context.Context, lease Lease, ttl time.Duration) (context.Context, func()) {
+	kept, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	due := l.now().Add(ttl / 3)
+	go func() {
+		defer close(done)
+		defer cancel()
+		// The clock of the table decides when renewals are due, and the ticker only says when to look at it
+		check := ttl / 3
+		if check > leaseCheckInterval {
+			check = leaseCheckInterval
+		}
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				now := l.now()
+				if now.Before(due) {
+					continue
+				}
+				renewed, err := l.Renew(lease, ttl)
+				if errors.Is(err, ErrNotApplied) || errors.Is(err, ErrValidation) {
+					return
+				} else if err != nil {
+					// Renewals which fail otherwise are tried again while the lease may still be held
+					if !lease.Expires.After(now) {
+						return
+					}
+					continue
+				}
+				lease = renewed
+				due = now.Add(ttl / 3)
+			case <-stopped:
+				l.Release(lease)
+				return
+			case <-kept.Done():
+				return
+			}
+		}
+	}()
+	return kept, func() {
+		select {
+		case <-done:
+		case stopped <- struct{}{}:
+			<-done
+		}
+	}
+}
+
+func (l *leaseT) WithClock(now func() time.Time) LeaseTable {
+	return &leaseT{Table: l.Table, now: now}
+}
+
+func (l *leaseT) WithOptions(o Options) LeaseTable {
+	return &leaseT{Table: l.Table.WithOptions(o), now: l.now}
+}
+
+// read reads the lease on name, without its holder if it has expired
+func (l *leaseT) read(name string) (Lease, error) {
+	lease := Lease{}
+	if err := l.Where(Eq("Name", name)).ReadOne(&lease).Run(); err != nil {
+		return Lease{}, err
+	}
+	if !lease.Expires.After(l.now()) {
+		lease.Holder, lease.Expires = "", time.Time{}
+	}
+	return lease, nil
+}
+
+// heldBy returns the conditions of the writes which only apply while lease is held
+func (l *leaseT) heldBy(lease Lease) []Relation {
+	return []Relation{Eq(leaseHolderField, lease.Holder), Eq(leaseTokenField, lease.Token)}
+}
+
+func (l *leaseT) validate(holder string, ttl time.Duration) error {
+	if holder == "" {
+		return ValidationError{Table: l.Name(), Field: leaseHolderField, Reason: "the holder of a lease can not be empty"}
+	}
+	if ttl < time.Second {
+		return ValidationError{Table: l.Name(), Reason: "the TTL of a lease has to be at least a second"}
+	}
+	return nil
+}
+
+func leaseColumns(lease Lease) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":            lease.Name,
+		leaseHolderField:  lease.Holder,
+		leaseTokenField:   lease.Token,
+		leaseExpiresField: lease.Expires,
+	}
+}
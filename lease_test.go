@@ -0,0 +1,227 @@
+package gocassa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClock is a clock which only moves when it is told to
+type testClock struct {
+	mtx sync.Mutex
+	t   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.t
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestLeaseTable(t *testing.T) {
+	clock := &testClock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	leases := NewMockKeySpace().LeaseTable("jobs").WithClock(clock.Now)
+
+	lease, err := leases.Acquire("report", "pod-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Holder != "pod-1" || lease.Token != 1 || !lease.Expires.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("unexpected lease %+v", lease)
+	}
+	if _, err := leases.Acquire("report", "pod-2", time.Minute); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a held lease not to be acquired, got %v", err)
+	}
+	if current, err := leases.Read("report"); err != nil || current != lease {
+		t.Fatalf("expected %+v, got %+v, %v", lease, current, err)
+	}
+
+	clock.Advance(30 * time.Second)
+	if lease, err = leases.Renew(lease, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(45 * time.Second)
+	if _, err := leases.Acquire("report", "pod-2", time.Minute); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a renewed lease not to be acquired, got %v", err)
+	}
+
+	// Once the lease expires, another holder acquires it with a greater token
+	clock.Advance(time.Minute)
+	stolen, err := leases.Acquire("report", "pod-2", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stolen.Token != 2 {
+		t.Fatalf("expected the token to increase, got %+v", stolen)
+	}
+	if _, err := leases.Renew(lease, time.Minute); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected an expired lease not to be renewed, got %v", err)
+	}
+	lease.Expires = clock.Now().Add(time.Minute)
+	if _, err := leases.Renew(lease, time.Minute); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a lost lease not to be renewed, got %v", err)
+	}
+	if err := leases.Release(lease); err != nil {
+		t.Fatalf("expected releasing a lost lease to do nothing, got %v", err)
+	}
+	if current, _ := leases.Read("report"); current.Holder != "pod-2" {
+		t.Fatalf("expected pod-2 to hold the lease, got %+v", current)
+	}
+
+	if err := leases.Release(stolen); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := leases.Read("report"); current.Holder != "" || current.Token != 2 {
+		t.Fatalf("expected the lease to be released and to keep its token, got %+v", current)
+	}
+	if lease, err = leases.Acquire("report", "pod-1", time.Minute); err != nil || lease.Token != 3 {
+		t.Fatalf("expected the released lease to be acquired with token 3, got %+v, %v", lease, err)
+	}
+
+	if _, err := leases.Acquire("report", "pod-1", time.Millisecond); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a TTL under a second, got %v", err)
+	}
+}
+
+func TestLeaseTableKeep(t *testing.T) {
+	clock := &testClock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	leases := NewMockKeySpace().LeaseTable("jobs").WithClock(clock.Now)
+	lease, err := leases.Acquire("report", "pod-1", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := leases.Keep(context.Background(), lease, time.Second)
+	defer stop()
+
+	// The lease is lost when another holder acquires it once it expires
+	clock.Advance(2 * time.Second)
+	if _, err := leases.Acquire("report", "pod-2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the context to be cancelled when the lease is lost")
+	}
+
+	// Stopping releases the lease
+	lease, err = leases.Acquire("other", "pod-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop = leases.Keep(context.Background(), lease, time.Minute)
+	stop()
+	if ctx.Err() == nil {
+		t.Fatal("expected the context to be cancelled once stopped")
+	}
+	if current, _ := leases.Read("other"); current.Holder != "" {
+		t.Fatalf("expected the lease to be released, got %+v", current)
+	}
+	stop()
+}
+
+// flakyTable fails the updates of its rows while failures is positive
+type flakyTable struct {
+	Table
+	failures *int32
+}
+
+func (f flakyTable) Where(relations ...Relation) Filter {
+	return flakyFilter{Filter: f.Table.Where(relations...), failures: f.failures}
+}
+
+type flakyFilter struct {
+	Filter
+	failures *int32
+}
+
+func (f flakyFilter) Update(m map[string]interface{}) Op {
+	if atomic.LoadInt32(f.failures) > 0 {
+		atomic.AddInt32(f.failures, -1)
+		return &badOp{errors.New("write failed")}
+	}
+	return f.Filter.Update(m)
+}
+
+// waitFor waits for cond to hold, and fails the test if it does not in a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s", what)
+		}
+	}
+}
+
+func TestLeaseTableKeepClock(t *testing.T) {
+	clock := &testClock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	table := NewMockKeySpace().LeaseTable("jobs").(*leaseT)
+	failures := int32(0)
+	leases := &leaseT{Table: flakyTable{Table: table.Table, failures: &failures}, now: clock.Now}
+	lease, err := leases.Acquire("report", "pod-1", 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := leases.Keep(context.Background(), lease, 3*time.Second)
+	defer stop()
+
+	// Renewals follow the clock of the table rather than the wall clock
+	time.Sleep(3 * leaseCheckInterval)
+	if current, _ := leases.Read("report"); !current.Expires.Equal(lease.Expires) {
+		t.Fatalf("expected no renewal before the clock moves, got %+v", current)
+	}
+	clock.Advance(time.Second)
+	waitFor(t, "the lease to be renewed", func() bool {
+		current, _ := leases.Read("report")
+		return current.Expires.Equal(clock.Now().Add(3 * time.Second))
+	})
+
+	// Failed renewals are retried while the lease is held
+	atomic.StoreInt32(&failures, 3)
+	clock.Advance(time.Second)
+	waitFor(t, "the lease to be renewed after the failures", func() bool {
+		current, _ := leases.Read("report")
+		return atomic.LoadInt32(&failures) == 0 && current.Expires.Equal(clock.Now().Add(3*time.Second))
+	})
+	if ctx.Err() != nil {
+		t.Fatal("expected the lease to be kept through failed renewals")
+	}
+
+	// and given up on once it has expired
+	atomic.StoreInt32(&failures, 1<<30)
+	clock.Advance(5 * time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the context to be cancelled once the lease expired")
+	}
+}
+
+func TestLeaseTableStatements(t *testing.T) {
+	qe := lwtQE{StatementRecordingQE: newStatementRecordingQE(), result: map[string]interface{}{"[applied]": true}}
+	leases := (&connection{q: qe}).KeySpace("some_ks").LeaseTable("jobs")
+	lease := Lease{Name: "report", Holder: "pod-1", Token: 3, Expires: time.Now().Add(time.Minute)}
+
+	if _, err := leases.Renew(lease, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	stmt := (*qe.stmts)[0]
+	if !strings.HasPrefix(stmt, "UPDATE some_ks.jobs_lease USING TTL 60 SET ") || !strings.HasSuffix(stmt, " WHERE name = ? IF holder = ? AND token = ?") {
+		t.Fatalf("unexpected renewal %q", stmt)
+	}
+	if err := leases.Release(lease); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "DELETE holder, expires FROM some_ks.jobs_lease WHERE name = ? IF holder = ? AND token = ?"; (*qe.stmts)[1] != expected {
+		t.Fatalf("expected %q, got %q", expected, (*qe.stmts)[1])
+	}
+}
@@ -2,13 +2,20 @@ package gocassa
 
 import (
 	"bytes"
-	"errors"
+	"encoding/binary"
 	"fmt"
+	"math/big"
+	"net"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	r "github.com/gocassa/gocassa/reflect"
 	"github.com/gocql/gocql"
 	"github.com/google/btree"
+	"gopkg.in/inf.v0"
 )
 
 // MockKeySpace implements the KeySpace interface and constructs in-memory tables.
@@ -17,9 +24,9 @@ type mockKeySpace struct {
 }
 
 type mockOp struct {
-	options      Options
-	funcs        []func(mockOp) error
-	preflightErr error
+	options   Options
+	funcs     []func(mockOp) error
+	preflight func(mockOp) error
 }
 
 func newOp(f func(mockOp) error) mockOp {
@@ -33,6 +40,9 @@ func (m mockOp) Add(ops ...Op) Op {
 }
 
 func (m mockOp) Run() error {
+	if err := m.Preflight(); err != nil {
+		return err
+	}
 	for _, f := range m.funcs {
 		err := f(m)
 		if err != nil {
@@ -44,8 +54,9 @@ func (m mockOp) Run() error {
 
 func (m mockOp) WithOptions(opt Options) Op {
 	return mockOp{
-		options: opt,
-		funcs:   m.funcs,
+		options:   opt,
+		funcs:     m.funcs,
+		preflight: m.preflight,
 	}
 }
 
@@ -62,24 +73,75 @@ func (m mockOp) QueryExecutor() QueryExecutor {
 }
 
 func (m mockOp) Preflight() error {
-	return m.preflightErr
+	if m.preflight == nil {
+		return nil
+	}
+	return m.preflight(m)
 }
 
 func (ks *mockKeySpace) NewTable(name string, entity interface{}, fields map[string]interface{}, keys Keys) Table {
+	fieldTypes, _ := r.FieldTypes(entity)
 	return &MockTable{
-		name:   name,
-		entity: entity,
-		keys:   keys,
-		rows:   map[rowKey]*btree.BTree{},
+		name:       name,
+		entity:     entity,
+		keys:       keys,
+		rows:       map[rowKey]*btree.BTree{},
+		statics:    map[rowKey]map[string]interface{}{},
+		fieldTypes: fieldTypes,
 	}
 }
 
+func (ks *mockKeySpace) NewType(name string, entity interface{}, fields map[string]interface{}) Type {
+	return &mockType{name: name}
+}
+
 func NewMockKeySpace() KeySpace {
 	ks := &mockKeySpace{}
 	ks.tableFactory = ks
+	ks.typeFactory = ks
+	ks.types = map[string]string{}
+	ks.udts = map[string]Type{}
 	return ks
 }
 
+// mockType implements the Type interface. Values of user defined types are stored as they are by MockTable, so
+// there is nothing to create.
+type mockType struct {
+	name string
+}
+
+func (t *mockType) Create() error {
+	return nil
+}
+
+func (t *mockType) CreateStatement() (string, error) {
+	return "", nil
+}
+
+func (t *mockType) CreateIfNotExist() error {
+	return nil
+}
+
+func (t *mockType) CreateIfNotExistStatement() (string, error) {
+	return "", nil
+}
+
+func (t *mockType) Recreate() error {
+	return nil
+}
+
+func (t *mockType) Alter() error {
+	return nil
+}
+
+func (t *mockType) AlterStatements(existingFields []string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (t *mockType) Name() string {
+	return t.name
+}
+
 // MockTable implements the Table interface and stores rows in-memory.
 type MockTable struct {
 	sync.RWMutex
@@ -88,9 +150,13 @@ type MockTable struct {
 	mtx     sync.RWMutex
 	name    string
 	rows    map[rowKey]*btree.BTree
+	// statics is mapping from row key to the static columns of the partition
+	statics map[rowKey]map[string]interface{}
 	entity  interface{}
 	keys    Keys
 	options Options
+	// fieldTypes are the CQL types declared in the entity's struct tags
+	fieldTypes map[string]string
 }
 
 type rowKey string
@@ -132,25 +198,210 @@ func (t gocqlTypeInfo) Custom() string {
 type keyPart struct {
 	Key   string
 	Value interface{}
+	// Type is the CQL type declared for the column, or gocql.TypeCustom if it is derived from Value
+	Type gocql.Type
 }
 
 func (k *keyPart) Bytes() []byte {
-	typeInfo := &gocqlTypeInfo{
-		proto: 0x03,
-		typ:   cassaType(k.Value),
+	return keyBytes(k.Type, k.Value)
+}
+
+// keyBytes serialises a key value. Native types use their CQL serialisation, while collections, tuples and user
+// defined types are serialised element by element, with map entries sorted, so that equal values always produce
+// the same bytes.
+func keyBytes(typ gocql.Type, value interface{}) []byte {
+	value = storedValue(value)
+	if typ == gocql.TypeCustom {
+		typ = cassaType(value)
 	}
-	marshalled, err := gocql.Marshal(typeInfo, k.Value)
-	if err != nil {
-		panic(err)
+	if typ != gocql.TypeCustom {
+		typeInfo := &gocqlTypeInfo{
+			proto: 0x03,
+			typ:   typ,
+		}
+		marshalled, err := gocql.Marshal(typeInfo, value)
+		if err != nil {
+			panic(err)
+		}
+		return marshalled
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(value))
+	elems := [][]byte{}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, keyBytes(gocql.TypeCustom, v.Index(i).Interface()))
+		}
+	case reflect.Map:
+		for _, mk := range v.MapKeys() {
+			elems = append(elems, append(keyBytes(gocql.TypeCustom, mk.Interface()),
+				keyBytes(gocql.TypeCustom, v.MapIndex(mk).Interface())...))
+		}
+		sort.Slice(elems, func(i, j int) bool { return bytes.Compare(elems[i], elems[j]) < 0 })
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				elems = append(elems, keyBytes(gocql.TypeCustom, v.Field(i).Interface()))
+			}
+		}
+	default:
+		panic(fmt.Sprintf("can not use %T as a key", value))
 	}
-	return marshalled
+
+	buf := bytes.Buffer{}
+	for _, elem := range elems {
+		binary.Write(&buf, binary.BigEndian, int32(len(elem)))
+		buf.Write(elem)
+	}
+	return buf.Bytes()
 }
 
 type key []keyPart
 
+// Compare orders two key parts the way Cassandra orders values of the column type, falling back to comparing
+// their serialised form.
+func (k *keyPart) Compare(other *keyPart) int {
+	// Both uuid and timeuuid columns order time based UUIDs by their timestamp first
+	a, aok := k.Value.(gocql.UUID)
+	b, bok := other.Value.(gocql.UUID)
+	if aok && bok && a.Version() == 1 && b.Version() == 1 {
+		if cmp := compareInt64(a.Timestamp(), b.Timestamp()); cmp != 0 {
+			return cmp
+		}
+	}
+	if cmp, ok := compareValues(storedValue(k.Value), storedValue(other.Value)); ok {
+		return cmp
+	}
+	return bytes.Compare(k.Bytes(), other.Bytes())
+}
+
+// storedValue is the value a key is stored as, which differs from the value itself for types with a codec
+func storedValue(v interface{}) interface{} {
+	if mv, ok, err := marshalCodec(v); ok && err == nil {
+		return mv
+	}
+	return v
+}
+
+func compareValues(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return compareInt64(av.UnixNano(), bv.UnixNano()), true
+		}
+	case *big.Int:
+		if bv, ok := b.(*big.Int); ok && av != nil && bv != nil {
+			return av.Cmp(bv), true
+		}
+	case *inf.Dec:
+		if bv, ok := b.(*inf.Dec); ok && av != nil && bv != nil {
+			return av.Cmp(bv), true
+		}
+	case big.Float:
+		if bv, ok := b.(big.Float); ok {
+			return av.Cmp(&bv), true
+		}
+	case *big.Float:
+		if bv, ok := b.(*big.Float); ok && av != nil && bv != nil {
+			return av.Cmp(bv), true
+		}
+	case net.IP:
+		if bv, ok := b.(net.IP); ok {
+			return bytes.Compare(av.To16(), bv.To16()), true
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv), true
+		}
+	}
+
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !av.IsValid() || !bv.IsValid() {
+		return 0, false
+	}
+	switch av.Kind() {
+	case reflect.Slice, reflect.Array:
+		// Lists and tuples compare element by element
+		if bv.Kind() != reflect.Slice && bv.Kind() != reflect.Array {
+			return 0, false
+		}
+		for i := 0; i < av.Len() && i < bv.Len(); i++ {
+			cmp, ok := compareValues(av.Index(i).Interface(), bv.Index(i).Interface())
+			if !ok || cmp != 0 {
+				return cmp, ok
+			}
+		}
+		return compareInt64(int64(av.Len()), int64(bv.Len())), true
+	case reflect.Struct:
+		if av.Type() != bv.Type() || !isTupleStruct(av.Type()) {
+			return 0, false
+		}
+		for i := 0; i < av.NumField(); i++ {
+			cmp, ok := compareValues(av.Field(i).Interface(), bv.Field(i).Interface())
+			if !ok || cmp != 0 {
+				return cmp, ok
+			}
+		}
+		return 0, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch bv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareInt64(av.Int(), bv.Int()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch bv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			switch x, y := av.Uint(), bv.Uint(); {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch bv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			switch x, y := av.Float(), bv.Float(); {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case reflect.String:
+		if bv.Kind() == reflect.String {
+			return strings.Compare(av.String(), bv.String()), true
+		}
+	case reflect.Bool:
+		if bv.Kind() == reflect.Bool {
+			switch x, y := av.Bool(), bv.Bool(); {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
 func (k key) Less(other key) bool {
 	for i := 0; i < len(k) && i < len(other); i++ {
-		cmp := bytes.Compare(k[i].Bytes(), other[i].Bytes())
+		cmp := k[i].Compare(&other[i])
 		if cmp == 0 {
 			continue
 		}
@@ -174,10 +425,10 @@ func (k key) ToSuperColumn() *superColumn {
 	return &superColumn{Key: k}
 }
 
-func (k key) Append(column string, value interface{}) key {
+func (k key) Append(column string, value interface{}, typ gocql.Type) key {
 	newKey := make([]keyPart, len(k)+1)
 	copy(newKey, k)
-	newKey[len(k)] = keyPart{column, value}
+	newKey[len(k)] = keyPart{column, value, typ}
 	return newKey
 }
 
@@ -192,14 +443,22 @@ func (t *MockTable) keyFromColumnValues(values map[string]interface{}, keyNames
 		value, ok := values[keyName]
 
 		if !ok {
-			return nil, fmt.Errorf("Missing mandatory PRIMARY KEY part %s", keyName)
+			return nil, ValidationError{Table: t.Name(), Field: keyName, Reason: "missing mandatory PRIMARY KEY part"}
+		}
+		if _, _, err := marshalCodec(value); err != nil {
+			return nil, ValidationError{Table: t.Name(), Field: keyName, Reason: err.Error()}
 		}
-		key = key.Append(keyName, value)
+		key = key.Append(keyName, value, t.columnType(keyName))
 	}
 
 	return key, nil
 }
 
+// columnType returns the CQL type declared for a column in the entity's struct tags, if any
+func (t *MockTable) columnType(column string) gocql.Type {
+	return cassaTypeFromString(t.fieldTypes[column])
+}
+
 func (t *MockTable) Name() string {
 	if len(t.options.TableName) > 0 {
 		return t.options.TableName
@@ -218,6 +477,94 @@ func (t *MockTable) getOrCreateRow(rowKey key) *btree.BTree {
 	return row
 }
 
+// setStatics stores the static columns in columns on the partition, and returns the rest
+func (t *MockTable) setStatics(rowKey key, columns map[string]interface{}) map[string]interface{} {
+	if len(t.keys.StaticColumns) == 0 {
+		return columns
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	statics := t.statics[rowKey.RowKey()]
+	if statics == nil {
+		statics = map[string]interface{}{}
+		t.statics[rowKey.RowKey()] = statics
+	}
+	rest := map[string]interface{}{}
+	for k, v := range columns {
+		if isKey(k, t.keys.StaticColumns) {
+			statics[k] = v
+		} else {
+			rest[k] = v
+		}
+	}
+	return rest
+}
+
+// setColumn sets a column of a row, adding counter increments and Counters to the counter's value as Cassandra does
+func setColumn(columns map[string]interface{}, k string, v interface{}) {
+	delta := int64(0)
+	switch value := v.(type) {
+	case Counter:
+		delta = int64(value)
+	case Modifier:
+		if value.op != modifierCounterIncrement {
+			columns[k] = v
+			return
+		}
+		delta = int64(value.args[0].(int))
+	default:
+		columns[k] = v
+		return
+	}
+	current, _ := columns[k].(int64)
+	columns[k] = current + delta
+}
+
+// checkConditions fails with a NotAppliedError if the conditions of a lightweight transaction do not hold on a row
+func (t *MockTable) checkConditions(rowKey, superColumnKey key, opts Options) error {
+	var current map[string]interface{}
+	t.mtx.RLock()
+	if row := t.rows[rowKey.RowKey()]; row != nil {
+		if item := row.Get(superColumnKey.ToSuperColumn()); item != nil {
+			current = map[string]interface{}{}
+			for k, v := range t.withStatics(rowKey, item.(*superColumn).Columns) {
+				current[k] = v
+			}
+		}
+	}
+	t.mtx.RUnlock()
+
+	applied := current != nil
+	switch {
+	case opts.IfNotExists:
+		applied = current == nil
+	case current != nil:
+		for _, c := range opts.Conditions {
+			applied = applied && c.accept(columnValue(current, c.key))
+		}
+	}
+	if applied {
+		return nil
+	}
+	return NotAppliedError{Table: t.Name(), Current: current}
+}
+
+// withStatics returns the columns of a row together with the static columns of its partition
+func (t *MockTable) withStatics(rowKey key, columns map[string]interface{}) map[string]interface{} {
+	statics := t.statics[rowKey.RowKey()]
+	if len(statics) == 0 {
+		return columns
+	}
+	result := map[string]interface{}{}
+	for k, v := range columns {
+		result[k] = v
+	}
+	for k, v := range statics {
+		result[k] = v
+	}
+	return result
+}
+
 func (t *MockTable) getOrCreateColumnGroup(rowKey, superColumnKey key) map[string]interface{} {
 	row := t.getOrCreateRow(rowKey)
 	scol := superColumnKey.ToSuperColumn()
@@ -236,9 +583,12 @@ func (t *MockTable) SetWithOptions(i interface{}, options Options) Op {
 		t.Lock()
 		defer t.Unlock()
 
-		columns, ok := toMap(i)
+		columns, ok := toWriteMap(i, append(append([]string{}, t.keys.PartitionKeys...), t.keys.ClusteringColumns...)...)
 		if !ok {
-			return errors.New("Can't create: value not understood")
+			return unrecognizedRowError(t.Name(), i)
+		}
+		if t.options.Merge(options).Merge(m.options).PartialSet {
+			columns = withoutEmpty(columns, t.keys)
 		}
 
 		rowKey, err := t.keyFromColumnValues(columns, t.keys.PartitionKeys)
@@ -246,15 +596,28 @@ func (t *MockTable) SetWithOptions(i interface{}, options Options) Op {
 			return err
 		}
 
+		if staticOnly(t.keys, columns) {
+			t.setStatics(rowKey, columns)
+			return nil
+		}
+
 		superColumnKey, err := t.keyFromColumnValues(columns, t.keys.ClusteringColumns)
 		if err != nil {
 			return err
 		}
+		if opts := t.options.Merge(options).Merge(m.options); opts.conditional() {
+			if err := validateConditions(t.Name(), t.keys, relations(t.keys, columns), updateOpType, opts); err != nil {
+				return err
+			}
+			if err := t.checkConditions(rowKey, superColumnKey, opts); err != nil {
+				return err
+			}
+		}
 
 		superColumn := t.getOrCreateColumnGroup(rowKey, superColumnKey)
 
-		for k, v := range columns {
-			superColumn[k] = v
+		for k, v := range t.setStatics(rowKey, columns) {
+			setColumn(superColumn, k, v)
 		}
 		return nil
 	})
@@ -293,11 +656,13 @@ func (t *MockTable) Recreate() error {
 
 func (t *MockTable) WithOptions(o Options) Table {
 	return &MockTable{
-		name:    t.name,
-		rows:    t.rows,
-		entity:  t.entity,
-		keys:    t.keys,
-		options: t.options.Merge(o),
+		name:       t.name,
+		rows:       t.rows,
+		statics:    t.statics,
+		entity:     t.entity,
+		keys:       t.keys,
+		options:    t.options.Merge(o),
+		fieldTypes: t.fieldTypes,
 	}
 }
 
@@ -351,6 +716,16 @@ func (f *MockFilter) keyRelationMap() map[string]Relation {
 	return result
 }
 
+// restrictsClustering tells if any of the relations is on a clustering column
+func (f *MockFilter) restrictsClustering() bool {
+	for _, relation := range f.relations {
+		if isKey(relation.key, f.table.keys.ClusteringColumns) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *MockFilter) keysFromRelations(keyNames []string) ([]key, error) {
 	keyRelationMap := f.keyRelationMap()
 	var rowKey key
@@ -365,18 +740,18 @@ func (f *MockFilter) keysFromRelations(keyNames []string) ([]key, error) {
 		relation, ok := keyRelationMap[keyName]
 
 		if !ok {
-			return nil, fmt.Errorf("Missing mandatory PRIMARY KEY part `%s`", keyName)
+			return nil, ValidationError{Table: f.table.Name(), Field: keyName, Reason: "missing mandatory PRIMARY KEY part"}
 		}
 
 		if relation.op != equality && !(lastKey && relation.op == in) {
-			return nil, fmt.Errorf("Invalid use of PK `%s`", keyName)
+			return nil, ValidationError{Table: f.table.Name(), Field: keyName, Reason: "invalid use of PRIMARY KEY part"}
 		}
 
 		if !lastKey {
-			rowKey = rowKey.Append(keyName, relation.terms[0])
+			rowKey = rowKey.Append(keyName, relation.terms[0], f.table.columnType(keyName))
 		} else {
 			for _, term := range relation.terms {
-				result = append(result, rowKey.Append(relation.key, term))
+				result = append(result, rowKey.Append(relation.key, term, f.table.columnType(keyName)))
 			}
 		}
 	}
@@ -384,10 +759,61 @@ func (f *MockFilter) keysFromRelations(keyNames []string) ([]key, error) {
 	return result, nil
 }
 
+// newOp returns an Op running run, which validates the relations of the filter the same way singleOp does first
+func (f *MockFilter) newOp(opType uint8, m map[string]interface{}, run func(mockOp) error) mockOp {
+	op := newOp(run)
+	op.preflight = func(op mockOp) error {
+		opts := f.table.options.Merge(op.options)
+		if err := validateRelations(f.table.Name(), f.table.keys, f.relations, opType, opts.AllowFiltering, m); err != nil {
+			return err
+		}
+		if opType == readOpType || opType == singleReadOpType {
+			return validateReadOptions(f.table.Name(), f.table.keys, opts)
+		}
+		return validateConditions(f.table.Name(), f.table.keys, f.relations, opType, opts)
+	}
+	return op
+}
+
+// checkConditions fails with a NotAppliedError if the conditions of a lightweight transaction do not hold on the row
+// of the filter
+func (f *MockFilter) checkConditions(opts Options) error {
+	if !opts.conditional() {
+		return nil
+	}
+	rowKeys, err := f.keysFromRelations(f.table.keys.PartitionKeys)
+	if err != nil {
+		return err
+	}
+	superColumnKeys, err := f.keysFromRelations(f.table.keys.ClusteringColumns)
+	if err != nil {
+		return err
+	}
+	return f.table.checkConditions(rowKeys[0], superColumnKeys[0], opts)
+}
+
 func (f *MockFilter) UpdateWithOptions(m map[string]interface{}, options Options) Op {
-	return newOp(func(mock mockOp) error {
+	cols := withoutUnset(m)
+	if f.table.options.Merge(options).PartialSet {
+		cols = withoutEmpty(cols, f.table.keys)
+	}
+	if len(cols) == 0 && len(m) > 0 {
+		return Noop()
+	}
+	m = cols
+	op := f.newOp(updateOpType, m, func(mock mockOp) error {
 		f.table.Lock()
 		defer f.table.Unlock()
+		if err := f.checkConditions(f.table.options.Merge(options).Merge(mock.options)); err != nil {
+			return err
+		}
+		m := m
+		if f.table.options.Merge(mock.options).PartialSet {
+			// PartialSet set on the op leaves out columns too
+			if m = withoutEmpty(m, f.table.keys); len(m) == 0 {
+				return nil
+			}
+		}
 
 		rowKeys, err := f.keysFromRelations(f.table.keys.PartitionKeys)
 		if err != nil {
@@ -395,6 +821,11 @@ func (f *MockFilter) UpdateWithOptions(m map[string]interface{}, options Options
 		}
 
 		for _, rowKey := range rowKeys {
+			if !f.restrictsClustering() && staticOnly(f.table.keys, m) {
+				f.table.setStatics(rowKey, m)
+				continue
+			}
+
 			superColumnKeys, err := f.keysFromRelations(f.table.keys.ClusteringColumns)
 			if err != nil {
 				return err
@@ -409,14 +840,16 @@ func (f *MockFilter) UpdateWithOptions(m map[string]interface{}, options Options
 					}
 				}
 
-				for key, value := range m {
-					superColumn[key] = value
+				for key, value := range f.table.setStatics(rowKey, m) {
+					setColumn(superColumn, key, value)
 				}
 			}
 		}
 
 		return nil
 	})
+	op.options = options
+	return op
 }
 
 func (f *MockFilter) Update(m map[string]interface{}) Op {
@@ -424,9 +857,12 @@ func (f *MockFilter) Update(m map[string]interface{}) Op {
 }
 
 func (f *MockFilter) Delete() Op {
-	return newOp(func(m mockOp) error {
+	return f.newOp(deleteOpType, nil, func(m mockOp) error {
 		f.table.Lock()
 		defer f.table.Unlock()
+		if err := f.checkConditions(f.table.options.Merge(m.options)); err != nil {
+			return err
+		}
 
 		rowKeys, err := f.keysFromRelations(f.table.keys.PartitionKeys)
 		if err != nil {
@@ -436,11 +872,14 @@ func (f *MockFilter) Delete() Op {
 		f.table.mtx.Lock()
 		defer f.table.mtx.Unlock()
 		for _, rowKey := range rowKeys {
+			if !f.restrictsClustering() {
+				delete(f.table.statics, rowKey.RowKey())
+			}
 			row := f.table.rows[rowKey.RowKey()]
 			if row == nil {
-				return nil
+				continue
 			}
-			
+
 			targets := []btree.Item{}
 
 			row.Ascend(func(item btree.Item) bool {
@@ -460,41 +899,275 @@ func (f *MockFilter) Delete() Op {
 	})
 }
 
-func (q *MockFilter) Read(out interface{}) Op {
-	return newOp(func(m mockOp) error {
-		q.table.Lock()
-		defer q.table.Unlock()
+func (f *MockFilter) DeleteColumns(columns ...string) Op {
+	if len(columns) == 0 {
+		return f.Delete()
+	}
+	m := columnSet(columns)
+	return f.newOp(deleteOpType, m, func(op mockOp) error {
+		f.table.Lock()
+		defer f.table.Unlock()
+		if err := f.checkConditions(f.table.options.Merge(op.options)); err != nil {
+			return err
+		}
 
-		rowKeys, err := q.keysFromRelations(q.table.keys.PartitionKeys)
+		rowKeys, err := f.keysFromRelations(f.table.keys.PartitionKeys)
 		if err != nil {
 			return err
 		}
 
-		q.table.mtx.RLock()
-		defer q.table.mtx.RUnlock()
-		var result []map[string]interface{}
+		f.table.mtx.Lock()
+		defer f.table.mtx.Unlock()
 		for _, rowKey := range rowKeys {
-			row := q.table.rows[rowKey.RowKey()]
+			statics := f.table.statics[rowKey.RowKey()]
+			for _, column := range columns {
+				delete(statics, column)
+			}
+			row := f.table.rows[rowKey.RowKey()]
 			if row == nil {
 				continue
 			}
 
+			targets := []btree.Item{}
 			row.Ascend(func(item btree.Item) bool {
-				columns := item.(*superColumn).Columns
-				if q.rowMatch(columns) {
-					result = append(result, columns)
+				if f.rowMatch(item.(*superColumn).Columns) {
+					targets = append(targets, item)
 				}
-
 				return true
 			})
+			for _, item := range targets {
+				// A row written by an update, as Set does, is gone once all its regular columns are deleted
+				cols := item.(*superColumn).Columns
+				hadValues := f.table.hasValues(cols)
+				for _, column := range columns {
+					delete(cols, column)
+				}
+				if hadValues && !f.table.hasValues(cols) {
+					row.Delete(item)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// hasValues tells if a row has columns other than its primary key
+func (t *MockTable) hasValues(columns map[string]interface{}) bool {
+	for k := range columns {
+		if !isKey(k, t.keys.PartitionKeys, t.keys.ClusteringColumns) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *MockFilter) Read(out interface{}) Op {
+	return q.newOp(readOpType, nil, func(m mockOp) error {
+		result, err := q.matchingRows()
+		if err != nil {
+			return err
+		}
+		opt := q.table.options.Merge(m.options)
+		if len(opt.ClusteringOrder) > 0 && opt.ClusteringOrder[0].Direction == DESC {
+			result = q.table.reverseClustering(result)
+		}
+		if len(opt.GroupBy) > 0 {
+			// Reads return the first row of each group
+			var firsts []map[string]interface{}
+			for _, group := range q.table.groupRows(result, opt.GroupBy) {
+				firsts = append(firsts, group[0])
+			}
+			result = firsts
+		}
+		result = q.table.limitRows(result, opt)
+
+		return q.assignResult(result, out)
+	})
+}
+
+// groupRows splits rows, which are in primary key order, into groups of rows with the same values of the columns
+func (t *MockTable) groupRows(rows []map[string]interface{}, columns []string) [][]map[string]interface{} {
+	var (
+		groups  [][]map[string]interface{}
+		current rowKey
+	)
+	for i, row := range rows {
+		var k key
+		for _, c := range columns {
+			k = k.Append(c, columnValue(row, c), t.columnType(c))
+		}
+		if i == 0 || k.RowKey() != current {
+			groups = append(groups, nil)
+			current = k.RowKey()
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], row)
+	}
+	return groups
+}
+
+// reverseClustering reverses the order of the rows of each partition, for reads in descending clustering order
+func (t *MockTable) reverseClustering(rows []map[string]interface{}) []map[string]interface{} {
+	ret := make([]map[string]interface{}, 0, len(rows))
+	for _, partition := range t.groupRows(rows, t.keys.PartitionKeys) {
+		for i := len(partition) - 1; i >= 0; i-- {
+			ret = append(ret, partition[i])
+		}
+	}
+	return ret
+}
+
+// limitRows applies the per partition limit and the limit of a read to rows
+func (t *MockTable) limitRows(rows []map[string]interface{}, opt Options) []map[string]interface{} {
+	if opt.PerPartitionLimit > 0 {
+		var limited []map[string]interface{}
+		for _, partition := range t.groupRows(rows, t.keys.PartitionKeys) {
+			if len(partition) > opt.PerPartitionLimit {
+				partition = partition[:opt.PerPartitionLimit]
+			}
+			limited = append(limited, partition...)
+		}
+		rows = limited
+	}
+	if opt.Limit > 0 && opt.Limit < len(rows) {
+		rows = rows[:opt.Limit]
+	}
+	return rows
+}
+
+// matchingRows returns the rows matching the filter, with the static columns of their partition, partition by
+// partition in clustering order
+func (q *MockFilter) matchingRows() ([]map[string]interface{}, error) {
+	q.table.Lock()
+	defer q.table.Unlock()
+
+	rowKeys, err := q.keysFromRelations(q.table.keys.PartitionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	q.table.mtx.RLock()
+	defer q.table.mtx.RUnlock()
+	var result []map[string]interface{}
+	for _, rowKey := range rowKeys {
+		row := q.table.rows[rowKey.RowKey()]
+		if row == nil || row.Len() == 0 {
+			// A partition with only static columns is read as a single row without clustering columns
+			if len(q.table.statics[rowKey.RowKey()]) > 0 && !q.restrictsClustering() {
+				columns := q.table.withStatics(rowKey, map[string]interface{}{})
+				for _, keyPart := range rowKey {
+					columns[keyPart.Key] = keyPart.Value
+				}
+				result = append(result, columns)
+			}
+			continue
+		}
+
+		row.Ascend(func(item btree.Item) bool {
+			columns := q.table.withStatics(rowKey, item.(*superColumn).Columns)
+			if q.rowMatch(columns) {
+				result = append(result, columns)
+			}
+
+			return true
+		})
+	}
+	return result, nil
+}
+
+func (q *MockFilter) Count(count *int64) Op {
+	return q.newOp(singleReadOpType, nil, func(m mockOp) error {
+		rows, err := q.matchingRows()
+		if err != nil {
+			return err
+		}
+		*count = int64(len(rows))
+		return nil
+	})
+}
+
+func (q *MockFilter) Distinct(out interface{}, staticColumns ...string) Op {
+	p := distinctProjection(q.table.keys, staticColumns)
+	op := q.newOp(readOpType, nil, func(m mockOp) error {
+		rows, err := q.matchingRows()
+		if err != nil {
+			return err
+		}
+		columns := distinctColumns(q.table.keys, staticColumns)
+		var result []map[string]interface{}
+		seen := map[rowKey]bool{}
+		for _, row := range rows {
+			// Rows are grouped by partition, so the first row of each stands for it
+			var partition key
+			for _, pk := range q.table.keys.PartitionKeys {
+				partition = partition.Append(pk, row[pk], q.table.columnType(pk))
+			}
+			if seen[partition.RowKey()] {
+				continue
+			}
+			seen[partition.RowKey()] = true
+			projected := map[string]interface{}{}
+			for _, c := range columns {
+				projected[c] = columnValue(row, c)
+			}
+			result = append(result, projected)
 		}
 		opt := q.table.options.Merge(m.options)
 		if opt.Limit > 0 && opt.Limit < len(result) {
 			result = result[:opt.Limit]
 		}
-
 		return q.assignResult(result, out)
 	})
+	preflight := op.preflight
+	op.preflight = func(o mockOp) error {
+		if err := preflight(o); err != nil {
+			return err
+		}
+		return validateProjection(q.table.Name(), q.table.keys, q.relations, p)
+	}
+	return op
+}
+
+func (q *MockFilter) Aggregate(out interface{}, aggregates ...Aggregate) Op {
+	opType := singleReadOpType
+	if isSlicePointer(out) {
+		opType = readOpType
+	}
+	return q.newOp(opType, nil, func(m mockOp) error {
+		rows, err := q.matchingRows()
+		if err != nil {
+			return err
+		}
+		opt := q.table.options.Merge(m.options)
+		groups := [][]map[string]interface{}{rows}
+		if len(opt.GroupBy) > 0 {
+			groups = q.table.groupRows(rows, opt.GroupBy)
+		}
+		var results []map[string]interface{}
+		for _, group := range groups {
+			result := map[string]interface{}{}
+			for _, c := range opt.GroupBy {
+				result[strings.ToLower(c)] = columnValue(group[0], c)
+			}
+			for _, a := range aggregates {
+				v, err := a.compute(group)
+				if err != nil {
+					return err
+				}
+				result[a.Name()] = v
+			}
+			results = append(results, result)
+		}
+		results = q.table.limitRows(results, Options{Limit: opt.Limit})
+
+		if opType == readOpType {
+			return q.assignResult(results, out)
+		}
+		if len(results) == 0 {
+			return newRowNotFoundError(q.table.Name(), "")
+		}
+		return decodeProjected(results[0], aggregateProjection(aggregates).withGroupBy(opt.GroupBy).names, out)
+	})
 }
 
 func (q *MockFilter) assignResult(records interface{}, out interface{}) error {
@@ -502,17 +1175,17 @@ func (q *MockFilter) assignResult(records interface{}, out interface{}) error {
 }
 
 func (q *MockFilter) ReadOne(out interface{}) Op {
-	return newOp(func(m mockOp) error {
+	return q.newOp(singleReadOpType, nil, func(m mockOp) error {
 		slicePtrVal := reflect.New(reflect.SliceOf(reflect.ValueOf(out).Elem().Type()))
 
-		err := q.Read(slicePtrVal.Interface()).Run()
+		err := q.Read(slicePtrVal.Interface()).WithOptions(m.options).Run()
 		if err != nil {
 			return err
 		}
 
 		sliceVal := slicePtrVal.Elem()
 		if sliceVal.Len() < 1 {
-			return RowNotFoundError{}
+			return newRowNotFoundError(q.table.Name(), "")
 		}
 		q.assignResult(sliceVal.Index(0).Interface(), out)
 		return nil
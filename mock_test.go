@@ -1,11 +1,17 @@
 package gocassa
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/gocql/gocql"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/inf.v0"
 )
 
 type user struct {
@@ -45,6 +51,7 @@ type MockSuite struct {
 	ks        KeySpace
 	mapTbl    MapTable
 	mmapTbl   MultimapTable
+	smmapTbl  MultimapTable
 	tsTbl     TimeSeriesTable
 	mtsTbl    MultiTimeSeriesTable
 	embMapTbl MapTable
@@ -61,6 +68,7 @@ func (s *MockSuite) SetupTest() {
 
 	s.mapTbl = s.ks.MapTable("users", "Pk1", user{})
 	s.mmapTbl = s.ks.MultimapTable("users", "Pk1", "Pk2", user{})
+	s.smmapTbl = s.ks.ShardedMultimapTable("users", "Pk1", "Pk2", 4, user{})
 	s.tsTbl = s.ks.TimeSeriesTable("points", "Time", "Id", 1*time.Minute, point{})
 	s.mtsTbl = s.ks.MultiTimeSeriesTable("points", "User", "Time", "Id", 1*time.Minute, point{})
 
@@ -203,7 +211,7 @@ func (s *MockSuite) TestMapTableDelete() {
 	s.insertUsers()
 	s.NoError(s.mapTbl.Delete(1).Run())
 	var user user
-	s.Equal(RowNotFoundError{}, s.mapTbl.Read(1, &user).Run())
+	s.True(errors.Is(s.mapTbl.Read(1, &user).Run(), ErrNotFound))
 }
 
 // MultiMapTable tests
@@ -262,7 +270,7 @@ func (s *MockSuite) TestMultiMapTableDelete() {
 	s.insertUsers()
 	s.NoError(s.mmapTbl.Delete(1, 2).Run())
 	var u user
-	s.Equal(RowNotFoundError{}, s.mmapTbl.Read(1, 2, &u).Run())
+	s.True(errors.Is(s.mmapTbl.Read(1, 2, &u).Run(), ErrNotFound))
 }
 
 func (s *MockSuite) TestMultiMapTableDeleteAll() {
@@ -272,6 +280,79 @@ func (s *MockSuite) TestMultiMapTableDeleteAll() {
 	s.Empty(users)
 }
 
+// Sharded MultiMapTable tests
+func (s *MockSuite) TestShardedMultiMapTableRead() {
+	s.insertUsers()
+
+	var u user
+	s.NoError(s.smmapTbl.Read(1, 1, &u).Run())
+	s.Equal("Jane", u.Name)
+	s.NoError(s.smmapTbl.Read(1, 2, &u).Run())
+	s.Equal("Joe", u.Name)
+}
+
+func (s *MockSuite) TestShardedMultiMapTableMultiRead() {
+	s.insertUsers()
+	var users []user
+	s.NoError(s.smmapTbl.MultiRead(1, []interface{}{1, 2}, &users).Run())
+	s.Len(users, 2)
+}
+
+func (s *MockSuite) TestShardedMultiMapTableList() {
+	for i := 0; i < 20; i++ {
+		s.NoError(s.smmapTbl.Set(user{Pk1: 1, Pk2: i, Name: fmt.Sprint("user ", i)}).Run())
+	}
+	s.NoError(s.smmapTbl.Set(user{Pk1: 2, Pk2: 5, Name: "other"}).Run())
+
+	// The rows of the shards are merged in the order of the ids, up to the limit
+	var users []user
+	s.NoError(s.smmapTbl.List(1, nil, 0, &users).Run())
+	s.Len(users, 20)
+	for i, u := range users {
+		s.Equal(i, u.Pk2)
+	}
+	s.NoError(s.smmapTbl.List(1, 5, 3, &users).Run())
+	s.Len(users, 3)
+	s.Equal([]int{5, 6, 7}, []int{users[0].Pk2, users[1].Pk2, users[2].Pk2})
+
+	s.NoError(s.smmapTbl.List(1, nil, 3, &users).WithOptions(Options{
+		ClusteringOrder: []ClusteringOrderColumn{{DESC, "Pk2"}},
+	}).Run())
+	s.Equal([]int{19, 18, 17}, []int{users[0].Pk2, users[1].Pk2, users[2].Pk2})
+	s.NoError(s.smmapTbl.WithOptions(Options{
+		ClusteringOrder: []ClusteringOrderColumn{{DESC, "Pk2"}},
+	}).List(1, nil, 3, &users).Run())
+	s.Equal([]int{19, 18, 17}, []int{users[0].Pk2, users[1].Pk2, users[2].Pk2})
+}
+
+func (s *MockSuite) TestShardedMultiMapTableUpdate() {
+	s.insertUsers()
+
+	s.NoError(s.smmapTbl.Update(1, 2, map[string]interface{}{
+		"Name": "foo",
+	}).Run())
+	var u user
+	s.NoError(s.smmapTbl.Read(1, 2, &u).Run())
+	s.Equal("foo", u.Name)
+}
+
+func (s *MockSuite) TestShardedMultiMapTableDelete() {
+	s.insertUsers()
+	s.NoError(s.smmapTbl.Delete(1, 2).Run())
+	var u user
+	s.True(errors.Is(s.smmapTbl.Read(1, 2, &u).Run(), ErrNotFound))
+}
+
+func (s *MockSuite) TestShardedMultiMapTableDeleteAll() {
+	s.insertUsers()
+	s.NoError(s.smmapTbl.DeleteAll(1).Run())
+	var users []user
+	s.NoError(s.smmapTbl.List(1, nil, 0, &users).Run())
+	s.Empty(users)
+	s.NoError(s.smmapTbl.List(2, nil, 0, &users).Run())
+	s.Len(users, 1)
+}
+
 // TimeSeriesTable tests
 func (s *MockSuite) TestTimeSeriesTableRead() {
 	points := s.insertPoints()
@@ -316,7 +397,144 @@ func (s *MockSuite) TestTimeSeriesTableDelete() {
 
 	var p point
 	s.NoError(s.tsTbl.Delete(points[0].Time, points[0].Id).Run())
-	s.Equal(RowNotFoundError{}, s.tsTbl.Read(points[0].Time, points[0].Id, &p).Run())
+	s.True(errors.Is(s.tsTbl.Read(points[0].Time, points[0].Id, &p).Run(), ErrNotFound))
+}
+
+func (s *MockSuite) TestFlexTimeSeriesTableList() {
+	tbl := s.ks.FlexTimeSeriesTable("points", "Time", "Id", MonthBucketer(nil), point{})
+	start := time.Date(2021, time.January, 31, 0, 0, 0, 0, time.UTC)
+	for i, day := range []time.Time{start, start.AddDate(0, 0, 28), start.AddDate(0, 0, 59)} {
+		// The last day of January, February and March
+		s.NoError(tbl.Set(point{Time: day, Id: i}).Run())
+	}
+
+	var ps []point
+	s.NoError(tbl.List(start, time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), &ps).Run())
+	s.Len(ps, 2)
+	s.NoError(tbl.List(start, time.Date(2021, time.March, 31, 0, 0, 0, 0, time.UTC), &ps).Run())
+	s.Len(ps, 3)
+}
+
+func (s *MockSuite) TestTimeSeriesTableListRange() {
+	tbl := s.ks.FlexTimeSeriesTable("points", "Time", "Id", DayBucketer(nil), point{})
+	start := s.parseTime("2015-04-01 12:00:00")
+	var points []point
+	for i, offset := range []time.Duration{0, 0, time.Hour, 24 * time.Hour, 72 * time.Hour} {
+		// Two points at the same time, and an empty day
+		p := point{Time: start.Add(offset), Id: i}
+		points = append(points, p)
+		s.NoError(tbl.Set(p).Run())
+	}
+	end := start.Add(96 * time.Hour)
+
+	// Newest first, two at a time
+	var (
+		listed []point
+		page   []point
+		cursor Cursor
+	)
+	for i := 0; i < 3; i++ {
+		s.NoError(tbl.ListRange(start, end, DESC, 2, cursor, &page, &cursor).Run())
+		listed = append(listed, page...)
+	}
+	s.Equal([]point{points[4], points[3], points[2], points[1], points[0]}, listed)
+	s.Empty(cursor)
+
+	// Oldest first, resuming between the points with the same time
+	s.NoError(tbl.ListRange(start, end, ASC, 1, "", &page, &cursor).Run())
+	s.Equal([]point{points[0]}, page)
+	s.NoError(tbl.ListRange(start, end, ASC, 0, cursor, &page, &cursor).Run())
+	s.Equal(points[1:], page)
+	s.Empty(cursor)
+
+	// ListLatest goes through every day since the points unless MaxBuckets bounds it
+	s.NoError(tbl.ListLatest(start.Add(time.Hour), 10, &page).Run())
+	s.Equal([]point{points[4], points[3], points[2]}, page)
+	s.True(errors.Is(tbl.ListLatest(start.Add(time.Hour), 10, &page).WithOptions(Options{MaxBuckets: 1000}).Run(), ErrValidation))
+
+	s.True(errors.Is(tbl.ListRange(start, end, ASC, 1, "nope", &page, nil).Run(), ErrValidation))
+}
+
+func (s *MockSuite) TestTimeSeriesTableListRangeMaxBuckets() {
+	bucketer := &countingBucketer{Bucketer: DayBucketer(nil)}
+	tbl := s.ks.FlexTimeSeriesTable("points", "Time", "Id", bucketer, point{}).WithOptions(Options{MaxBuckets: 2})
+	start := s.parseTime("2015-04-01 12:00:00")
+	points := []point{{Time: start, Id: 1}, {Time: start.Add(96 * time.Hour), Id: 2}}
+	for _, p := range points {
+		s.NoError(tbl.Set(p).Run())
+	}
+	end := start.Add(120 * time.Hour)
+
+	// Each call stops after two days, and resumes at the next one
+	var (
+		listed []point
+		page   []point
+		cursor Cursor
+	)
+	for i := 0; i < 3; i++ {
+		bucketer.next = 0
+		s.NoError(tbl.ListRange(start, end, ASC, 10, cursor, &page, &cursor).Run())
+		s.LessOrEqual(bucketer.next, 2)
+		s.NotEqual(i < 2, cursor == "")
+		listed = append(listed, page...)
+	}
+	s.Equal(points, listed)
+
+	// Without a cursor to resume from, going through more buckets fails
+	err := tbl.ListRange(start, end, ASC, 10, "", &page, nil).Run()
+	s.True(errors.Is(err, ErrValidation))
+	s.Contains(err.Error(), "maximum of 2 buckets")
+	s.NoError(tbl.ListRange(start, start.Add(24*time.Hour), ASC, 10, "", &page, nil).Run())
+	s.Equal(points[:1], page)
+}
+
+func (s *MockSuite) TestTimeSeriesTableDescendingOrder() {
+	tbl := s.ks.TimeSeriesTable("points", "Time", "Id", time.Hour, point{}).WithOptions(Options{
+		ClusteringOrder: []ClusteringOrderColumn{{DESC, "Time"}, {DESC, "Id"}},
+	})
+	points := []point{{Time: s.parseTime("2015-04-01 15:41:00"), Id: 1}, {Time: s.parseTime("2015-04-01 15:42:00"), Id: 2}}
+	for _, p := range points {
+		s.NoError(tbl.Set(p).Run())
+	}
+
+	// Reads follow the clustering order of the table unless told otherwise
+	var ps []point
+	s.NoError(tbl.List(points[0].Time, points[1].Time, &ps).Run())
+	s.Equal([]point{points[1], points[0]}, ps)
+	s.NoError(tbl.ListRange(points[0].Time, points[1].Time, ASC, 0, "", &ps, nil).Run())
+	s.Equal(points, ps)
+}
+
+func (s *MockSuite) TestTimeSeriesTableListBuckets() {
+	tbl := s.ks.TimeSeriesTable("points", "Time", "Id", time.Hour, point{})
+	start := s.parseTime("2015-04-01 00:30:00")
+	var points []point
+	for i := 0; i < 10; i++ {
+		p := point{Time: start.Add(time.Duration(i) * time.Hour), Id: i}
+		points = append(points, p)
+		s.NoError(tbl.Set(p).Run())
+	}
+	end := points[len(points)-1].Time
+
+	for _, opts := range []Options{
+		{},
+		{BucketsPerQuery: 3},
+		{BucketsPerQuery: 1, ParallelQueries: 4},
+	} {
+		var ps []point
+		s.NoError(tbl.WithOptions(opts).List(start, end, &ps).Run())
+		s.Equal(points, ps, "%+v", opts)
+		s.NoError(tbl.List(start, end, &ps).WithOptions(opts.Merge(Options{Limit: 4})).Run())
+		s.Equal(points[:4], ps, "%+v", opts)
+	}
+
+	// Descending tables list the latest buckets first
+	desc := tbl.WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{DESC, "Time"}, {DESC, "Id"}}})
+	var ps []point
+	s.NoError(desc.List(start, end, &ps).WithOptions(Options{BucketsPerQuery: 1, ParallelQueries: 2, Limit: 2}).Run())
+	s.Equal([]point{points[9], points[8]}, ps)
+
+	s.True(errors.Is(tbl.WithOptions(Options{MaxBuckets: 5}).List(start, end, &ps).Run(), ErrValidation))
 }
 
 // MultiTimeSeriesTable tests
@@ -360,7 +578,7 @@ func (s *MockSuite) TestMultiTimeSeriesTableDelete() {
 	s.NoError(s.mtsTbl.Delete("John", points[0].Time, points[0].Id).Run())
 
 	var p point
-	s.Equal(RowNotFoundError{}, s.mtsTbl.Read("John", points[0].Time, points[0].Id, &p).Run())
+	s.True(errors.Is(s.mtsTbl.Read("John", points[0].Time, points[0].Id, &p).Run(), ErrNotFound))
 }
 
 func (s *MockSuite) TestNoop() {
@@ -385,6 +603,104 @@ func (s *MockSuite) TestEmbedMapRead() {
 	s.Equal(expectedAddresses[1], actualAddress)
 }
 
+func (s *MockSuite) TestTableTypesRoundTrip() {
+	tbl := s.ks.Table("types", OpTestTypesStruct{}, Keys{
+		PartitionKeys:     []string{"Ascii"},
+		ClusteringColumns: []string{"Created"},
+	})
+	expected := OpTestTypesStruct{
+		TinyInt:  -3,
+		SmallInt: -300,
+		Unsigned: 200,
+		Varint:   big.NewInt(-12345678901),
+		Decimal:  inf.NewDec(12345, 2),
+		Inet:     net.ParseIP("10.0.0.1"),
+		Date:     s.parseTime("2015-04-01 00:00:00"),
+		Time:     90 * time.Minute,
+		Duration: gocql.Duration{Days: 2},
+		Created:  gocql.TimeUUID(),
+		Ascii:    "abc",
+	}
+	s.NoError(tbl.Set(expected).Run())
+
+	var actual OpTestTypesStruct
+	s.NoError(tbl.Where(Eq("Ascii", "abc"), Eq("Created", expected.Created)).ReadOne(&actual).Run())
+	s.Equal(expected, actual)
+}
+
+func (s *MockSuite) TestTableKeyOrdering() {
+	type row struct {
+		Pk      string
+		Ck      int
+		Created gocql.UUID `cql:",type=timeuuid"`
+	}
+	tbl := s.ks.Table("ordering", row{}, Keys{
+		PartitionKeys:     []string{"Pk"},
+		ClusteringColumns: []string{"Ck", "Created"},
+	})
+	base := s.parseTime("2015-04-01 00:00:00")
+	rows := []row{
+		{Pk: "a", Ck: -10, Created: gocql.UUIDFromTime(base)},
+		{Pk: "a", Ck: -1, Created: gocql.UUIDFromTime(base)},
+		{Pk: "a", Ck: 1, Created: gocql.UUIDFromTime(base)},
+		{Pk: "a", Ck: 1, Created: gocql.UUIDFromTime(base.Add(time.Second))},
+		{Pk: "a", Ck: 1, Created: gocql.UUIDFromTime(base.Add(time.Hour))},
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		s.NoError(tbl.Set(rows[i]).Run())
+	}
+
+	var actual []row
+	s.NoError(tbl.Where(Eq("Pk", "a")).Read(&actual).Run())
+	s.Equal(rows, actual)
+}
+
+func (s *MockSuite) TestTableTupleAndFrozenKeys() {
+	tbl := s.ks.Table("tuples", OpTestTupleStruct{}, Keys{
+		PartitionKeys:     []string{"Location"},
+		ClusteringColumns: []string{"Tags"},
+	})
+	london := OpTestGeoPoint{51.5, -0.1}
+	rows := []OpTestTupleStruct{
+		{Location: london, Tags: []string{"a"}, Nested: map[string][]int{"k": {1}}},
+		{Location: london, Tags: []string{"a", "b"}},
+		{Location: london, Tags: []string{"b"}},
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		s.NoError(tbl.Set(rows[i]).Run())
+	}
+	s.NoError(tbl.Set(OpTestTupleStruct{Location: OpTestGeoPoint{48.9, 2.4}, Tags: []string{"a"}}).Run())
+
+	var actual []OpTestTupleStruct
+	s.NoError(tbl.Where(Eq("Location", london)).Read(&actual).Run())
+	s.Equal(rows, actual)
+
+	var row OpTestTupleStruct
+	s.NoError(tbl.Where(Eq("Location", london), Eq("Tags", []string{"a", "b"})).ReadOne(&row).Run())
+	s.Equal(rows[1], row)
+}
+
+func (s *MockSuite) TestTableWithUdt() {
+	s.ks.Type("address", AddressUdt{})
+	s.ks.Type("person", PersonUdt{})
+	tbl := s.ks.Table("people", PersonWithUdt{}, Keys{PartitionKeys: []string{"Id"}})
+	s.NoError(tbl.CreateIfNotExist())
+
+	expected := PersonWithUdt{
+		Id: "1",
+		Person: PersonUdt{
+			Name:    "Jane",
+			Home:    AddressUdt{Street: "Main St", Geo: &GeoUdt{Lat: 1, Lng: 2}},
+			Offices: map[string]AddressUdt{"hq": {Street: "High St"}},
+		},
+	}
+	s.NoError(tbl.Set(expected).Run())
+
+	var actual PersonWithUdt
+	s.NoError(tbl.Where(Eq("Id", "1")).ReadOne(&actual).Run())
+	s.Equal(expected, actual)
+}
+
 // Helper functions
 func (s *MockSuite) insertPoints() []point {
 	points := []point{
@@ -460,6 +776,7 @@ func (s *MockSuite) insertUsers() (user, user, user, user) {
 		s.NoError(s.tbl.Set(u).Run())
 		s.NoError(s.mapTbl.Set(u).Run())
 		s.NoError(s.mmapTbl.Set(u).Run())
+		s.NoError(s.smmapTbl.Set(u).Run())
 	}
 
 	return u1, u2, u3, u4
@@ -496,3 +813,197 @@ func (s *MockSuite) parseTime(value string) time.Time {
 	s.NoError(err)
 	return t
 }
+
+func (s *MockSuite) TestTableDeleteColumnsAndRanges() {
+	_, _, u3, u4 := s.insertUsers()
+	partition := []Relation{Eq("Pk1", 1), Eq("Pk2", 1)}
+
+	// Deleting every regular column of a row deletes the row
+	s.NoError(s.tbl.Where(append(partition, Eq("Ck1", 1), Eq("Ck2", 1))...).DeleteColumns("Name").Run())
+	var users []user
+	s.NoError(s.tbl.Where(partition...).Read(&users).Run())
+	s.Equal([]user{u4, u3}, users)
+	s.True(errors.Is(s.tbl.Where(partition...).DeleteColumns("Name").Run(), ErrValidation))
+
+	s.NoError(s.tbl.Where(append(partition, GTE("Ck1", 2))...).Delete().Run())
+	s.NoError(s.tbl.Where(partition...).Read(&users).Run())
+	s.Equal([]user{u4}, users)
+
+	// Partitions without rows are skipped
+	s.NoError(s.tbl.Where(Eq("Pk1", 1), In("Pk2", 3, 2)).Delete().Run())
+	users = nil
+	s.NoError(s.tbl.Where(Eq("Pk1", 1), Eq("Pk2", 2)).Read(&users).Run())
+	s.Empty(users)
+
+	tbl := s.ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+		StaticColumns:     []string{"SellerName"},
+	})
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "1", Price: 10, SellerName: "Jane"}).Run())
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).DeleteColumns("SellerName").Run())
+	var sales []sale
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Read(&sales).Run())
+	s.Equal([]sale{{SellerId: "s1", SaleId: "1", Price: 10}}, sales)
+}
+
+func (s *MockSuite) TestTableCountDistinctAggregate() {
+	tbl := s.ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+		StaticColumns:     []string{"SellerName"},
+	})
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "1", Price: 10, SellerName: "Jane"}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "2", Price: 25, SellerName: "Jane"}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s2", SaleId: "3", Price: 30, SellerName: "John"}).Run())
+
+	var count int64
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Count(&count).Run())
+	s.Equal(int64(2), count)
+	s.NoError(tbl.Where(Eq("SellerId", "s3")).Count(&count).Run())
+	s.Equal(int64(0), count)
+
+	var sellers []sale
+	s.NoError(tbl.Where(In("SellerId", "s1", "s2", "s3")).Distinct(&sellers, "SellerName").Run())
+	s.ElementsMatch([]sale{{SellerId: "s1", SellerName: "Jane"}, {SellerId: "s2", SellerName: "John"}}, sellers)
+	s.True(errors.Is(tbl.Where(Eq("SellerId", "s1")).Distinct(&sellers, "Price").Run(), ErrValidation))
+
+	var max int
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Aggregate(&max, Max("Price")).Run())
+	s.Equal(25, max)
+
+	var stats struct {
+		Min   int   `cql:"min_price"`
+		Avg   int   `cql:"avg_price"`
+		Total int64 `cql:"total"`
+	}
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Aggregate(&stats, Min("Price"), Avg("Price"), Sum("Price").As("total")).Run())
+	s.Equal(10, stats.Min)
+	s.Equal(17, stats.Avg)
+	s.Equal(int64(35), stats.Total)
+}
+
+func (s *MockSuite) TestTablePerPartitionLimitAndGroupBy() {
+	tbl := s.ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+	})
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "1", Price: 10}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "2", Price: 25}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "3", Price: 5}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s2", SaleId: "4", Price: 30}).Run())
+
+	var sales []sale
+	s.NoError(tbl.Where(In("SellerId", "s1", "s2")).Read(&sales).WithOptions(Options{PerPartitionLimit: 2}).Run())
+	s.Len(sales, 3)
+	s.NoError(tbl.Where(In("SellerId", "s1", "s2")).Read(&sales).WithOptions(Options{PerPartitionLimit: 1, Limit: 1}).Run())
+	s.Len(sales, 1)
+
+	// Reads return the first row of each group
+	s.NoError(tbl.Where(In("SellerId", "s1", "s2")).Read(&sales).WithOptions(Options{GroupBy: []string{"SellerId"}}).Run())
+	s.ElementsMatch([]sale{{SellerId: "s1", SaleId: "1", Price: 10}, {SellerId: "s2", SaleId: "4", Price: 30}}, sales)
+
+	var totals []struct {
+		SellerId string
+		Total    int64 `cql:"total"`
+		Max      int   `cql:"max_price"`
+	}
+	s.NoError(tbl.Where(In("SellerId", "s1", "s2")).
+		Aggregate(&totals, Sum("Price").As("total"), Max("Price")).
+		WithOptions(Options{GroupBy: []string{"SellerId"}}).Run())
+	s.Len(totals, 2)
+	for _, total := range totals {
+		switch total.SellerId {
+		case "s1":
+			s.Equal(int64(40), total.Total)
+			s.Equal(25, total.Max)
+		case "s2":
+			s.Equal(int64(30), total.Total)
+			s.Equal(30, total.Max)
+		default:
+			s.Fail("unexpected group", total.SellerId)
+		}
+	}
+
+	s.True(errors.Is(tbl.Where(Eq("SellerId", "s1")).Read(&sales).WithOptions(Options{GroupBy: []string{"SaleId"}}).Run(), ErrValidation))
+}
+
+type profile struct {
+	Id    string
+	Name  string
+	Tags  []string `cql:",omitempty"`
+	Score int      `cql:",omitempty"`
+}
+
+func (s *MockSuite) TestTableOmitEmpty() {
+	tbl := s.ks.MapTable("profiles", "Id", profile{})
+	s.NoError(tbl.Set(profile{Id: "p1", Name: "Jane", Tags: []string{"a"}, Score: 3}).Run())
+
+	// Empty omitempty fields leave the columns as they are
+	s.NoError(tbl.Set(profile{Id: "p1", Name: "Janet"}).Run())
+	var p profile
+	s.NoError(tbl.Read("p1", &p).Run())
+	s.Equal(profile{Id: "p1", Name: "Janet", Tags: []string{"a"}, Score: 3}, p)
+
+	// PartialSet leaves out every empty field
+	s.NoError(tbl.WithOptions(Options{PartialSet: true}).Set(profile{Id: "p1", Score: 4}).Run())
+	s.NoError(tbl.Read("p1", &p).Run())
+	s.Equal(profile{Id: "p1", Name: "Janet", Tags: []string{"a"}, Score: 4}, p)
+	s.NoError(tbl.Update("p1", map[string]interface{}{"Name": "", "Score": 5}).WithOptions(Options{PartialSet: true}).Run())
+	s.NoError(tbl.Read("p1", &p).Run())
+	s.Equal(profile{Id: "p1", Name: "Janet", Tags: []string{"a"}, Score: 5}, p)
+
+	// Unset leaves a column as it is, nil clears it
+	s.NoError(tbl.Update("p1", map[string]interface{}{"Name": Unset, "Tags": nil}).Run())
+	s.NoError(tbl.Read("p1", &p).Run())
+	s.Equal(profile{Id: "p1", Name: "Janet", Score: 5}, p)
+	s.NoError(tbl.Update("p1", map[string]interface{}{"Name": Unset}).Run())
+}
+
+type sale struct {
+	SellerId   string
+	SaleId     string
+	SellerName string
+	Price      int
+}
+
+func (s *MockSuite) TestTableStaticColumns() {
+	tbl := s.ks.Table("sales", sale{}, Keys{
+		PartitionKeys:     []string{"SellerId"},
+		ClusteringColumns: []string{"SaleId"},
+		StaticColumns:     []string{"SellerName"},
+	})
+
+	s.NoError(tbl.Set(map[string]interface{}{"SellerId": "s1", "SellerName": "Jane"}).Run())
+	var sales []sale
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Read(&sales).Run())
+	s.Equal([]sale{{SellerId: "s1", SellerName: "Jane"}}, sales)
+
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "1", Price: 10, SellerName: "Jane"}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s1", SaleId: "2", Price: 20, SellerName: "Jane"}).Run())
+	s.NoError(tbl.Set(sale{SellerId: "s2", SaleId: "3", Price: 30, SellerName: "John"}).Run())
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Update(map[string]interface{}{"SellerName": "Janet"}).Run())
+
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Read(&sales).Run())
+	s.Equal([]sale{
+		{SellerId: "s1", SaleId: "1", Price: 10, SellerName: "Janet"},
+		{SellerId: "s1", SaleId: "2", Price: 20, SellerName: "Janet"},
+	}, sales)
+
+	s.NoError(tbl.Where(Eq("SellerId", "s2")).Read(&sales).Run())
+	s.Equal([]sale{{SellerId: "s2", SaleId: "3", Price: 30, SellerName: "John"}}, sales)
+
+	s.Error(tbl.Where(Eq("SellerId", "s1")).Update(map[string]interface{}{"Price": 1}).Run())
+
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Delete().Run())
+	sales = nil
+	s.NoError(tbl.Where(Eq("SellerId", "s1")).Read(&sales).Run())
+	s.Empty(sales)
+}
+
+func (s *MockSuite) TestSetValidationError() {
+	var verr ValidationError
+	s.True(errors.As(s.tbl.Set(42).Run(), &verr))
+	s.True(errors.As(s.tsTbl.Set(user{}).Run(), &verr))
+	s.Equal("Time", verr.Field)
+}
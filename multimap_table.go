@@ -1,29 +1,95 @@
 package gocassa
 
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// shardFieldName is the column holding the shard of the rows of a sharded multimap
+const shardFieldName = "shard"
+
 type multimapT struct {
 	Table
 	fieldToIndexBy string
 	idField        string
+	// shards is the number of partitions the rows of a value are spread over, or 0 if they are in a single one
+	shards int
+	// idType is the CQL type of the id column, which ids are serialised as to pick their shard
+	idType gocql.Type
+	// desc tells whether the ids are in descending order, which sharded lists merge the shards in
+	desc bool
+}
+
+func (mm *multimapT) Set(v interface{}) Op {
+	if mm.shards == 0 {
+		return mm.Table.Set(v)
+	}
+	m, ok := toWriteMap(v, mm.fieldToIndexBy, mm.idField)
+	if !ok {
+		return &badOp{unrecognizedRowError(mm.Name(), v)}
+	}
+	row := make(map[string]interface{}, len(m)+1)
+	for k, value := range m {
+		row[k] = value
+	}
+	shard, err := mm.shard(m[mm.idField])
+	if err != nil {
+		return &badOp{err}
+	}
+	row[shardFieldName] = shard
+	return mm.Table.Set(row)
 }
 
 func (mm *multimapT) Update(field, id interface{}, m map[string]interface{}) Op {
-	return mm.Where(Eq(mm.fieldToIndexBy, field), Eq(mm.idField, id)).Update(m)
+	rels, err := mm.rowRelations(field, id)
+	if err != nil {
+		return &badOp{err}
+	}
+	return mm.Where(rels...).Update(m)
 }
 
 func (mm *multimapT) Delete(field, id interface{}) Op {
-	return mm.Where(Eq(mm.fieldToIndexBy, field), Eq(mm.idField, id)).Delete()
+	rels, err := mm.rowRelations(field, id)
+	if err != nil {
+		return &badOp{err}
+	}
+	return mm.Where(rels...).Delete()
 }
 
 func (mm *multimapT) DeleteAll(field interface{}) Op {
-	return mm.Where(Eq(mm.fieldToIndexBy, field)).Delete()
+	return mm.Where(mm.partitionRelations(field)...).Delete()
 }
 
 func (mm *multimapT) Read(field, id, pointer interface{}) Op {
-	return mm.Where(Eq(mm.fieldToIndexBy, field), Eq(mm.idField, id)).ReadOne(pointer)
+	rels, err := mm.rowRelations(field, id)
+	if err != nil {
+		return &badOp{err}
+	}
+	return mm.Where(rels...).ReadOne(pointer)
 }
 
 func (mm *multimapT) MultiRead(field interface{}, ids []interface{}, pointerToASlice interface{}) Op {
-	return mm.Where(Eq(mm.fieldToIndexBy, field), In(mm.idField, ids...)).Read(pointerToASlice)
+	if mm.shards == 0 {
+		return mm.Where(Eq(mm.fieldToIndexBy, field), In(mm.idField, ids...)).Read(pointerToASlice)
+	}
+	shards := []interface{}{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		s, err := mm.shard(id)
+		if err != nil {
+			return &badOp{err}
+		}
+		if !seen[s] {
+			seen[s] = true
+			shards = append(shards, s)
+		}
+	}
+	return mm.Where(Eq(mm.fieldToIndexBy, field), In(shardFieldName, shards...), In(mm.idField, ids...)).Read(pointerToASlice)
 }
 
 func (mm *multimapT) List(field, startId interface{}, limit int, pointerToASlice interface{}) Op {
@@ -31,7 +97,23 @@ func (mm *multimapT) List(field, startId interface{}, limit int, pointerToASlice
 	if startId != nil {
 		rels = append(rels, GTE(mm.idField, startId))
 	}
-	return mm.WithOptions(Options{Limit: limit}).(*multimapT).Where(rels...).Read(pointerToASlice)
+	if mm.shards == 0 {
+		return mm.WithOptions(Options{Limit: limit}).(*multimapT).Where(rels...).Read(pointerToASlice)
+	}
+
+	// Each shard is listed up to the limit, and the rows of the shards are merged
+	return &shardedListOp{
+		read: func(shard int) Filter {
+			shardRels := append([]Relation{rels[0], Eq(shardFieldName, shard)}, rels[1:]...)
+			return mm.Table.WithOptions(Options{Limit: limit}).Where(shardRels...)
+		},
+		table:   mm.Name(),
+		shards:  mm.shards,
+		idField: mm.idField,
+		limit:   limit,
+		desc:    mm.desc,
+		pointer: pointerToASlice,
+	}
 }
 
 func (mm *multimapT) WithOptions(o Options) MultimapTable {
@@ -39,5 +121,212 @@ func (mm *multimapT) WithOptions(o Options) MultimapTable {
 		Table:          mm.Table.WithOptions(o),
 		fieldToIndexBy: mm.fieldToIndexBy,
 		idField:        mm.idField,
+		shards:         mm.shards,
+		idType:         mm.idType,
+		desc:           idDescending(mm.idField, o, mm.desc),
+	}
+}
+
+// idDescending tells whether the ids are in descending order with the clustering order of o, given whether they were
+// before
+func idDescending(idField string, o Options, desc bool) bool {
+	for _, c := range o.ClusteringOrder {
+		if c.Column == idField {
+			return c.Direction == DESC
+		}
+	}
+	return desc
+}
+
+// shard returns the shard of the row with an id. It hashes the id as it is serialised for the id column, so that equal
+// ids are in the same shard whatever their Go type, or the location of times.
+func (mm *multimapT) shard(id interface{}) (shard int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = ValidationError{Table: mm.Name(), Field: mm.idField, Reason: fmt.Sprintf("can not serialise %T as an id: %v", id, r)}
+		}
+	}()
+	h := fnv.New32a()
+	h.Write(keyBytes(mm.idType, id))
+	return int(h.Sum32() % uint32(mm.shards)), nil
+}
+
+// rowRelations returns the relations selecting the row with an id
+func (mm *multimapT) rowRelations(field, id interface{}) ([]Relation, error) {
+	if mm.shards == 0 {
+		return []Relation{Eq(mm.fieldToIndexBy, field), Eq(mm.idField, id)}, nil
+	}
+	shard, err := mm.shard(id)
+	if err != nil {
+		return nil, err
+	}
+	return []Relation{Eq(mm.fieldToIndexBy, field), Eq(shardFieldName, shard), Eq(mm.idField, id)}, nil
+}
+
+// partitionRelations returns the relations selecting the partitions of the rows of a value
+func (mm *multimapT) partitionRelations(field interface{}) []Relation {
+	if mm.shards == 0 {
+		return []Relation{Eq(mm.fieldToIndexBy, field)}
+	}
+	shards := make([]interface{}, mm.shards)
+	for s := range shards {
+		shards[s] = s
+	}
+	return []Relation{Eq(mm.fieldToIndexBy, field), In(shardFieldName, shards...)}
+}
+
+// shardedListOp lists the rows of a value of a sharded multimap. It reads the shards in parallel into slices of the
+// type of pointer, and merges their rows in the order of their ids.
+type shardedListOp struct {
+	// read returns the filter of the rows of a shard
+	read    func(shard int) Filter
+	table   string
+	shards  int
+	options Options
+	idField string
+	limit   int
+	desc    bool
+	pointer interface{}
+}
+
+// reads returns the reads of the shards into results, which are pointers to slices
+func (o *shardedListOp) reads(results []reflect.Value) []Op {
+	reads := make([]Op, o.shards)
+	for s := range reads {
+		reads[s] = o.read(s).Read(results[s].Interface()).WithOptions(o.options)
+	}
+	return reads
+}
+
+// results returns the pointers to the slices the shards are read into
+func (o *shardedListOp) results() []reflect.Value {
+	results := make([]reflect.Value, o.shards)
+	for s := range results {
+		results[s] = reflect.New(reflect.TypeOf(o.pointer).Elem())
 	}
+	return results
+}
+
+func (o *shardedListOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	results := o.results()
+	errs := make([]error, o.shards)
+	wg := sync.WaitGroup{}
+	for s, read := range o.reads(results) {
+		wg.Add(1)
+		go func(s int, read Op) {
+			defer wg.Done()
+			errs[s] = read.Run()
+		}(s, read)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	out := reflect.ValueOf(o.pointer).Elem()
+	rows := reflect.MakeSlice(out.Type(), 0, 0)
+	for _, result := range results {
+		rows = reflect.AppendSlice(rows, result.Elem())
+	}
+	ids := make([]*keyPart, rows.Len())
+	for i := range ids {
+		ids[i] = &keyPart{Key: o.idField, Value: o.id(rows.Index(i)), Type: gocql.TypeCustom}
+	}
+	sort.Stable(shardedRows{swap: reflect.Swapper(rows.Interface()), ids: ids, desc: o.desc})
+	if o.limit > 0 && rows.Len() > o.limit {
+		rows = rows.Slice(0, o.limit)
+	}
+	out.Set(rows)
+	return nil
+}
+
+// id returns the id of a row read, which is a struct or a map
+func (o *shardedListOp) id(row reflect.Value) interface{} {
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		if row.IsNil() {
+			return nil
+		}
+		row = row.Elem()
+	}
+	switch row.Kind() {
+	case reflect.Map:
+		if m, ok := row.Interface().(map[string]interface{}); ok {
+			return columnValue(m, o.idField)
+		}
+	case reflect.Struct:
+		if index, ok := keyFieldIndex(row.Type(), o.idField); ok {
+			if field, err := row.FieldByIndexErr(index); err == nil {
+				return field.Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// shardedRows sorts the rows of the shards by their ids
+type shardedRows struct {
+	swap func(i, j int)
+	ids  []*keyPart
+	desc bool
+}
+
+func (r shardedRows) Len() int {
+	return len(r.ids)
+}
+
+func (r shardedRows) Less(i, j int) bool {
+	if r.desc {
+		return r.ids[j].Compare(r.ids[i]) < 0
+	}
+	return r.ids[i].Compare(r.ids[j]) < 0
+}
+
+func (r shardedRows) Swap(i, j int) {
+	r.swap(i, j)
+	r.ids[i], r.ids[j] = r.ids[j], r.ids[i]
+}
+
+func (o *shardedListOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *shardedListOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *shardedListOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.options = o.options.Merge(opts)
+	if opts.Limit > 0 {
+		ret.limit = opts.Limit
+	}
+	ret.desc = idDescending(o.idField, opts, o.desc)
+	return &ret
+}
+
+func (o *shardedListOp) Preflight() error {
+	rt := reflect.TypeOf(o.pointer)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice {
+		return ValidationError{Table: o.table, Reason: fmt.Sprintf("can not list into %T, a pointer to a slice is required", o.pointer)}
+	}
+	for _, read := range o.reads(o.results()) {
+		if err := read.Preflight(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateStatement returns the statement of the read of the first shard
+func (o *shardedListOp) GenerateStatement() (string, []interface{}) {
+	return o.read(0).Read(o.pointer).WithOptions(o.options).GenerateStatement()
+}
+
+func (o *shardedListOp) QueryExecutor() QueryExecutor {
+	return o.read(0).Read(o.pointer).WithOptions(o.options).QueryExecutor()
 }
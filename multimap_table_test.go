@@ -1,8 +1,13 @@
 package gocassa
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
 )
 
 type Customer2 struct {
@@ -168,3 +173,139 @@ func TestMultimapTableMultiListOrder(t *testing.T) {
 		t.Fatalf("Expected to find joe, got %v", (customers)[1])
 	}
 }
+
+func TestShardedMultimapTableStatements(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.ShardedMultimapTable("customer", "Tag", "Id", 8, Customer2{})
+
+	stmt, err := tbl.(TableChanger).CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stmt, "CREATE TABLE some_ks.customer_multimap_Tag_Id_8shards (") || !strings.Contains(stmt, "PRIMARY KEY ((tag, shard), id)") {
+		t.Fatalf("expected the rows of a tag to be sharded, got %s", stmt)
+	}
+
+	shard, _ := tbl.(*multimapT).shard("33")
+	stmt, params := tbl.Read("A", "33", &Customer2{}).GenerateStatement()
+	if !strings.HasSuffix(stmt, " WHERE tag = ? AND shard = ? AND id = ?") || params[1] != shard {
+		t.Fatalf("expected the read to be routed to shard %d, got %q %v", shard, stmt, params)
+	}
+	stmt, _ = tbl.List("A", nil, 10, &[]Customer2{}).GenerateStatement()
+	if !strings.HasSuffix(stmt, " WHERE tag = ? AND shard = ? LIMIT ?") {
+		t.Fatalf("expected the list of each shard to be limited, got %q", stmt)
+	}
+
+	if _, err := ks.ShardedMultimapTableE("customer", "Tag", "Id", 0, Customer2{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError without shards, got %v", err)
+	}
+	type sharded struct {
+		Id, Tag, Shard string
+	}
+	if _, err := ks.ShardedMultimapTableE("customer", "Tag", "Id", 8, sharded{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a row with a shard field, got %v", err)
+	}
+}
+
+func TestShardedMultimapTableListDecode(t *testing.T) {
+	type pricedTask struct {
+		Queue    string
+		Id       int
+		Priority codecTestPriority
+		Budget   OpTestMoney
+	}
+	native := func(typ gocql.Type) gocql.NativeType {
+		return gocql.NewNativeType(4, typ, "")
+	}
+	columns := []gocql.ColumnInfo{
+		{Name: "queue", TypeInfo: native(gocql.TypeVarchar)},
+		{Name: "shard", TypeInfo: native(gocql.TypeInt)},
+		{Name: "id", TypeInfo: native(gocql.TypeInt)},
+		{Name: "priority", TypeInfo: native(gocql.TypeInt)},
+		{Name: "budget", TypeInfo: native(gocql.TypeBigInt)},
+	}
+	// Every shard reads the same rows, which are merged in the order of their ids
+	qe := newScanQE(columns,
+		[]interface{}{"q", 0, 3, 2, int64(300)},
+		[]interface{}{"q", 0, 1, 0, int64(100)},
+	)
+	tbl := (&connection{q: qe}).KeySpace("some_ks").ShardedMultimapTable("tasks", "Queue", "Id", 2, pricedTask{})
+
+	var tasks []pricedTask
+	if err := tbl.List("q", nil, 3, &tasks).Run(); err != nil {
+		t.Fatal(err)
+	}
+	low, high := pricedTask{"q", 1, "low", OpTestMoney{100}}, pricedTask{"q", 3, "high", OpTestMoney{300}}
+	if expected := []pricedTask{low, low, high}; !reflect.DeepEqual(tasks, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, tasks)
+	}
+	var pointers []*pricedTask
+	if err := tbl.List("q", nil, 0, &pointers).Run(); err != nil || len(pointers) != 4 || *pointers[3] != high {
+		t.Fatalf("expected pointers to the rows, got %+v, %v", pointers, err)
+	}
+	var maps []map[string]interface{}
+	if err := tbl.List("q", nil, 0, &maps).Run(); err != nil || len(maps) != 4 || maps[0]["id"] != 1 || maps[3]["id"] != 3 {
+		t.Fatalf("expected the rows as maps, got %v, %v", maps, err)
+	}
+	if err := tbl.List("q", nil, 0, tasks).Run(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a slice which is not a pointer, got %v", err)
+	}
+}
+
+func TestShardedMultimapTableShards(t *testing.T) {
+	type event struct {
+		Kind string
+		At   time.Time
+		Seq  int
+	}
+	ks := NewMockKeySpace()
+	byTime := ks.ShardedMultimapTable("events", "Kind", "At", 16, event{}).(*multimapT)
+	bySeq := ks.ShardedMultimapTable("events", "Kind", "Seq", 16, event{}).(*multimapT)
+
+	// Equal ids are in the same shard whatever their location, monotonic reading or Go type
+	at := time.Now()
+	for _, other := range []time.Time{at.UTC(), at.In(time.FixedZone("CET", 3600)), at.Round(0), at.Truncate(time.Millisecond)} {
+		a, errA := byTime.shard(at)
+		b, errB := byTime.shard(other)
+		if errA != nil || errB != nil || a != b {
+			t.Fatalf("expected %v and %v to be in the same shard, got %d and %d, %v %v", at, other, a, b, errA, errB)
+		}
+	}
+	shards := map[int]bool{}
+	for _, seq := range []interface{}{7, int32(7), int64(7), int16(7)} {
+		shard, err := bySeq.shard(seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards[shard] = true
+	}
+	if len(shards) != 1 {
+		t.Fatalf("expected the ids of 7 to be in a single shard, got %v", shards)
+	}
+	if _, err := bySeq.shard("seven"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for an id which is not an int, got %v", err)
+	}
+
+	// So rows written with an id are read and deleted with an equal one
+	row := event{Kind: "click", At: at.In(time.FixedZone("CET", 3600)), Seq: 7}
+	if err := byTime.Set(row).Run(); err != nil {
+		t.Fatal(err)
+	}
+	read := event{}
+	if err := byTime.Read("click", at.UTC(), &read).Run(); err != nil || read.Seq != 7 {
+		t.Fatalf("expected the row written with a time in another location, got %+v, %v", read, err)
+	}
+	if err := byTime.Delete("click", at.Round(0)).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := byTime.Read("click", at, &read).Run(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the row to be deleted, got %v", err)
+	}
+	if err := bySeq.Set(row).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bySeq.Read("click", int64(7), &read).Run(); err != nil {
+		t.Fatalf("expected the row written with an int id to be read with an int64 one, got %v", err)
+	}
+}
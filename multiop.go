@@ -1,5 +1,7 @@
 package gocassa
 
+import "fmt"
+
 type multiOp []Op
 
 func Noop() Op {
@@ -25,14 +27,45 @@ func (mo multiOp) RunAtomically() error {
 	stmts := make([]string, len(mo))
 	vals := make([][]interface{}, len(mo))
 	var qe QueryExecutor
+	counters := 0
 	for i, op := range mo {
+		if _, ok := op.(interface{ counterUpdate() }); ok {
+			counters++
+		}
+		if c, ok := op.(interface{ conditional() bool }); ok && c.conditional() {
+			// A lightweight transaction is atomic on its own, and its result would be lost in a batch
+			if len(mo) > 1 {
+				return ValidationError{Reason: "lightweight transactions can not be run atomically with other statements"}
+			}
+			return op.RunAtomically()
+		}
 		s, v := op.GenerateStatement()
 		qe = op.QueryExecutor()
 		stmts[i] = s
 		vals[i] = v
 	}
+	if counters > 0 && counters != len(mo) {
+		return ValidationError{Reason: "counter updates can not be run atomically with other statements"}
+	}
+	if qe == nil {
+		// Ops without a query executor, eg. those of MockTable, apply their changes themselves
+		for _, op := range mo {
+			if err := op.RunAtomically(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-	return qe.ExecuteAtomically(stmts, vals)
+	if counters == 0 {
+		return wrapBatchError(stmts, qe.ExecuteAtomically(stmts, vals))
+	}
+	// Counter updates can only be batched together, in a counter batch
+	cqe, ok := qe.(CounterBatchExecutor)
+	if !ok {
+		return ValidationError{Reason: fmt.Sprintf("%T can not run counter batches", qe)}
+	}
+	return wrapBatchError(stmts, cqe.ExecuteCounterBatch(stmts, vals))
 }
 
 func (mo multiOp) GenerateStatement() (string, []interface{}) {
@@ -6,9 +6,16 @@ import (
 	"time"
 )
 
+// Bucketer splits the rows of time series tables into partitions, or buckets, by their time. Buckets are
+// identified by their start in Unix milliseconds, eg. DayBucketer(loc) or a fixed duration since the epoch.
 type Bucketer interface {
+	// Bucket returns the bucket of the given Unix time in seconds
 	Bucket(int64) int64
+	// Next returns the bucket following the given bucket
 	Next(int64) int64
+	// Prev returns the bucket preceding the given bucket
+	Prev(int64) int64
+	// String names the bucketer in table names
 	String() string
 }
 
@@ -19,6 +26,7 @@ type multiTimeSeriesT struct {
 	idField     string
 	bucketSize  time.Duration
 	bucketer    Bucketer
+	options     Options
 }
 
 type tsBucketer struct {
@@ -33,17 +41,21 @@ func (b *tsBucketer) Next(secs int64) int64 {
 	return secs + int64(b.bucketSize/time.Second)*1000
 }
 
+func (b *tsBucketer) Prev(secs int64) int64 {
+	return secs - int64(b.bucketSize/time.Second)*1000
+}
+
 func (b *tsBucketer) String() string {
 	return b.bucketSize.String()
 }
 
 func (o *multiTimeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toWriteMap(v, append([]string{o.timeField, o.idField}, o.indexFields...)...)
 	if !ok {
-		panic("Can't set: not able to convert")
+		return &badOp{unrecognizedRowError(o.Name(), v)}
 	}
 	if tim, ok := m[o.timeField].(time.Time); !ok {
-		panic("timeField is not actually a time.Time")
+		return &badOp{ValidationError{Table: o.Name(), Field: o.timeField, Reason: fmt.Sprintf("%T is not a time.Time", m[o.timeField])}}
 	} else {
 		m[bucketFieldName] = o.bucket(tim.Unix())
 	}
@@ -85,18 +97,50 @@ func (o *multiTimeSeriesT) Read(v interface{}, timeStamp time.Time, id, pointer
 }
 
 func (o *multiTimeSeriesT) List(v interface{}, startTime time.Time, endTime time.Time, pointerToASlice interface{}) Op {
-	buckets := []interface{}{}
-	start := o.bucket(startTime.Unix())
-	end := o.bucket(endTime.Unix())
-	for i := start; i <= end; i = o.bucketer.Next(i) { // nearly but not quite an iterator
-		buckets = append(buckets, i)
+	idxs, err := o.indexes(v)
+	if err != nil {
+		return &badOp{err}
+	}
+	return &bucketsOp{
+		table: o.Name(),
+		where: func(relations ...Relation) Filter {
+			return o.Where(append(append([]Relation{}, idxs...), relations...)...)
+		},
+		bucketer:  o.bucketer,
+		start:     o.bucket(startTime.Unix()),
+		end:       o.bucket(endTime.Unix()),
+		relations: []Relation{GTE(o.timeField, startTime), LTE(o.timeField, endTime)},
+		result:    pointerToASlice,
+		options:   o.options,
 	}
+}
+
+func (o *multiTimeSeriesT) ListRange(v interface{}, startTime, endTime time.Time, order ColumnDirection, limit int, cursor Cursor, pointerToASlice interface{}, next *Cursor) Op {
 	idxs, err := o.indexes(v)
 	if err != nil {
 		return &badOp{err}
 	}
-	relations := fRelations(idxs, In(bucketFieldName, buckets...), GTE(o.timeField, startTime), LTE(o.timeField, endTime))
-	return o.Where(relations...).Read(pointerToASlice)
+	return &rangeOp{
+		table: o.Name(),
+		where: func(relations ...Relation) Filter {
+			return o.Where(append(append([]Relation{}, idxs...), relations...)...)
+		},
+		bucketer:  o.bucketer,
+		timeField: o.timeField,
+		idField:   o.idField,
+		start:     startTime,
+		end:       endTime,
+		order:     order,
+		limit:     limit,
+		cursor:    cursor,
+		result:    pointerToASlice,
+		next:      next,
+		options:   o.options,
+	}
+}
+
+func (o *multiTimeSeriesT) ListLatest(v interface{}, since time.Time, limit int, pointerToASlice interface{}) Op {
+	return o.ListRange(v, since, time.Now(), DESC, limit, "", pointerToASlice, nil)
 }
 
 func (o *multiTimeSeriesT) WithOptions(opt Options) MultiTimeSeriesTable {
@@ -106,6 +150,7 @@ func (o *multiTimeSeriesT) WithOptions(opt Options) MultiTimeSeriesTable {
 		timeField:   o.timeField,
 		idField:     o.idField,
 		bucketer:    o.bucketer,
+		options:     o.options.Merge(opt),
 	}
 }
 
@@ -132,7 +177,7 @@ func (o *multiTimeSeriesT) indexes(iv interface{}) ([]Relation, error) {
 		}
 	}
 	if ni != len(o.indexFields) {
-		return nil, fmt.Errorf("Indexes incomplete: %+v", o.indexFields)
+		return nil, ValidationError{Reason: fmt.Sprintf("Indexes incomplete: %+v", o.indexFields)}
 	}
 	return indexes, nil
 }
@@ -146,7 +191,7 @@ func (o *multiTimeSeriesT) indexesAsMap(v interface{}) (map[string]interface{},
 		return vt, nil
 	default:
 		if len(o.indexFields) != 1 {
-			return nil, fmt.Errorf("Must pass map of values if more than one indexField (have: %d): got: %+v", len(o.indexFields), v)
+			return nil, ValidationError{Reason: fmt.Sprintf("Must pass map of values if more than one indexField (have: %d): got: %+v", len(o.indexFields), v)}
 		}
 		return map[string]interface{}{o.indexFields[0]: v}, nil
 	}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"math/big"
+	"net"
 	"reflect"
-	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
 	rreflect "github.com/gocassa/gocassa/reflect"
 	"github.com/mitchellh/mapstructure"
+	"gopkg.in/inf.v0"
 )
 
 const (
@@ -26,7 +29,13 @@ type singleOp struct {
 	opType  uint8
 	result  interface{}
 	m       map[string]interface{} // map for updates, sets etc
-	qe      QueryExecutor
+	columns []string               // columns to delete, all if empty
+	// projection replaces the columns selected by reads, eg. with COUNT(*)
+	projection *projection
+	// row tells whether the op writes a row with Set, which still writes its keys when PartialSet leaves out the
+	// other columns
+	row bool
+	qe  QueryExecutor
 }
 
 // Used to pass errors back through the fluent API
@@ -35,13 +44,42 @@ type badOp struct {
 }
 
 func (o *singleOp) WithOptions(opts Options) Op {
-	return &singleOp{
-		options: o.options.Merge(opts),
-		f:       o.f,
-		opType:  o.opType,
-		result:  o.result,
-		m:       o.m,
-		qe:      o.qe}
+	ret := &singleOp{
+		options:    o.options.Merge(opts),
+		f:          o.f,
+		opType:     o.opType,
+		result:     o.result,
+		m:          o.m,
+		columns:    o.columns,
+		projection: o.projection,
+		row:        o.row,
+		qe:         o.qe}
+	if (o.opType == updateOpType || o.opType == insertOpType) &&
+		!o.f.t.options.Merge(o.options).PartialSet && o.f.t.options.Merge(ret.options).PartialSet {
+		return ret.partial()
+	}
+	return ret
+}
+
+// partial leaves out the empty columns of a write once PartialSet is set on it, as Set and Update do when it is set
+// on the table
+func (o *singleOp) partial() Op {
+	m := withoutEmpty(o.m, o.f.t.info.keys)
+	switch {
+	case len(m) > 0 || len(o.m) == 0:
+		o.m = m
+	case !o.row:
+		// Every column was left out, so there is nothing to write
+		return Noop()
+	default:
+		// Set still inserts the keys of the row
+		keys := map[string]interface{}{}
+		for _, r := range o.f.rs {
+			keys[r.key] = r.terms[0]
+		}
+		o.opType, o.m, o.f = insertOpType, keys, filter{t: o.f.t}
+	}
+	return o
 }
 
 func (o *singleOp) Add(additions ...Op) Op {
@@ -49,7 +87,19 @@ func (o *singleOp) Add(additions ...Op) Op {
 }
 
 func (o *singleOp) Preflight() error {
-	return nil
+	opts := o.f.t.options.Merge(o.options)
+	if err := validateRelations(o.f.t.Name(), o.f.t.info.keys, o.f.rs, o.opType, opts.AllowFiltering, o.m); err != nil {
+		return err
+	}
+	if o.opType == readOpType || o.opType == singleReadOpType {
+		if err := validateReadOptions(o.f.t.Name(), o.f.t.info.keys, opts); err != nil {
+			return err
+		}
+	}
+	if err := validateConditions(o.f.t.Name(), o.f.t.info.keys, o.f.rs, o.opType, opts); err != nil {
+		return err
+	}
+	return validateProjection(o.f.t.Name(), o.f.t.info.keys, o.f.rs, o.projection)
 }
 
 func newWriteOp(qe QueryExecutor, f filter, opType uint8, m map[string]interface{}) *singleOp {
@@ -60,11 +110,44 @@ func newWriteOp(qe QueryExecutor, f filter, opType uint8, m map[string]interface
 		m:      m}
 }
 
+// rowScanner is implemented by the QueryExecutors which can scan rows into values of a rowDecoder instead of returning
+// them as maps, as the gocql one does, so that reads into structs don't build a map per row
+type rowScanner interface {
+	scanWithOptions(opts Options, stmt string, params []interface{}, d rowDecoder) error
+}
+
+// scan reads the rows straight into the struct, or slice of structs, result if the QueryExecutor can scan them. It
+// returns false if it can't, leaving the rows to be read as maps.
+func (w *singleOp) scan(stmt string, params []interface{}, one bool) (bool, error) {
+	rs, ok := w.qe.(rowScanner)
+	if !ok || w.projection != nil {
+		return false, nil
+	}
+	d := newStructDecoder(w.result, one)
+	if d == nil {
+		return false, nil
+	}
+	err := rs.scanWithOptions(w.options, stmt, params, d)
+	switch {
+	case d.err != nil:
+		return true, d.err
+	case err != nil:
+		return true, wrapQueryError(w.f.t.Name(), stmt, err)
+	case one && d.n == 0:
+		return true, newRowNotFoundError(w.f.t.Name(), stmt)
+	}
+	d.finish()
+	return true, nil
+}
+
 func (w *singleOp) read() error {
 	stmt, params := w.generateRead(w.options)
+	if ok, err := w.scan(stmt, params, false); ok {
+		return err
+	}
 	maps, err := w.qe.QueryWithOptions(w.options, stmt, params...)
 	if err != nil {
-		return err
+		return wrapQueryError(w.f.t.Name(), stmt, err)
 	}
 
 	return decodeResult(maps, w.result)
@@ -72,26 +155,56 @@ func (w *singleOp) read() error {
 
 func (w *singleOp) readOne() error {
 	stmt, params := w.generateRead(w.options)
+	if ok, err := w.scan(stmt, params, true); ok {
+		return err
+	}
 	maps, err := w.qe.QueryWithOptions(w.options, stmt, params...)
 	if err != nil {
-		return err
+		return wrapQueryError(w.f.t.Name(), stmt, err)
 	}
 	if len(maps) == 0 {
-		_, f, n, _ := runtime.Caller(3)
-		return RowNotFoundError{
-			file: f,
-			line: n,
-		}
+		return newRowNotFoundError(w.f.t.Name(), stmt)
+	}
+	if w.projection != nil {
+		return decodeProjected(maps[0], w.projection.names, w.result)
 	}
 	return decodeResult(maps[0], w.result)
 }
 
 func (w *singleOp) write() error {
 	stmt, params := w.generateWrite(w.options)
-	return w.qe.ExecuteWithOptions(w.options, stmt, params...)
+	if !w.conditional() {
+		return wrapQueryError(w.f.t.Name(), stmt, w.qe.ExecuteWithOptions(w.options, stmt, params...))
+	}
+	// Lightweight transactions return whether they were applied, and the current row if they were not
+	maps, err := w.qe.QueryWithOptions(w.options, stmt, params...)
+	if err != nil {
+		return wrapQueryError(w.f.t.Name(), stmt, err)
+	}
+	if len(maps) == 0 {
+		return nil
+	}
+	if applied, _ := maps[0]["[applied]"].(bool); applied {
+		return nil
+	}
+	current := map[string]interface{}{}
+	for k, v := range maps[0] {
+		if k != "[applied]" {
+			current[k] = v
+		}
+	}
+	return NotAppliedError{Table: w.f.t.Name(), Statement: stmt, Current: current}
+}
+
+// conditional tells whether the op is a lightweight transaction
+func (o *singleOp) conditional() bool {
+	return o.opType != readOpType && o.opType != singleReadOpType && o.f.t.options.Merge(o.options).conditional()
 }
 
 func (o *singleOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
 	switch o.opType {
 	case updateOpType, insertOpType, deleteOpType:
 		return o.write()
@@ -156,19 +269,43 @@ func (o *badOp) QueryExecutor() QueryExecutor {
 func (o *singleOp) generateWrite(opt Options) (string, []interface{}) {
 	var str string
 	var vals []interface{}
-	switch o.opType {
-	case updateOpType:
-		stmt, uvals := updateStatement(o.f.t.keySpace.name, o.f.t.Name(), o.m, o.f.t.options.Merge(opt))
+	mopt := o.f.t.options.Merge(opt)
+	switch {
+	case o.opType == updateOpType && mopt.IfNotExists:
+		// Only inserts can be conditional on the row not existing, eg. those of Set
+		row := map[string]interface{}{}
+		for k, v := range o.m {
+			row[k] = v
+		}
+		for _, r := range o.f.rs {
+			row[r.key] = r.terms[0]
+		}
+		fields, insertVals := keyValues(row)
+		str = insertStatement(o.f.t.keySpace.name, o.f.t.Name(), fields, mopt)
+		vals = marshalValues(insertVals)
+	case o.opType == updateOpType:
+		stmt, uvals := updateStatement(o.f.t.keySpace.name, o.f.t.Name(), o.m, mopt)
 		whereStmt, whereVals := generateWhere(o.f.rs)
-		str = stmt + whereStmt
-		vals = append(uvals, whereVals...)
-	case deleteOpType:
+		ifStmt, ifVals := conditionClause(mopt)
+		str = stmt + whereStmt + ifStmt
+		vals = append(append(uvals, whereVals...), ifVals...)
+	case o.opType == deleteOpType:
 		str, vals = generateWhere(o.f.rs)
-		str = fmt.Sprintf("DELETE FROM %s.%s%s", o.f.t.keySpace.name, o.f.t.Name(), str)
-	case insertOpType:
+		columns := ""
+		if len(o.columns) > 0 {
+			columns = strings.ToLower(strings.Join(o.columns, ", ")) + " "
+		}
+		using := usingClause(mopt, false)
+		if using != "" {
+			using = " " + using
+		}
+		ifStmt, ifVals := conditionClause(mopt)
+		str = fmt.Sprintf("DELETE %sFROM %s.%s%s%s%s", columns, o.f.t.keySpace.name, o.f.t.Name(), using, str, ifStmt)
+		vals = append(vals, ifVals...)
+	case o.opType == insertOpType:
 		fields, insertVals := keyValues(o.m)
-		str = insertStatement(o.f.t.keySpace.name, o.f.t.Name(), fields, o.f.t.options.Merge(opt))
-		vals = insertVals
+		str = insertStatement(o.f.t.keySpace.name, o.f.t.Name(), fields, mopt)
+		vals = marshalValues(insertVals)
 	}
 	if o.f.t.keySpace.debugMode {
 		fmt.Println(str, vals)
@@ -181,7 +318,11 @@ func (o *singleOp) generateRead(opt Options) (string, []interface{}) {
 	mopt := o.f.t.options.Merge(opt)
 	ord, ov := o.generateOrderBy(mopt)
 	lim, lv := o.generateLimit(mopt)
-	stmt := fmt.Sprintf("SELECT %s FROM %s.%s", o.f.t.generateFieldNames(mopt.Select), o.f.t.keySpace.name, o.f.t.Name())
+	fields := o.f.t.generateFieldNames(mopt.Select)
+	if o.projection != nil {
+		fields = o.projection.withGroupBy(mopt.GroupBy).cql()
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s.%s", fields, o.f.t.keySpace.name, o.f.t.Name())
 	vals := []interface{}{}
 	buf := new(bytes.Buffer)
 	buf.WriteString(stmt)
@@ -190,17 +331,25 @@ func (o *singleOp) generateRead(opt Options) (string, []interface{}) {
 		buf.WriteString(w)
 		vals = append(vals, wv...)
 	}
+	if len(mopt.GroupBy) > 0 {
+		buf.WriteString(" GROUP BY ")
+		buf.WriteString(strings.ToLower(strings.Join(mopt.GroupBy, ", ")))
+	}
 	if ord != "" {
 		buf.WriteString(" ")
 		buf.WriteString(ord)
 		vals = append(vals, ov...)
 	}
+	if mopt.PerPartitionLimit > 0 {
+		buf.WriteString(" PER PARTITION LIMIT ?")
+		vals = append(vals, mopt.PerPartitionLimit)
+	}
 	if lim != "" {
 		buf.WriteString(" ")
 		buf.WriteString(lim)
 		vals = append(vals, lv...)
 	}
-	if opt.AllowFiltering {
+	if mopt.AllowFiltering {
 		buf.WriteString(" ")
 		buf.WriteString("ALLOW FILTERING")
 	}
@@ -251,24 +400,52 @@ func generateWhere(rs []Relation) (string, []interface{}) {
 			s, v := r.cql()
 			buf.WriteString(s)
 			if r.op == in {
-				vals = append(vals, v)
+				vals = append(vals, marshalValues(v))
 				continue
 			}
-			vals = append(vals, v...)
+			vals = append(vals, marshalValues(v)...)
 		}
 	}
 	return buf.String(), vals
 }
 
+// usingClause returns the USING clause setting the TTL, if ttl is true, and the timestamp of a write, or an empty
+// string if neither is set
+func usingClause(opts Options, ttl bool) string {
+	var parts []string
+	if ttl && opts.TTL != 0 {
+		parts = append(parts, "TTL "+strconv.FormatFloat(opts.TTL.Seconds(), 'f', 0, 64))
+	}
+	if !opts.Timestamp.IsZero() {
+		parts = append(parts, "TIMESTAMP "+strconv.FormatInt(opts.Timestamp.UnixNano()/int64(time.Microsecond), 10))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "USING " + strings.Join(parts, " AND ")
+}
+
+// conditionClause returns the IF clause of the updates and deletes made lightweight transactions by opts, or an
+// empty string if there is none
+func conditionClause(opts Options) (string, []interface{}) {
+	if opts.IfExists {
+		return " IF EXISTS", []interface{}{}
+	}
+	if len(opts.Conditions) == 0 {
+		return "", []interface{}{}
+	}
+	str, vals := generateWhere(opts.Conditions)
+	return strings.Replace(str, " WHERE ", " IF ", 1), vals
+}
+
 // UPDATE keyspace.Movies SET col1 = val1, col2 = val2
 func updateStatement(kn, cfName string, fields map[string]interface{}, opts Options) (string, []interface{}) {
 	buf := new(bytes.Buffer)
 	buf.WriteString(fmt.Sprintf("UPDATE %s.%s ", kn, cfName))
 
 	// Apply options
-	if opts.TTL != 0 {
-		buf.WriteString("USING TTL ")
-		buf.WriteString(strconv.FormatFloat(opts.TTL.Seconds(), 'f', 0, 64))
+	if using := usingClause(opts, true); using != "" {
+		buf.WriteString(using)
 		buf.WriteRune(' ')
 	}
 
@@ -285,7 +462,7 @@ func updateStatement(kn, cfName string, fields map[string]interface{}, opts Opti
 			ret = append(ret, vals...)
 		} else {
 			buf.WriteString(k + " = ?")
-			ret = append(ret, v)
+			ret = append(ret, marshalValue(v))
 		}
 		i++
 	}
@@ -293,7 +470,17 @@ func updateStatement(kn, cfName string, fields map[string]interface{}, opts Opti
 	return buf.String(), ret
 }
 
+// decodeResult decodes rows read from Cassandra, or a single row, into result
 func decodeResult(m, result interface{}) error {
+	if ok, err := decodeDirect(m, result); ok {
+		return err
+	}
+	return decodeWithMapstructure(m, result)
+}
+
+// decodeWithMapstructure is the fallback of decodeResult for results which are not structs or slices of structs,
+// eg. maps
+func decodeWithMapstructure(m, result interface{}) error {
 	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		ZeroFields:       true,
 		WeaklyTypedInput: true,
@@ -301,6 +488,9 @@ func decodeResult(m, result interface{}) error {
 		TagName:          rreflect.TagName,
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			decodeBigIntHook,
+			decodeDecimalHook,
+			decodeInetHook,
+			decodeTupleHook,
 		),
 	})
 	if err != nil {
@@ -336,3 +526,89 @@ func decodeBigIntHook(f reflect.Kind, t reflect.Kind, data interface{}) (interfa
 
 	return data, nil
 }
+
+var (
+	bigFloatType = reflect.TypeOf(big.Float{})
+	netIPType    = reflect.TypeOf(net.IP{})
+)
+
+// decodeDecimalHook decodes CQL decimals, which gocql reads as *inf.Dec, into big.Float fields
+func decodeDecimalHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	dec, ok := data.(*inf.Dec)
+	if !ok || dec == nil || (t != bigFloatType && t != reflect.PtrTo(bigFloatType)) {
+		return data, nil
+	}
+	fl, ok := new(big.Float).SetString(dec.String())
+	if !ok {
+		return nil, fmt.Errorf("can not decode decimal %v into %v", dec, t)
+	}
+	return fl, nil
+}
+
+// decodeInetHook decodes CQL inets, which gocql reads as strings, into net.IP fields
+func decodeInetHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t != netIPType {
+		return data, nil
+	}
+	s := reflect.ValueOf(data).String()
+	if s == "" {
+		return net.IP(nil), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("can not decode %q into %v", s, t)
+	}
+	return ip, nil
+}
+
+// decodeTupleHook decodes tuples, which are read as a slice of their elements, into structs implementing Tuple
+func decodeTupleHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if (f.Kind() != reflect.Slice && f.Kind() != reflect.Array) || !isTupleStruct(t) {
+		return data, nil
+	}
+	elems := reflect.ValueOf(data)
+	if elems.Len() != t.NumField() {
+		return nil, fmt.Errorf("can not decode tuple of %d elements into %v with %d fields", elems.Len(), t, t.NumField())
+	}
+	tuple := reflect.New(t).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if err := decodeResult(elems.Index(i).Interface(), tuple.Field(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return tuple.Interface(), nil
+}
+
+// marshalValue converts values which gocql can not marshal by itself into an equivalent which it can
+func marshalValue(v interface{}) interface{} {
+	if mv, ok, err := marshalCodec(v); err != nil {
+		return marshalFailure{err}
+	} else if ok {
+		v = mv
+	}
+	var f *big.Float
+	switch t := v.(type) {
+	case big.Float:
+		f = &t
+	case *big.Float:
+		f = t
+	default:
+		return v
+	}
+	if f == nil {
+		return nil
+	}
+	// Infinities have no decimal representation, leave them to gocql to reject
+	if dec, ok := new(inf.Dec).SetString(f.Text('f', -1)); ok {
+		return dec
+	}
+	return v
+}
+
+func marshalValues(vs []interface{}) []interface{} {
+	ret := make([]interface{}, len(vs))
+	for i, v := range vs {
+		ret[i] = marshalValue(v)
+	}
+	return ret
+}
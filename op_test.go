@@ -1,8 +1,16 @@
 package gocassa
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
 )
 
 type OpTestStruct struct {
@@ -95,3 +103,434 @@ func TestDecodeSingleRow(t *testing.T) {
 		t.Fatalf("Did not get expected result")
 	}
 }
+
+type OpTestTypesStruct struct {
+	TinyInt   int8
+	SmallInt  int16
+	Unsigned  uint8
+	Unsigned2 uint16
+	Unsigned4 uint32
+	Varint    *big.Int
+	Decimal   *inf.Dec
+	Float     big.Float
+	Inet      net.IP
+	Date      time.Time     `cql:",type=date"`
+	Time      time.Duration `cql:",type=time"`
+	Duration  gocql.Duration
+	Created   gocql.UUID `cql:",type=timeuuid"`
+	Ascii     string     `cql:",type=ascii"`
+	Nullable  *string
+}
+
+func TestDecodeTypes(t *testing.T) {
+	created := gocql.TimeUUID()
+	date := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	// Values as returned by gocql's MapScan for each column type
+	row := map[string]interface{}{
+		"TinyInt":   int8(-3),
+		"SmallInt":  int16(-300),
+		"Unsigned":  int16(200),
+		"Unsigned2": 60000,
+		"Unsigned4": int64(4000000000),
+		"Varint":    big.NewInt(-12345678901),
+		"Decimal":   inf.NewDec(12345, 2),
+		"Float":     inf.NewDec(-25, 1),
+		"Inet":      "10.0.0.1",
+		"Date":      date,
+		"Time":      90 * time.Minute,
+		"Duration":  gocql.Duration{Months: 1, Days: 2, Nanoseconds: 3},
+		"Created":   created,
+		"Ascii":     "abc",
+		"Nullable":  nil,
+	}
+
+	var result OpTestTypesStruct
+	if err := decodeResult(row, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := OpTestTypesStruct{
+		TinyInt:   -3,
+		SmallInt:  -300,
+		Unsigned:  200,
+		Unsigned2: 60000,
+		Unsigned4: 4000000000,
+		Varint:    big.NewInt(-12345678901),
+		Decimal:   inf.NewDec(12345, 2),
+		Inet:      net.ParseIP("10.0.0.1"),
+		Date:      date,
+		Time:      90 * time.Minute,
+		Duration:  gocql.Duration{Months: 1, Days: 2, Nanoseconds: 3},
+		Created:   created,
+		Ascii:     "abc",
+	}
+	expected.Float.SetFloat64(-2.5)
+	if result.Float.Cmp(&expected.Float) != 0 {
+		t.Fatalf("Expected decimal to decode into %v, got %v", &expected.Float, &result.Float)
+	}
+	result.Float, expected.Float = big.Float{}, big.Float{}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Did not get expected result: %+v", result)
+	}
+}
+
+func TestMarshalValue(t *testing.T) {
+	f, _ := new(big.Float).SetString("12.5")
+	dec, ok := marshalValue(f).(*inf.Dec)
+	if !ok || dec.Cmp(inf.NewDec(125, 1)) != 0 {
+		t.Fatalf("Expected big.Float to be marshalled as decimal 12.5, got %v", marshalValue(f))
+	}
+	if v := marshalValue("str"); v != "str" {
+		t.Fatalf("Expected values gocql can marshal to be untouched, got %v", v)
+	}
+}
+
+type OpTestGeoPoint struct {
+	Lat, Lng float64
+}
+
+func (OpTestGeoPoint) CQLTuple() {}
+
+type OpTestTupleStruct struct {
+	Location OpTestGeoPoint
+	Pair     [2]string
+	Tags     []string
+	Nested   map[string][]int
+	Points   []OpTestGeoPoint
+}
+
+func TestDecodeTuples(t *testing.T) {
+	// Top level tuples are collapsed by the backend, nested ones are returned as slices by gocql
+	row := map[string]interface{}{
+		"Location[0]": 51.5,
+		"Location[1]": -0.1,
+		"Pair[0]":     "a",
+		"Pair[1]":     "b",
+		"Tags":        []string{"x", "y"},
+		"Nested":      map[string][]int{"k": {1, 2}},
+		"Points":      [][]interface{}{{1.0, 2.0}, {3.0, 4.0}},
+	}
+	collapseTuples(row, map[string]int{"Location": 2, "Pair": 2})
+	if _, ok := row["Location[0]"]; ok {
+		t.Fatal("Expected tuple elements to be collapsed")
+	}
+
+	var result OpTestTupleStruct
+	if err := decodeResult(row, &result); err != nil {
+		t.Fatal(err)
+	}
+	expected := OpTestTupleStruct{
+		Location: OpTestGeoPoint{51.5, -0.1},
+		Pair:     [2]string{"a", "b"},
+		Tags:     []string{"x", "y"},
+		Nested:   map[string][]int{"k": {1, 2}},
+		Points:   []OpTestGeoPoint{{1, 2}, {3, 4}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Did not get expected result: %+v", result)
+	}
+}
+
+type OpTestStrictStruct struct {
+	Small int8
+	Count uint32
+}
+
+func TestDecodeStrict(t *testing.T) {
+	var result OpTestStrictStruct
+	row := map[string]interface{}{"small": 12, "count": int64(7)}
+	if err := decodeResult(row, &result); err != nil {
+		t.Fatal(err)
+	}
+	expected := OpTestStrictStruct{Small: 12, Count: 7}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Did not get expected result: %+v", result)
+	}
+
+	for _, row := range []map[string]interface{}{
+		{"small": 300},
+		{"count": -1},
+		{"small": "12"},
+	} {
+		if err := decodeResult(row, &result); err == nil {
+			t.Errorf("Expected an error decoding %v", row)
+		}
+	}
+
+	// Results which are not structs still go through mapstructure
+	var maps []map[string]interface{}
+	if err := decodeResult([]map[string]interface{}{{"small": 1}}, &maps); err != nil || len(maps) != 1 {
+		t.Fatalf("Expected the row as a map, got %v, %v", maps, err)
+	}
+}
+
+// wideRow has a column for each of the fields of OpTestWideStruct
+var wideRow = func() map[string]interface{} {
+	row := map[string]interface{}{}
+	for i := 0; i < 10; i++ {
+		row[fmt.Sprintf("s%d", i)] = fmt.Sprintf("value %d", i)
+		row[fmt.Sprintf("i%d", i)] = i
+		row[fmt.Sprintf("f%d", i)] = float64(i)
+		row[fmt.Sprintf("t%d", i)] = time.Unix(int64(i), 0)
+		row[fmt.Sprintf("l%d", i)] = []string{"a", "b", "c"}
+	}
+	return row
+}()
+
+type OpTestWideStruct struct {
+	S0, S1, S2, S3, S4, S5, S6, S7, S8, S9 string
+	I0, I1, I2, I3, I4, I5, I6, I7, I8, I9 int
+	F0, F1, F2, F3, F4, F5, F6, F7, F8, F9 float64
+	T0, T1, T2, T3, T4, T5, T6, T7, T8, T9 time.Time
+	L0, L1, L2, L3, L4, L5, L6, L7, L8, L9 []string
+}
+
+func wideRows(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = wideRow
+	}
+	return rows
+}
+
+func TestDecodeWideRow(t *testing.T) {
+	var direct, fallback []OpTestWideStruct
+	if err := decodeResult(wideRows(2), &direct); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeWithMapstructure(wideRows(2), &fallback); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(direct, fallback) {
+		t.Fatalf("Expected the same result from both decoders, got %+v and %+v", direct, fallback)
+	}
+}
+
+// scanQE returns rows encoded as Cassandra sends them, as maps the way gocql's MapScan reads them, or scanned into the
+// destinations of a rowDecoder
+type scanQE struct {
+	OptionCheckingQE
+	columns []gocql.ColumnInfo
+	rows    [][][]byte
+}
+
+func newScanQE(columns []gocql.ColumnInfo, rows ...[]interface{}) scanQE {
+	qe := scanQE{OptionCheckingQE: OptionCheckingQE{opts: &Options{}}, columns: columns}
+	for _, row := range rows {
+		encoded := make([][]byte, len(row))
+		for i, value := range row {
+			b, err := gocql.Marshal(columns[i].TypeInfo, value)
+			if err != nil {
+				panic(err)
+			}
+			encoded[i] = b
+		}
+		qe.rows = append(qe.rows, encoded)
+	}
+	return qe
+}
+
+func (qe scanQE) QueryWithOptions(opts Options, stmt string, params ...interface{}) ([]map[string]interface{}, error) {
+	maps := []map[string]interface{}{}
+	for _, row := range qe.rows {
+		m := map[string]interface{}{}
+		for i, col := range qe.columns {
+			if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+				elems := make([]interface{}, len(tuple.Elems))
+				for j, elem := range tuple.Elems {
+					elems[j] = elem.New()
+				}
+				if err := gocql.Unmarshal(col.TypeInfo, row[i], elems); err != nil {
+					return nil, err
+				}
+				for j := range elems {
+					elems[j] = reflect.ValueOf(elems[j]).Elem().Interface()
+				}
+				m[col.Name] = elems
+				continue
+			}
+			value := col.TypeInfo.New()
+			if err := gocql.Unmarshal(col.TypeInfo, row[i], value); err != nil {
+				return nil, err
+			}
+			m[col.Name] = reflect.ValueOf(value).Elem().Interface()
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+func (qe scanQE) Query(stmt string, params ...interface{}) ([]map[string]interface{}, error) {
+	return qe.QueryWithOptions(Options{}, stmt, params...)
+}
+
+func (qe scanQE) scanWithOptions(opts Options, stmt string, params []interface{}, d rowDecoder) error {
+	d.setColumns(qe.columns)
+	for _, row := range qe.rows {
+		dest := d.dest()
+		n := 0
+		for i, col := range qe.columns {
+			if tuple, ok := col.TypeInfo.(gocql.TupleTypeInfo); ok {
+				if err := gocql.Unmarshal(col.TypeInfo, row[i], dest[n:n+len(tuple.Elems)]); err != nil {
+					return err
+				}
+				n += len(tuple.Elems)
+				continue
+			}
+			if dest[n] != nil {
+				if err := gocql.Unmarshal(col.TypeInfo, row[i], dest[n]); err != nil {
+					return err
+				}
+			}
+			n++
+		}
+		if more, err := d.row(); err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapsQE hides the scanning of a QueryExecutor, so that its rows are read as maps
+type mapsQE struct {
+	QueryExecutor
+}
+
+type OpTestScanned struct {
+	Id      string
+	Count   int
+	Point   [2]float64
+	Tags    []string
+	Missing string
+}
+
+func TestScanRows(t *testing.T) {
+	native := func(typ gocql.Type) gocql.NativeType {
+		return gocql.NewNativeType(4, typ, "")
+	}
+	columns := []gocql.ColumnInfo{
+		{Name: "id", TypeInfo: native(gocql.TypeVarchar)},
+		{Name: "count", TypeInfo: native(gocql.TypeBigInt)},
+		{Name: "point", TypeInfo: gocql.TupleTypeInfo{
+			NativeType: native(gocql.TypeTuple),
+			Elems:      []gocql.TypeInfo{native(gocql.TypeDouble), native(gocql.TypeDouble)},
+		}},
+		{Name: "tags", TypeInfo: gocql.CollectionType{NativeType: native(gocql.TypeList), Elem: native(gocql.TypeVarchar)}},
+		{Name: "unknown", TypeInfo: native(gocql.TypeInt)},
+	}
+	qe := newScanQE(columns,
+		[]interface{}{"a", int64(1), []interface{}{1.5, 2.5}, []string{"x", "y"}, 7},
+		[]interface{}{"b", nil, []interface{}{0.0, 0.0}, nil, nil},
+	)
+	read := func(qe QueryExecutor, result interface{}, one bool) error {
+		tbl := (&connection{q: qe}).KeySpace("some_ks").Table("scanned", OpTestScanned{}, Keys{PartitionKeys: []string{"Id"}})
+		if one {
+			return tbl.Where(Eq("Id", "a")).ReadOne(result).Run()
+		}
+		return tbl.Where(Eq("Id", "a")).Read(result).Run()
+	}
+
+	// Rows are scanned into the fields as they would be decoded from maps
+	var scanned, fromMaps []OpTestScanned
+	if err := read(qe, &scanned, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := read(mapsQE{qe}, &fromMaps, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []OpTestScanned{{Id: "a", Count: 1, Point: [2]float64{1.5, 2.5}, Tags: []string{"x", "y"}}, {Id: "b"}}
+	if !reflect.DeepEqual(scanned, expected) || !reflect.DeepEqual(fromMaps, expected) {
+		t.Fatalf("expected %+v, got %+v scanned and %+v from maps", expected, scanned, fromMaps)
+	}
+	var pointers []*OpTestScanned
+	if err := read(qe, &pointers, false); err != nil || len(pointers) != 2 || !reflect.DeepEqual(*pointers[0], expected[0]) || pointers[1].Id != "b" {
+		t.Fatalf("expected pointers to the rows, got %+v, %v", pointers, err)
+	}
+
+	// A single row leaves the fields without a column as they are
+	one := OpTestScanned{Missing: "kept"}
+	if err := read(qe, &one, true); err != nil || one.Id != "a" || one.Missing != "kept" {
+		t.Fatalf("expected the first row, got %+v, %v", one, err)
+	}
+	if err := read(newScanQE(columns), &one, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a RowNotFoundError, got %v", err)
+	}
+
+	// Values which do not fit their field are errors
+	type small struct {
+		Id    string
+		Count int8
+	}
+	tooBig := newScanQE(columns[:2], []interface{}{"a", int64(300)})
+	var smalls []small
+	tbl := (&connection{q: tooBig}).KeySpace("some_ks").Table("scanned", small{}, Keys{PartitionKeys: []string{"Id"}})
+	if err := tbl.Where(Eq("Id", "a")).Read(&smalls).Run(); err == nil || errors.As(err, &QueryError{}) {
+		t.Fatalf("expected an error decoding the count, got %v", err)
+	}
+}
+
+// wideColumns are the columns of wideRow
+var wideColumns = func() []gocql.ColumnInfo {
+	var columns []gocql.ColumnInfo
+	native := func(typ gocql.Type) gocql.NativeType {
+		return gocql.NewNativeType(4, typ, "")
+	}
+	for _, c := range []struct {
+		prefix string
+		info   gocql.TypeInfo
+	}{
+		{"s", native(gocql.TypeVarchar)},
+		{"i", native(gocql.TypeInt)},
+		{"f", native(gocql.TypeDouble)},
+		{"t", native(gocql.TypeTimestamp)},
+		{"l", gocql.CollectionType{NativeType: native(gocql.TypeList), Elem: native(gocql.TypeVarchar)}},
+	} {
+		for i := 0; i < 10; i++ {
+			columns = append(columns, gocql.ColumnInfo{Name: fmt.Sprintf("%s%d", c.prefix, i), TypeInfo: c.info})
+		}
+	}
+	return columns
+}()
+
+func BenchmarkDecodeWideRows(b *testing.B) {
+	row := make([]interface{}, len(wideColumns))
+	for i, col := range wideColumns {
+		row[i] = wideRow[col.Name]
+	}
+	rows := make([][]interface{}, 100)
+	for i := range rows {
+		rows[i] = row
+	}
+	qe := newScanQE(wideColumns, rows...)
+	read := func(b *testing.B, qe QueryExecutor) {
+		tbl := (&connection{q: qe}).KeySpace("some_ks").Table("wide", OpTestWideStruct{}, Keys{PartitionKeys: []string{"S0"}})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var result []OpTestWideStruct
+			if err := tbl.Where(Eq("S0", "value 0")).Read(&result).Run(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	// Scanning into the fields saves the map of each row, and boxing the values with the type of their field
+	b.Run("scan", func(b *testing.B) {
+		read(b, qe)
+	})
+	b.Run("direct", func(b *testing.B) {
+		read(b, mapsQE{qe})
+	})
+	b.Run("mapstructure", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			maps, err := qe.Query("")
+			if err != nil {
+				b.Fatal(err)
+			}
+			var result []OpTestWideStruct
+			if err := decodeWithMapstructure(maps, &result); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -40,6 +40,12 @@ type Options struct {
 	TTL time.Duration
 	// Limit query result set
 	Limit int
+	// PerPartitionLimit limits the number of rows read from each partition, eg. to read the latest rows of several
+	// partitions with an IN relation
+	PerPartitionLimit int
+	// GroupBy groups the rows read by a prefix of the primary key: all the partition keys, followed by some of the
+	// clustering columns in order. Reads return the first row of each group, and aggregates are computed per group.
+	GroupBy []string
 	// TableName overrides the default internal table name. When naming a table 'users' the internal table name becomes 'users_someTableSpecificMetaInformation'.
 	TableName string
 	// ClusteringOrder specifies the clustering order during table creation. If empty, it is omitted and the defaults are used.
@@ -54,18 +60,54 @@ type Options struct {
 	CompactStorage bool
 	// Compressor specifies the compressor (if any) to use on a newly created table
 	Compressor string
+	// PartialSet makes Set and Update, on a table or on their Op, only write the values which are not empty, leaving
+	// the other columns as they are instead of overwriting them with zero values or nulls
+	PartialSet bool
+	// Timestamp sets the write time of inserts, updates and deletes with USING TIMESTAMP. If zero, the time the
+	// write is received is used. Writes and deletes with older timestamps than the data they touch are ignored.
+	Timestamp time.Time
+	// MaxBuckets is the most buckets a time series List may read, and the most a ListRange or ListLatest may walk
+	// through. Listing a longer range fails with a ValidationError instead of sending the queries. If 0 or negative,
+	// there is no limit.
+	MaxBuckets int
+	// BucketsPerQuery splits the buckets read by a time series List into queries of at most that many buckets. If 0,
+	// all of them are read with a single IN query.
+	BucketsPerQuery int
+	// ParallelQueries is how many of the queries of a time series List are run at once. Their rows are merged in
+	// the clustering order of the time field. If 0, the queries are run one after the other.
+	ParallelQueries int
+	// IfNotExists makes writes lightweight transactions inserting the row only if it does not exist yet, so that
+	// eg. Set does not overwrite an existing row. Writes which are not applied fail with a NotAppliedError.
+	IfNotExists bool
+	// IfExists makes updates and deletes lightweight transactions applied only if the row exists
+	IfExists bool
+	// Conditions makes updates and deletes lightweight transactions applied only if the row exists and its columns
+	// satisfy the relations, eg. Eq("OwnerId", "user-1")
+	Conditions []Relation
 }
 
 // Merge returns a new Options which is a right biased merge of the two initial Options.
 func (o Options) Merge(neu Options) Options {
 	ret := Options{
-		TTL:             o.TTL,
-		Limit:           o.Limit,
-		TableName:       o.TableName,
-		ClusteringOrder: o.ClusteringOrder,
-		Select:          o.Select,
-		CompactStorage:  o.CompactStorage,
-		Compressor:      o.Compressor,
+		TTL:               o.TTL,
+		Limit:             o.Limit,
+		PerPartitionLimit: o.PerPartitionLimit,
+		GroupBy:           o.GroupBy,
+		TableName:         o.TableName,
+		ClusteringOrder:   o.ClusteringOrder,
+		AllowFiltering:    o.AllowFiltering,
+		Select:            o.Select,
+		Consistency:       o.Consistency,
+		CompactStorage:    o.CompactStorage,
+		Compressor:        o.Compressor,
+		PartialSet:        o.PartialSet,
+		Timestamp:         o.Timestamp,
+		MaxBuckets:        o.MaxBuckets,
+		BucketsPerQuery:   o.BucketsPerQuery,
+		ParallelQueries:   o.ParallelQueries,
+		IfNotExists:       o.IfNotExists,
+		IfExists:          o.IfExists,
+		Conditions:        o.Conditions,
 	}
 	if neu.TTL != time.Duration(0) {
 		ret.TTL = neu.TTL
@@ -73,6 +115,12 @@ func (o Options) Merge(neu Options) Options {
 	if neu.Limit != 0 {
 		ret.Limit = neu.Limit
 	}
+	if neu.PerPartitionLimit != 0 {
+		ret.PerPartitionLimit = neu.PerPartitionLimit
+	}
+	if len(neu.GroupBy) > 0 {
+		ret.GroupBy = neu.GroupBy
+	}
 	if len(neu.TableName) > 0 {
 		ret.TableName = neu.TableName
 	}
@@ -94,9 +142,46 @@ func (o Options) Merge(neu Options) Options {
 	if len(neu.Compressor) > 0 {
 		ret.Compressor = neu.Compressor
 	}
+	if neu.PartialSet {
+		ret.PartialSet = neu.PartialSet
+	}
+	if !neu.Timestamp.IsZero() {
+		ret.Timestamp = neu.Timestamp
+	}
+	if neu.MaxBuckets != 0 {
+		ret.MaxBuckets = neu.MaxBuckets
+	}
+	if neu.BucketsPerQuery != 0 {
+		ret.BucketsPerQuery = neu.BucketsPerQuery
+	}
+	if neu.ParallelQueries != 0 {
+		ret.ParallelQueries = neu.ParallelQueries
+	}
+	if neu.IfNotExists {
+		ret.IfNotExists = neu.IfNotExists
+	}
+	if neu.IfExists {
+		ret.IfExists = neu.IfExists
+	}
+	if len(neu.Conditions) > 0 {
+		ret.Conditions = neu.Conditions
+	}
 	return ret
 }
 
+// conditional tells whether the options make writes lightweight transactions
+func (o Options) conditional() bool {
+	return o.IfNotExists || o.IfExists || len(o.Conditions) > 0
+}
+
+// maxBuckets returns the most buckets a time series read goes through, or 0 if there is no limit
+func (o Options) maxBuckets() int {
+	if o.MaxBuckets < 0 {
+		return 0
+	}
+	return o.MaxBuckets
+}
+
 // AppendClusteringOrder adds a clustering order.  If there already clustering orders, the new one is added to the end.
 func (o Options) AppendClusteringOrder(column string, direction ColumnDirection) Options {
 	col := ClusteringOrderColumn{
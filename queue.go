@@ -0,0 +1,311 @@
+package gocassa
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// QueueItem is an item of a queue. Items are stored in one of the shards of the queue picked by their id, in the bucket
+// of their due time, or of the time they were enqueued if they were already due.
+type QueueItem struct {
+	Shard   int
+	Bucket  int64
+	Due     time.Time
+	Id      string
+	Payload []byte
+	// Attempts is the number of times the item has been polled
+	Attempts int
+	// Visible is when the item can be polled. Polling an item hides it for the visibility timeout, and the values of
+	// Visible and Attempts set by the poll identify its claim in Ack and Nack.
+	Visible time.Time
+}
+
+// queueBucket records that a bucket of a shard may have items, so that polls only read the buckets which do
+type queueBucket struct {
+	Shard  int
+	Bucket int64
+}
+
+// queueShard records the lowest bucket of a shard which may have items, so that polls skip the tombstones of the
+// buckets emptied before
+type queueShard struct {
+	Shard int
+	Low   int64
+}
+
+// queuePageSize is the most items a poll reads from a bucket with a single query
+const queuePageSize = 100
+
+type queueT struct {
+	items    Table
+	buckets  Table
+	marks    Table
+	shards   int
+	bucketer Bucketer
+	now      func() time.Time
+	// low caches the lowest bucket of each shard which may have items, as read from or written to marks. Polls read
+	// the buckets from there on.
+	low *sync.Map
+}
+
+func (q *queueT) Enqueue(id string, payload []byte, due time.Time) Op {
+	now := q.now()
+	if due.IsZero() {
+		due = now
+	}
+	// An item is never stored in a bucket older than the current one, which polls may have emptied already
+	at := due
+	if at.Before(now) {
+		at = now
+	}
+	item := QueueItem{
+		Shard:   q.shard(id),
+		Bucket:  q.bucketer.Bucket(at.Unix()),
+		Due:     due.Truncate(time.Millisecond),
+		Id:      id,
+		Payload: payload,
+		Visible: due.Truncate(time.Millisecond),
+	}
+	return multiOp{
+		q.buckets.Set(queueBucket{Shard: item.Shard, Bucket: item.Bucket}),
+		q.items.Set(item),
+	}
+}
+
+func (q *queueT) Poll(n int, visibilityTimeout time.Duration) ([]QueueItem, error) {
+	if n < 1 {
+		return nil, ValidationError{Table: q.Name(), Reason: "polls have to ask for at least one item"}
+	}
+	now := q.now()
+	var polled []QueueItem
+	start := rand.Intn(q.shards)
+	for i := 0; i < q.shards && len(polled) < n; i++ {
+		shard := (start + i) % q.shards
+		items, err := q.pollShard(shard, n-len(polled), now, visibilityTimeout)
+		if err != nil {
+			return nil, err
+		}
+		polled = append(polled, items...)
+	}
+	return polled, nil
+}
+
+func (q *queueT) Ack(item QueueItem) Op {
+	return q.filter(item).Delete().WithOptions(Options{Conditions: q.claimedBy(item)})
+}
+
+func (q *queueT) Nack(item QueueItem, delay time.Duration) Op {
+	return q.filter(item).
+		Update(map[string]interface{}{"Visible": q.now().Add(delay).Truncate(time.Millisecond)}).
+		WithOptions(Options{Conditions: q.claimedBy(item)})
+}
+
+func (q *queueT) WithClock(now func() time.Time) Queue {
+	ret := *q
+	ret.now = now
+	return &ret
+}
+
+func (q *queueT) WithOptions(o Options) Queue {
+	ret := *q
+	ret.items = q.items.WithOptions(o)
+	ret.buckets = q.buckets.WithOptions(o)
+	ret.marks = q.marks.WithOptions(o)
+	return &ret
+}
+
+func (q *queueT) Create() error {
+	return q.tables().Create()
+}
+
+func (q *queueT) CreateStatement() (string, error) {
+	return q.tables().CreateStatement()
+}
+
+func (q *queueT) CreateIfNotExist() error {
+	return q.tables().CreateIfNotExist()
+}
+
+func (q *queueT) CreateIfNotExistStatement() (string, error) {
+	return q.tables().CreateIfNotExistStatement()
+}
+
+func (q *queueT) Recreate() error {
+	return q.tables().Recreate()
+}
+
+func (q *queueT) Name() string {
+	return q.tables().Name()
+}
+
+func (q *queueT) tables() tableGroup {
+	return tableGroup{q.items, q.buckets, q.marks}
+}
+
+func (q *queueT) shard(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(q.shards))
+}
+
+func (q *queueT) filter(item QueueItem) Filter {
+	return q.items.Where(Eq("Shard", item.Shard), Eq("Bucket", item.Bucket), Eq("Due", item.Due), Eq("Id", item.Id))
+}
+
+// claimedBy returns the conditions of the writes which only apply while the claim of a poll on item holds
+func (q *queueT) claimedBy(item QueueItem) []Relation {
+	return []Relation{Eq("Visible", item.Visible), Eq("Attempts", item.Attempts)}
+}
+
+// pollShard claims up to n items of the buckets of a shard, and forgets the buckets which have been empty for a
+// bucket's time
+func (q *queueT) pollShard(shard, n int, now time.Time, visibilityTimeout time.Duration) ([]QueueItem, error) {
+	low, err := q.lowBucket(shard)
+	if err != nil {
+		return nil, err
+	}
+	relations := []Relation{Eq("Shard", shard), LTE("Bucket", q.bucketer.Bucket(now.Unix()))}
+	if low != nil {
+		relations = append(relations, GTE("Bucket", *low))
+	}
+	buckets := []queueBucket{}
+	if err := q.buckets.Where(relations...).Read(&buckets).Run(); err != nil {
+		return nil, err
+	}
+
+	var polled []QueueItem
+	var next *int64
+	leading := true // whether the buckets before b have all been forgotten
+	for _, b := range buckets {
+		items, err := q.pollBucket(b, n-len(polled), now, visibilityTimeout)
+		if err != nil {
+			return nil, err
+		}
+		polled = append(polled, items...)
+
+		forgotten, err := q.forget(b, now)
+		if err != nil {
+			return nil, err
+		}
+		if leading && forgotten {
+			// The buckets before the first one left are skipped from now on
+			bucket := q.bucketer.Next(b.Bucket)
+			next = &bucket
+		}
+		leading = leading && forgotten
+		if len(polled) >= n {
+			break
+		}
+	}
+	if next != nil {
+		q.low.Store(shard, *next)
+		if err := q.marks.Set(queueShard{Shard: shard, Low: *next}).Run(); err != nil {
+			return nil, err
+		}
+	}
+	return polled, nil
+}
+
+// lowBucket returns the lowest bucket of a shard which may have items, or nil if no bucket has been forgotten yet. A
+// mark which a concurrent poll moved back only makes the polls scan more buckets.
+func (q *queueT) lowBucket(shard int) (*int64, error) {
+	if low, ok := q.low.Load(shard); ok {
+		bucket := low.(int64)
+		return &bucket, nil
+	}
+	mark := queueShard{}
+	err := q.marks.Where(Eq("Shard", shard)).ReadOne(&mark).Run()
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	q.low.Store(shard, mark.Low)
+	return &mark.Low, nil
+}
+
+// pollBucket claims up to n of the items of a bucket which are due and visible
+func (q *queueT) pollBucket(b queueBucket, n int, now time.Time, visibilityTimeout time.Duration) ([]QueueItem, error) {
+	var polled []QueueItem
+	var after *QueueItem
+	for len(polled) < n {
+		page, err := q.page(b, now, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page {
+			if item.Visible.After(now) {
+				continue
+			}
+			claimed, err := q.claim(item, now.Add(visibilityTimeout))
+			if errors.Is(err, ErrNotApplied) {
+				continue // polled by someone else
+			} else if err != nil {
+				return nil, err
+			}
+			polled = append(polled, claimed)
+			if len(polled) == n {
+				break
+			}
+		}
+		if len(page) < queuePageSize {
+			break
+		}
+		after = &page[len(page)-1]
+	}
+	return polled, nil
+}
+
+// page reads the next page of the due items of a bucket, after the item after if it is not nil
+func (q *queueT) page(b queueBucket, now time.Time, after *QueueItem) ([]QueueItem, error) {
+	bucket := []Relation{Eq("Shard", b.Shard), Eq("Bucket", b.Bucket)}
+	reads := [][]Relation{append(bucket, LTE("Due", now))}
+	if after != nil {
+		reads = [][]Relation{
+			append(append([]Relation{}, bucket...), Eq("Due", after.Due), GT("Id", after.Id)),
+			append(append([]Relation{}, bucket...), GT("Due", after.Due), LTE("Due", now)),
+		}
+	}
+	var page []QueueItem
+	for _, relations := range reads {
+		items := []QueueItem{}
+		err := q.items.Where(relations...).Read(&items).WithOptions(Options{Limit: queuePageSize - len(page)}).Run()
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, items...)
+		if len(page) == queuePageSize {
+			break
+		}
+	}
+	return page, nil
+}
+
+// claim hides an item until visible, if nobody else has claimed it since it was read
+func (q *queueT) claim(item QueueItem, visible time.Time) (QueueItem, error) {
+	claimed := item
+	claimed.Attempts++
+	claimed.Visible = visible.Truncate(time.Millisecond)
+	err := q.filter(item).Update(map[string]interface{}{
+		"Attempts": claimed.Attempts,
+		"Visible":  claimed.Visible,
+	}).WithOptions(Options{Conditions: q.claimedBy(item)}).Run()
+	return claimed, err
+}
+
+// forget deletes the record of a bucket which has no items, once it is over by a bucket's time so that late writes to
+// it have landed. It tells whether the bucket was forgotten.
+func (q *queueT) forget(b queueBucket, now time.Time) (bool, error) {
+	if q.bucketer.Next(q.bucketer.Next(b.Bucket)) > now.UnixNano()/int64(time.Millisecond) {
+		return false, nil
+	}
+	items := []QueueItem{}
+	err := q.items.Where(Eq("Shard", b.Shard), Eq("Bucket", b.Bucket)).Read(&items).WithOptions(Options{Limit: 1}).Run()
+	if err != nil || len(items) > 0 {
+		return false, err
+	}
+	return true, q.buckets.Where(Eq("Shard", b.Shard), Eq("Bucket", b.Bucket)).Delete().Run()
+}
@@ -0,0 +1,200 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func pollIds(t *testing.T, queue Queue, n int, visibilityTimeout time.Duration) ([]QueueItem, []string) {
+	items, err := queue.Poll(n, visibilityTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+	sort.Strings(ids)
+	return items, ids
+}
+
+func TestQueue(t *testing.T) {
+	clock := &testClock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	start := clock.Now()
+	ks := NewMockKeySpace()
+	queue := ks.Queue("jobs", 4, time.Minute).WithClock(clock.Now)
+
+	for i := 0; i < 5; i++ {
+		if err := queue.Enqueue(fmt.Sprint("job-", i), []byte{byte(i)}, time.Time{}).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Enqueue("later", nil, clock.Now().Add(3*time.Minute)).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	items, ids := pollIds(t, queue, 3, time.Minute)
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %v", ids)
+	}
+	for _, item := range items {
+		if item.Attempts != 1 || !item.Visible.Equal(clock.Now().Add(time.Minute)) {
+			t.Fatalf("unexpected polled item %+v", item)
+		}
+	}
+	_, rest := pollIds(t, queue, 10, time.Minute)
+	if len(rest) != 2 {
+		t.Fatalf("expected the 2 items left, got %v", rest)
+	}
+	if _, none := pollIds(t, queue, 10, time.Minute); len(none) != 0 {
+		t.Fatalf("expected the polled items to be hidden, got %v", none)
+	}
+
+	if err := queue.Ack(items[0]).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Nack(items[1], 0).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ids := pollIds(t, queue, 10, time.Minute); len(ids) != 1 || ids[0] != items[1].Id {
+		t.Fatalf("expected the nacked item %s, got %v", items[1].Id, ids)
+	}
+	if err := queue.Ack(items[1]).Run(); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected acking an item polled again not to be applied, got %v", err)
+	}
+
+	// Items which are not acked in time are polled again, as are the items which are due later
+	clock.Advance(3 * time.Minute)
+	expected := []string{"later"}
+	for _, item := range items[1:] {
+		expected = append(expected, item.Id)
+	}
+	expected = append(expected, rest...)
+	sort.Strings(expected)
+	polled, ids := pollIds(t, queue, 10, time.Minute)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+	for _, item := range polled {
+		if err := queue.Ack(item).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Once they are empty and over, the buckets are forgotten
+	clock.Advance(3 * time.Minute)
+	if _, ids := pollIds(t, queue, 10, time.Minute); len(ids) != 0 {
+		t.Fatalf("expected an empty queue, got %v", ids)
+	}
+	buckets := []queueBucket{}
+	if err := queue.(*queueT).buckets.Where(In("Shard", 0, 1, 2, 3)).Read(&buckets).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 0 {
+		t.Fatalf("expected the empty buckets to be forgotten, got %+v", buckets)
+	}
+	// Polls of another process start from the buckets left too
+	marks := []queueShard{}
+	if err := queue.(*queueT).marks.Where(In("Shard", 0, 1, 2, 3)).Read(&marks).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(marks) == 0 {
+		t.Fatal("expected the lowest buckets of the shards to be recorded")
+	}
+	other := *queue.(*queueT)
+	other.low = &sync.Map{}
+	for _, mark := range marks {
+		low, err := other.lowBucket(mark.Shard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if low == nil || *low != mark.Low || *low <= other.bucketer.Bucket(start.Unix()) {
+			t.Fatalf("expected shard %d to start from bucket %d, got %v", mark.Shard, mark.Low, low)
+		}
+	}
+	if err := queue.Enqueue("again", nil, time.Time{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ids := pollIds(t, queue, 10, time.Minute); len(ids) != 1 {
+		t.Fatalf("expected the item enqueued after the buckets were forgotten, got %v", ids)
+	}
+
+	if _, err := queue.Poll(0, time.Minute); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a poll of no items, got %v", err)
+	}
+	if _, err := ks.QueueE("jobs", 4, 500*time.Millisecond); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a bucket size under a second, got %v", err)
+	}
+}
+
+func TestQueuePages(t *testing.T) {
+	clock := &testClock{t: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	queue := NewMockKeySpace().Queue("jobs", 1, time.Hour).WithClock(clock.Now)
+	due := clock.Now()
+	for i := 0; i < queuePageSize*2+10; i++ {
+		if i%3 == 0 {
+			due = due.Add(time.Second)
+		}
+		if err := queue.Enqueue(fmt.Sprintf("job-%03d", i), nil, due).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Hour)
+
+	// The items polled first are hidden from the next polls, which page past them
+	seen := map[string]bool{}
+	for _, n := range []int{queuePageSize + 5, queuePageSize*2 + 10} {
+		_, ids := pollIds(t, queue, n, time.Hour)
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("expected %s to be polled once", id)
+			}
+			seen[id] = true
+		}
+	}
+	if len(seen) != queuePageSize*2+10 {
+		t.Fatalf("expected every item to be polled, got %d", len(seen))
+	}
+}
+
+func TestQueueStatements(t *testing.T) {
+	qe := lwtQE{StatementRecordingQE: newStatementRecordingQE(), result: map[string]interface{}{"[applied]": true}}
+	queue := (&connection{q: qe}).KeySpace("some_ks").Queue("jobs", 8, time.Minute)
+
+	stmt, err := queue.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"CREATE TABLE some_ks.jobs_queue (",
+		"PRIMARY KEY ((shard, bucket), due, id)",
+		"CREATE TABLE some_ks.jobs_queueBuckets (",
+		"PRIMARY KEY ((shard), bucket)",
+		"CREATE TABLE some_ks.jobs_queueShards (",
+	} {
+		if !strings.Contains(stmt, expected) {
+			t.Fatalf("expected %q in %s", expected, stmt)
+		}
+	}
+
+	item := QueueItem{Shard: 1, Bucket: 2, Due: time.Now(), Id: "job", Visible: time.Now()}
+	if err := queue.Ack(item).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "DELETE FROM some_ks.jobs_queue WHERE shard = ? AND bucket = ? AND due = ? AND id = ? IF visible = ? AND attempts = ?"
+	if (*qe.stmts)[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, (*qe.stmts)[0])
+	}
+	if err := queue.Nack(item, time.Minute).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected = "UPDATE some_ks.jobs_queue SET Visible = ? WHERE shard = ? AND bucket = ? AND due = ? AND id = ? IF visible = ? AND attempts = ?"
+	if (*qe.stmts)[1] != expected {
+		t.Fatalf("expected %q, got %q", expected, (*qe.stmts)[1])
+	}
+}
@@ -1,8 +1,11 @@
 package reflect
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -16,6 +19,44 @@ type field struct {
 	typ       reflect.Type
 	omitEmpty bool
 	quoted    bool
+	cqlType   string // CQL type override, from the "type=" tag option
+	key       keyOptions
+}
+
+// keyOptions are the primary key options of a field's tag
+type keyOptions struct {
+	partition     bool
+	partitionPos  int // position given with "partition=N", 0 if not given
+	clustering    bool
+	clusteringPos int // position given with "clustering=N", 0 if not given
+	desc          bool
+	static        bool
+	err           error // set if the options are invalid
+}
+
+func parseKeyOptions(opts tagOptions) (keyOptions, error) {
+	var err error
+	ko := keyOptions{
+		desc:   opts.Contains("desc"),
+		static: opts.Contains("static"),
+	}
+	if ko.partition = opts.Contains("partition"); !ko.partition {
+		if pos, ok := opts.Get("partition"); ok {
+			ko.partition = true
+			if ko.partitionPos, err = strconv.Atoi(pos); err != nil || ko.partitionPos < 1 {
+				return ko, fmt.Errorf("invalid partition key position %q", pos)
+			}
+		}
+	}
+	if ko.clustering = opts.Contains("clustering"); !ko.clustering {
+		if pos, ok := opts.Get("clustering"); ok {
+			ko.clustering = true
+			if ko.clusteringPos, err = strconv.Atoi(pos); err != nil || ko.clusteringPos < 1 {
+				return ko, fmt.Errorf("invalid clustering column position %q", pos)
+			}
+		}
+	}
+	return ko, nil
 }
 
 func fillField(f field) field {
@@ -117,18 +158,25 @@ func typeFields(t reflect.Type) []field {
 					ft = ft.Elem()
 				}
 
-				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				// Record found field and index sequence. Struct fields
+				// with the "squash" option are explored like embedded ones.
+				squash := opts.Contains("squash") && ft.Kind() == reflect.Struct
+				if !squash && (name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct) {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
 					}
+					cqlType, _ := opts.Get("type")
+					key, err := parseKeyOptions(opts)
+					key.err = err
 					fields = append(fields, fillField(field{
 						name:      name,
 						tag:       tagged,
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
+						cqlType:   cqlType,
+						key:       key,
 					}))
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
@@ -252,3 +300,36 @@ func cachedTypeFields(t reflect.Type) []field {
 	fieldCache.Unlock()
 	return f
 }
+
+var indexCache struct {
+	sync.RWMutex
+	m map[reflect.Type]map[string][]int
+}
+
+// cachedFieldIndexes returns the index sequences of the fields of t keyed by lower case field name, computing them
+// from cachedTypeFields once per type.
+func cachedFieldIndexes(t reflect.Type) map[string][]int {
+	indexCache.RLock()
+	idx := indexCache.m[t]
+	indexCache.RUnlock()
+	if idx != nil {
+		return idx
+	}
+
+	fields := cachedTypeFields(t)
+	idx = make(map[string][]int, len(fields))
+	for _, f := range fields {
+		name := strings.ToLower(f.name)
+		if _, ok := idx[name]; !ok {
+			idx[name] = f.index
+		}
+	}
+
+	indexCache.Lock()
+	if indexCache.m == nil {
+		indexCache.m = map[reflect.Type]map[string][]int{}
+	}
+	indexCache.m[t] = idx
+	indexCache.Unlock()
+	return idx
+}
@@ -2,7 +2,9 @@
 package reflect
 
 import (
+	"fmt"
 	r "reflect"
+	"sort"
 )
 
 // StructToMap converts a struct to map. The object's default key string
@@ -28,12 +30,90 @@ func StructToMap(val interface{}) (map[string]interface{}, bool) {
 	structFields := cachedTypeFields(structVal.Type())
 	mapVal := make(map[string]interface{}, len(structFields))
 	for _, info := range structFields {
-		field := fieldByIndex(structVal, info.index)
+		mapVal[info.name] = fieldValue(structVal, info.index).Interface()
+	}
+	return mapVal, true
+}
+
+// StructToMapOmitEmpty is StructToMap for rows being written: fields
+// whose tag specifies the "omitempty" option are left out when they are
+// empty, unless they are named in keep. Fields of embedded structs which
+// are nil pointers are empty. Example:
+//
+//   // Field is only written if it is not empty.
+//   Field []string `cql:"tags,omitempty"`
+func StructToMapOmitEmpty(val interface{}, keep ...string) (map[string]interface{}, bool) {
+	structVal := r.Indirect(r.ValueOf(val))
+	if structVal.Kind() != r.Struct {
+		return nil, false
+	}
+	structFields := cachedTypeFields(structVal.Type())
+	mapVal := make(map[string]interface{}, len(structFields))
+	for _, info := range structFields {
+		field := fieldValue(structVal, info.index)
+		if info.omitEmpty && isEmptyValue(field) && !contains(keep, info.name) {
+			continue
+		}
 		mapVal[info.name] = field.Interface()
 	}
 	return mapVal, true
 }
 
+// IsEmptyValue tells if v is empty: false, 0, a nil pointer or interface,
+// an empty string or collection, or a zero struct.
+func IsEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	return isEmptyValue(r.ValueOf(v))
+}
+
+func isEmptyValue(v r.Value) bool {
+	switch v.Kind() {
+	case r.Array, r.Map, r.Slice, r.String:
+		return v.Len() == 0
+	case r.Bool:
+		return !v.Bool()
+	case r.Int, r.Int8, r.Int16, r.Int32, r.Int64:
+		return v.Int() == 0
+	case r.Uint, r.Uint8, r.Uint16, r.Uint32, r.Uint64, r.Uintptr:
+		return v.Uint() == 0
+	case r.Float32, r.Float64:
+		return v.Float() == 0
+	case r.Interface, r.Ptr:
+		return v.IsNil()
+	case r.Struct:
+		return v.IsZero()
+	}
+	return false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue returns the field of the struct v with the given index
+// sequence, or its zero value if it is in an embedded struct which is a
+// nil pointer.
+func fieldValue(v r.Value, index []int) r.Value {
+	field := v
+	for _, i := range index {
+		if field.Kind() == r.Ptr {
+			if field.IsNil() {
+				return r.Zero(v.Type().FieldByIndex(index).Type)
+			}
+			field = field.Elem()
+		}
+		field = field.Field(i)
+	}
+	return field
+}
+
 // MapToStruct converts a map to a struct. It is the inverse of the StructToMap
 // function. For details see StructToMap.
 func MapToStruct(m map[string]interface{}, struc interface{}) error {
@@ -71,13 +151,119 @@ func FieldsAndValues(val interface{}) ([]string, []interface{}, bool) {
 	fields := make([]string, len(structFields))
 	values := make([]interface{}, len(structFields))
 	for i, info := range structFields {
-		field := fieldByIndex(structVal, info.index)
 		fields[i] = info.name
-		values[i] = field.Interface()
+		values[i] = fieldValue(structVal, info.index).Interface()
 	}
 	return fields, values, true
 }
 
+// FieldTypes returns the CQL types explicitly declared on the fields of the
+// given struct, keyed by field name. Fields without a type override are
+// omitted. Example:
+//
+//   // Field is created as a timeuuid column rather than a uuid one.
+//   Field gocql.UUID `cql:"created,type=timeuuid"`
+func FieldTypes(val interface{}) (map[string]string, bool) {
+	structVal := r.Indirect(r.ValueOf(val))
+	if structVal.Kind() != r.Struct {
+		return nil, false
+	}
+	types := map[string]string{}
+	for _, info := range cachedTypeFields(structVal.Type()) {
+		if info.cqlType != "" {
+			types[info.name] = info.cqlType
+		}
+	}
+	return types, true
+}
+
+// PrimaryKey is the primary key of a struct as declared in the tags of its fields.
+type PrimaryKey struct {
+	PartitionKeys     []string
+	ClusteringColumns []string
+	// Descending are the clustering columns stored in descending order
+	Descending map[string]bool
+	// StaticColumns are the columns shared by all rows of a partition
+	StaticColumns []string
+}
+
+// PrimaryKeyOf returns the primary key declared in the tags of the given struct's fields.
+// Keys are ordered by the position given in the tag, and then by the order of the fields. Examples:
+//
+//   // Field is the first part of the partition key.
+//   Field string `cql:"id,partition"`
+//
+//   // Field is the second clustering column, in descending order.
+//   Field time.Time `cql:"created,clustering=2,desc"`
+//
+//   // Field is a static column.
+//   Field string `cql:"region,static"`
+func PrimaryKeyOf(val interface{}) (PrimaryKey, error) {
+	pk := PrimaryKey{Descending: map[string]bool{}}
+	structVal := r.Indirect(r.ValueOf(val))
+	if structVal.Kind() != r.Struct {
+		return pk, fmt.Errorf("can not read primary key of non struct type %T", val)
+	}
+
+	var partition, clustering []field
+	for _, info := range cachedTypeFields(structVal.Type()) {
+		ko := info.key
+		switch {
+		case ko.err != nil:
+			return pk, fmt.Errorf("field %s: %v", info.name, ko.err)
+		case ko.partition && ko.clustering:
+			return pk, fmt.Errorf("field %s can not be both a partition key and a clustering column", info.name)
+		case ko.static && (ko.partition || ko.clustering):
+			return pk, fmt.Errorf("key field %s can not be static", info.name)
+		case ko.desc && !ko.clustering:
+			return pk, fmt.Errorf("field %s is not a clustering column, so it can not be descending", info.name)
+		case ko.partition:
+			partition = append(partition, info)
+		case ko.clustering:
+			clustering = append(clustering, info)
+			if ko.desc {
+				pk.Descending[info.name] = true
+			}
+		case ko.static:
+			pk.StaticColumns = append(pk.StaticColumns, info.name)
+		}
+	}
+
+	sortByPosition(partition, func(f field) int { return f.key.partitionPos })
+	sortByPosition(clustering, func(f field) int { return f.key.clusteringPos })
+	for _, f := range partition {
+		pk.PartitionKeys = append(pk.PartitionKeys, f.name)
+	}
+	for _, f := range clustering {
+		pk.ClusteringColumns = append(pk.ClusteringColumns, f.name)
+	}
+	return pk, nil
+}
+
+// sortByPosition orders key fields by their explicit position, fields without one coming last in field order
+func sortByPosition(fields []field, pos func(field) int) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		pi, pj := pos(fields[i]), pos(fields[j])
+		if pi == 0 || pj == 0 {
+			return pi != 0 && pj == 0
+		}
+		return pi < pj
+	})
+}
+
+// FieldIndexes returns the index sequence of each field of the given struct
+// type, keyed by the lower case field name, which is how Cassandra names
+// columns. Use it with FieldByIndex to set fields without going through a map.
+func FieldIndexes(t r.Type) map[string][]int {
+	return cachedFieldIndexes(t)
+}
+
+// FieldByIndex returns the field of the struct v with the given index
+// sequence, allocating nil pointers to embedded structs on the way.
+func FieldByIndex(v r.Value, index []int) r.Value {
+	return fieldByIndex(v, index)
+}
+
 func fieldByIndex(v r.Value, index []int) r.Value {
 	for _, i := range index {
 		if v.Kind() == r.Ptr {
@@ -63,6 +63,70 @@ func TestStructToMap(t *testing.T) {
 	}
 }
 
+type Address struct {
+	City string
+	Zip  string `cql:"zip,omitempty"`
+}
+
+type Audit struct {
+	CreatedBy string
+}
+
+type Profile struct {
+	Name    string
+	Tags    []string `cql:"tags,omitempty"`
+	Score   int      `cql:",omitempty"`
+	Address Address  `cql:",squash"`
+	*Audit
+}
+
+func TestStructToMapOmitEmpty(t *testing.T) {
+	m, ok := StructToMapOmitEmpty(Profile{Name: "n", Address: Address{City: "London"}})
+	if !ok {
+		t.Fatal("ok is false for a profile")
+	}
+	for _, name := range []string{"tags", "Score", "zip", "Address"} {
+		if _, ok := m[name]; ok {
+			t.Errorf("Expected %s to be omitted, got %v", name, m)
+		}
+	}
+	if m["Name"] != "n" || m["City"] != "London" {
+		t.Errorf("Expected the squashed fields to be written, got %v", m)
+	}
+	if v, ok := m["CreatedBy"]; !ok || v != "" {
+		t.Errorf("Expected the fields of a nil embedded struct to be zero, got %v", m)
+	}
+
+	m, _ = StructToMapOmitEmpty(&Profile{Tags: []string{"a"}, Score: 1}, "zip")
+	if _, ok := m["zip"]; !ok {
+		t.Errorf("Expected kept fields to be written even if empty, got %v", m)
+	}
+	if len(m["tags"].([]string)) != 1 || m["Score"] != 1 {
+		t.Errorf("Expected non empty fields to be written, got %v", m)
+	}
+
+	m, _ = StructToMap(Profile{})
+	for _, name := range []string{"tags", "Score", "zip", "City", "CreatedBy"} {
+		if _, ok := m[name]; !ok {
+			t.Errorf("Expected StructToMap to keep %s, got %v", name, m)
+		}
+	}
+}
+
+func TestIsEmptyValue(t *testing.T) {
+	var nilSlice []string
+	for _, v := range []interface{}{nil, "", 0, int8(0), 0.0, false, nilSlice, []int{}, map[string]int{}, (*int)(nil), Address{}} {
+		if !IsEmptyValue(v) {
+			t.Errorf("Expected %#v to be empty", v)
+		}
+	}
+	for _, v := range []interface{}{"a", 1, true, []int{0}, Address{City: "a"}} {
+		if IsEmptyValue(v) {
+			t.Errorf("Expected %#v not to be empty", v)
+		}
+	}
+}
+
 func TestMapToStruct(t *testing.T) {
 
 	m := make(map[string]interface{})
@@ -189,3 +253,73 @@ func assertValuesEqual(t *testing.T, a, b []interface{}) {
 		}
 	}
 }
+
+func TestFieldTypes(t *testing.T) {
+	type event struct {
+		ID      gocql.UUID `cql:"id"`
+		Created gocql.UUID `cql:"created,type=timeuuid"`
+		Code    string     `cql:",omitempty,type=ascii"`
+	}
+
+	types, ok := FieldTypes(event{})
+	if !ok {
+		t.Fatal("ok is false for a struct")
+	}
+	if len(types) != 2 {
+		t.Errorf("expected 2 type overrides but got %v", types)
+	}
+	if types["created"] != "timeuuid" {
+		t.Errorf("expected created to be a timeuuid but got %q", types["created"])
+	}
+	if types["Code"] != "ascii" {
+		t.Errorf("expected Code to be ascii but got %q", types["Code"])
+	}
+
+	if _, ok := FieldTypes("str"); ok {
+		t.Error("ok result from FieldTypes when the val is a string")
+	}
+}
+
+func TestPrimaryKeyOf(t *testing.T) {
+	type sale struct {
+		Seller   string `cql:"seller,partition"`
+		Region   string `cql:"region,partition"`
+		Second   string `cql:"second,clustering=2"`
+		Created  int64  `cql:"created,clustering=1,desc"`
+		Id       string `cql:"id,clustering"`
+		Name     string `cql:"name,static"`
+		Quantity int
+	}
+
+	pk, err := PrimaryKeyOf(sale{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFieldsEqual(t, []string{"seller", "region"}, pk.PartitionKeys)
+	assertFieldsEqual(t, []string{"created", "second", "id"}, pk.ClusteringColumns)
+	assertFieldsEqual(t, []string{"name"}, pk.StaticColumns)
+	if !pk.Descending["created"] || len(pk.Descending) != 1 {
+		t.Errorf("expected only created to be descending, got %v", pk.Descending)
+	}
+
+	invalid := []interface{}{
+		"str",
+		struct {
+			A string `cql:"a,partition,clustering"`
+		}{},
+		struct {
+			A string `cql:"a,partition,static"`
+		}{},
+		struct {
+			A string `cql:"a,partition,desc"`
+		}{},
+		struct {
+			A string `cql:"a,clustering=first"`
+		}{},
+	}
+	for _, v := range invalid {
+		if _, err := PrimaryKeyOf(v); err == nil {
+			t.Errorf("expected an error for %T", v)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"reflect"
 	"strings"
 	"time"
 )
@@ -43,7 +44,14 @@ func (r Relation) cql() (string, []interface{}) {
 func anyEquals(value interface{}, terms []interface{}) bool {
 	primVal := convertToPrimitive(value)
 	for _, term := range terms {
-		if primVal == convertToPrimitive(term) {
+		// Collections and tuples are not comparable with ==
+		if cmp, ok := compareValues(value, term); ok {
+			if cmp == 0 {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(primVal, convertToPrimitive(term)) {
 			return true
 		}
 	}
@@ -57,7 +65,7 @@ func convertToPrimitive(i interface{}) interface{} {
 	case time.Duration:
 		return v.Nanoseconds()
 	default:
-		return i
+		return storedValue(i)
 	}
 }
 
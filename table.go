@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 
 	r "github.com/gocassa/gocassa/reflect"
@@ -21,6 +20,7 @@ type tableInfo struct {
 	keyspace, name string
 	marshalSource  interface{}
 	fieldSource    map[string]interface{}
+	fieldTypes     map[string]string // CQL types declared in struct tags, keyed by field name
 	keys           Keys
 	types          []*typeInfo
 	fieldNames     map[string]struct{} // This is here only to check containment
@@ -36,6 +36,7 @@ func newTableInfo(keyspace, name string, keys Keys, entity interface{}, fieldSou
 		keys:          keys,
 		fieldSource:   fieldSource,
 	}
+	cinf.fieldTypes, _ = r.FieldTypes(entity)
 	types := []*typeInfo{}
 	fields := []string{}
 	values := []interface{}{}
@@ -80,6 +81,62 @@ func toMap(i interface{}) (m map[string]interface{}, ok bool) {
 	return
 }
 
+// Unset can be used as the value of a column in the rows passed to Set and the maps passed to Update to leave the
+// column as it is. Unlike nil, which writes a null and so a tombstone, it writes nothing.
+var Unset interface{} = unset{}
+
+type unset struct{}
+
+// toWriteMap is toMap for rows being written: empty fields tagged omitempty, other than the keys, and columns set to
+// Unset are left out.
+func toWriteMap(i interface{}, keys ...string) (m map[string]interface{}, ok bool) {
+	switch v := i.(type) {
+	case map[string]interface{}:
+		m, ok = withoutUnset(v), true
+	default:
+		m, ok = r.StructToMapOmitEmpty(i, keys...)
+		if ok {
+			m = withoutUnset(m)
+		}
+	}
+
+	return
+}
+
+// withoutUnset returns m without the columns set to Unset, copying it only if there are any
+func withoutUnset(m map[string]interface{}) map[string]interface{} {
+	return withoutColumns(m, func(_ string, v interface{}) bool {
+		return v == Unset
+	})
+}
+
+// withoutEmpty returns m without the empty values of columns other than the keys, for PartialSet
+func withoutEmpty(m map[string]interface{}, keys Keys) map[string]interface{} {
+	return withoutColumns(m, func(k string, v interface{}) bool {
+		return r.IsEmptyValue(v) && !isKey(k, keys.PartitionKeys, keys.ClusteringColumns)
+	})
+}
+
+func withoutColumns(m map[string]interface{}, drop func(k string, v interface{}) bool) map[string]interface{} {
+	var ret map[string]interface{}
+	for k, v := range m {
+		if !drop(k, v) {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				ret[k] = v
+			}
+		}
+		delete(ret, k)
+	}
+	if ret == nil {
+		return m
+	}
+	return ret
+}
+
 func (t t) Where(rs ...Relation) Filter {
 	return filter{
 		t:  t,
@@ -107,6 +164,28 @@ func relations(keys Keys, m map[string]interface{}) []Relation {
 	return ret
 }
 
+// staticOnly tells if m writes only the static columns of a partition, without naming a row in it
+func staticOnly(keys Keys, m map[string]interface{}) bool {
+	if len(keys.StaticColumns) == 0 {
+		return false
+	}
+	missingColumn := false
+	for _, c := range keys.ClusteringColumns {
+		if _, ok := m[c]; !ok {
+			missingColumn = true
+		}
+	}
+	if !missingColumn {
+		return false
+	}
+	for k := range m {
+		if !isKey(k, keys.PartitionKeys, keys.StaticColumns) {
+			return false
+		}
+	}
+	return true
+}
+
 func removeFields(m map[string]interface{}, s []string) map[string]interface{} {
 	keys := map[string]bool{}
 	for _, v := range s {
@@ -152,37 +231,53 @@ func insertStatement(keySpaceName, cfName string, fieldNames []string, opts Opti
 		cfName,
 		strings.Join(lowerFieldNames, ", "),
 		strings.Join(placeHolders, ", ")))
+	if opts.IfNotExists {
+		buf.WriteString(" IF NOT EXISTS")
+	}
 
 	// Apply options
-	if opts.TTL != 0 {
-		buf.WriteString(" USING TTL ")
-		buf.WriteString(strconv.FormatFloat(opts.TTL.Seconds(), 'f', 0, 64))
+	if using := usingClause(opts, true); using != "" {
+		buf.WriteString(" ")
+		buf.WriteString(using)
 	}
 
 	return buf.String()
 }
 
 func (t t) Set(i interface{}) Op {
-	m, ok := toMap(i)
+	ks := append(append([]string{}, t.info.keys.PartitionKeys...), t.info.keys.ClusteringColumns...)
+	m, ok := toWriteMap(i, ks...)
 	if !ok {
-		panic("SetWithOptions: Incompatible type")
+		return &badOp{unrecognizedRowError(t.Name(), i)}
+	}
+	if t.options.PartialSet {
+		m = withoutEmpty(m, t.info.keys)
 	}
-	ks := append(t.info.keys.PartitionKeys, t.info.keys.ClusteringColumns...)
 	updFields := removeFields(m, ks)
 	if len(updFields) == 0 {
-		return newWriteOp(t.keySpace.qe, filter{
+		op := newWriteOp(t.keySpace.qe, filter{
 			t: t,
 		}, insertOpType, m)
+		op.row = true
+		return op
 	}
 	transformFields(updFields)
 	rels := relations(t.info.keys, m)
-	return newWriteOp(t.keySpace.qe, filter{
+	if staticOnly(t.info.keys, m) {
+		rels = relations(Keys{PartitionKeys: t.info.keys.PartitionKeys}, m)
+	}
+	op := newWriteOp(t.keySpace.qe, filter{
 		t:  t,
 		rs: rels,
 	}, updateOpType, updFields)
+	op.row = true
+	return op
 }
 
 func (t t) Create() error {
+	if err := t.keySpace.createTypes(t.info.fieldValues); err != nil {
+		return err
+	}
 	if stmt, err := t.CreateStatement(); err != nil {
 		return err
 	} else {
@@ -191,6 +286,9 @@ func (t t) Create() error {
 }
 
 func (t t) CreateIfNotExist() error {
+	if err := t.keySpace.createTypes(t.info.fieldValues); err != nil {
+		return err
+	}
 	if stmt, err := t.CreateIfNotExistStatement(); err != nil {
 		return err
 	} else {
@@ -215,8 +313,10 @@ func (t t) CreateStatement() (string, error) {
 		t.Name(),
 		t.info.keys.PartitionKeys,
 		t.info.keys.ClusteringColumns,
+		t.info.keys.StaticColumns,
 		t.info.fields,
 		t.info.fieldValues,
+		t.info.fieldTypes,
 		t.options.ClusteringOrder,
 		t.info.keys.Compound,
 		t.options.CompactStorage,
@@ -230,8 +330,10 @@ func (t t) CreateIfNotExistStatement() (string, error) {
 		t.Name(),
 		t.info.keys.PartitionKeys,
 		t.info.keys.ClusteringColumns,
+		t.info.keys.StaticColumns,
 		t.info.fields,
 		t.info.fieldValues,
+		t.info.fieldTypes,
 		t.options.ClusteringOrder,
 		t.info.keys.Compound,
 		t.options.CompactStorage,
@@ -1,8 +1,10 @@
 package gocassa
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -273,6 +275,62 @@ func TestKeysCreation(t *testing.T) {
 	}
 }
 
+func TestCreateStatementTypes(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	cs := ks.Table("types", OpTestTypesStruct{}, Keys{
+		PartitionKeys:     []string{"Ascii"},
+		ClusteringColumns: []string{"Created"},
+	})
+	str, err := cs.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{
+		"tinyint tinyint",
+		"smallint smallint",
+		"unsigned smallint",
+		"unsigned2 int",
+		"unsigned4 bigint",
+		"varint varint",
+		"decimal decimal",
+		"float decimal",
+		"inet inet",
+		"date date",
+		"time time",
+		"duration duration",
+		"created timeuuid",
+		"ascii ascii",
+		"nullable varchar",
+	} {
+		if !strings.Contains(str, "    "+col+",") {
+			t.Errorf("Expected column %q in %s", col, str)
+		}
+	}
+}
+
+func TestCreateStatementTuplesAndFrozen(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	cs := ks.Table("tuples", OpTestTupleStruct{}, Keys{
+		PartitionKeys:     []string{"Location"},
+		ClusteringColumns: []string{"Tags"},
+	})
+	str, err := cs.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{
+		"location tuple<double, double>",
+		"pair tuple<varchar, varchar>",
+		"tags frozen<list<varchar>>",
+		"nested map<varchar, frozen<list<int>>>",
+		"points list<tuple<double, double>>",
+	} {
+		if !strings.Contains(str, "    "+col+",") {
+			t.Errorf("Expected column %q in %s", col, str)
+		}
+	}
+}
+
 // Mock QueryExecutor that keeps track of options passed to it
 type OptionCheckingQE struct {
 	opts *Options
@@ -303,6 +361,28 @@ func (qe OptionCheckingQE) ExecuteAtomically(stmt []string, params [][]interface
 func (qe OptionCheckingQE) Close() {
 }
 
+// Mock QueryExecutor that keeps track of the statements executed through it
+type StatementRecordingQE struct {
+	OptionCheckingQE
+	stmts *[]string
+}
+
+func newStatementRecordingQE() StatementRecordingQE {
+	return StatementRecordingQE{
+		OptionCheckingQE: OptionCheckingQE{opts: &Options{}},
+		stmts:            &[]string{},
+	}
+}
+
+func (qe StatementRecordingQE) ExecuteWithOptions(opts Options, stmt string, params ...interface{}) error {
+	*qe.stmts = append(*qe.stmts, stmt)
+	return qe.OptionCheckingQE.ExecuteWithOptions(opts, stmt, params...)
+}
+
+func (qe StatementRecordingQE) Execute(stmt string, params ...interface{}) error {
+	return qe.ExecuteWithOptions(Options{}, stmt, params...)
+}
+
 func TestQueryWithConsistency(t *testing.T) {
 	// It's tricky to verify this against a live DB, so mock out the
 	// query executor and make sure the right options get passed
@@ -361,3 +441,284 @@ func validateTableName(t *testing.T, tbl TableChanger, expected string) bool {
 	}
 	return ok
 }
+
+type TaggedKeysStruct struct {
+	Tenant  string    `cql:"tenant,partition=1"`
+	Region  string    `cql:"region,partition=2"`
+	Created time.Time `cql:"created,clustering=1,desc"`
+	Id      string    `cql:"id,clustering=2"`
+	Body    string    `cql:"body"`
+}
+
+func TestCreateStatementFromTags(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	cs := ks.TableFromTags("tagged", TaggedKeysStruct{})
+	str, err := cs.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(str, "PRIMARY KEY ((tenant, region), created, id)") {
+		t.Fatalf("unexpected primary key in %q", str)
+	}
+	if !strings.Contains(str, "WITH CLUSTERING ORDER BY (created DESC, id ASC)") {
+		t.Fatalf("unexpected clustering order in %q", str)
+	}
+}
+
+func TestTableUnknownKeyPanics(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	for name, create := range map[string]func(){
+		"Table": func() {
+			ks.Table("tagged", TaggedKeysStruct{}, Keys{PartitionKeys: []string{"Nope"}})
+		},
+		"MapTable": func() { ks.MapTable("tagged", "nope", TaggedKeysStruct{}) },
+		"FromTags": func() { ks.TableFromTags("untagged", OpTestTypesStruct{}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			create()
+		}()
+	}
+}
+
+type StaticColumnsStruct struct {
+	SellerId   string `cql:",partition"`
+	SaleId     string `cql:",clustering"`
+	SellerName string `cql:",static"`
+	Price      int
+}
+
+func TestStaticColumns(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.TableFromTags("sales", StaticColumnsStruct{})
+	str, err := tbl.CreateStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(str, "sellername varchar STATIC") {
+		t.Fatalf("expected a static column in %q", str)
+	}
+
+	if err := tbl.Set(map[string]interface{}{"SellerId": "s1", "SellerName": "Jane"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Where(Eq("SellerId", "s1")).Update(map[string]interface{}{"SellerName": "Janet"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "UPDATE some_ks." + tbl.Name() + " SET sellername = ? WHERE sellerid = ?"
+	if len(*qe.stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %v", *qe.stmts)
+	}
+	for _, stmt := range *qe.stmts {
+		if !strings.EqualFold(stmt, expected) {
+			t.Errorf("expected %q, got %q", expected, stmt)
+		}
+	}
+
+	noClustering := ks.Table("sales", StaticColumnsStruct{}, Keys{
+		PartitionKeys: []string{"SellerId"},
+		StaticColumns: []string{"SellerName"},
+	})
+	if _, err := noClustering.CreateStatement(); err == nil {
+		t.Error("expected an error for static columns without clustering columns")
+	}
+}
+
+type OmitEmptyStruct struct {
+	Id    string
+	Name  string
+	Tags  []string `cql:",omitempty"`
+	Score int      `cql:",omitempty"`
+}
+
+func TestSetOmitEmpty(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.Table("profiles", OmitEmptyStruct{}, Keys{PartitionKeys: []string{"Id"}})
+
+	if err := tbl.Set(OmitEmptyStruct{Id: "p1"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.WithOptions(Options{PartialSet: true}).Set(OmitEmptyStruct{Id: "p1", Score: 2}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Where(Eq("Id", "p1")).Update(map[string]interface{}{"Name": "n", "Tags": Unset}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Where(Eq("Id", "p1")).Update(map[string]interface{}{"Tags": Unset}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"UPDATE some_ks." + tbl.Name() + " SET name = ? WHERE id = ?",
+		"UPDATE some_ks." + tbl.Name() + " SET score = ? WHERE id = ?",
+		"UPDATE some_ks." + tbl.Name() + " SET name = ? WHERE id = ?",
+	}
+	if len(*qe.stmts) != len(expected) {
+		t.Fatalf("expected %d statements, got %v", len(expected), *qe.stmts)
+	}
+	for i, stmt := range *qe.stmts {
+		if !strings.EqualFold(stmt, expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], stmt)
+		}
+	}
+}
+
+func TestPartialSetOnOp(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.Table("profiles", OmitEmptyStruct{}, Keys{PartitionKeys: []string{"Id"}})
+	partial := Options{PartialSet: true}
+
+	stmt, values := tbl.Set(OmitEmptyStruct{Id: "p1", Score: 2}).WithOptions(partial).GenerateStatement()
+	if expected := "UPDATE some_ks." + tbl.Name() + " SET score = ? WHERE id = ?"; !strings.EqualFold(stmt, expected) {
+		t.Errorf("expected %q, got %q", expected, stmt)
+	}
+	if !reflect.DeepEqual(values, []interface{}{2, "p1"}) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	// Set still writes the keys when every other column is left out, and Update writes nothing
+	ops := []Op{
+		tbl.Set(OmitEmptyStruct{Id: "p1", Name: "", Tags: []string{}}).WithOptions(partial),
+		tbl.Where(Eq("Id", "p1")).Update(map[string]interface{}{"Name": "n", "Score": 0}).WithOptions(partial),
+		tbl.Where(Eq("Id", "p1")).Update(map[string]interface{}{"Name": ""}).WithOptions(partial),
+	}
+	for _, op := range ops {
+		if err := op.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"INSERT INTO some_ks." + tbl.Name() + " (id) VALUES (?)",
+		"UPDATE some_ks." + tbl.Name() + " SET name = ? WHERE id = ?",
+	}
+	if len(*qe.stmts) != len(expected) {
+		t.Fatalf("expected %d statements, got %v", len(expected), *qe.stmts)
+	}
+	for i, stmt := range *qe.stmts {
+		if !strings.EqualFold(stmt, expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], stmt)
+		}
+	}
+}
+
+func TestDeleteStatements(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.Table("users", user{}, Keys{
+		PartitionKeys:     []string{"Pk1"},
+		ClusteringColumns: []string{"Ck1"},
+	})
+	ts := time.Unix(1600000000, 5000)
+
+	ops := []Op{
+		tbl.Where(Eq("Pk1", 1), Eq("Ck1", 2)).DeleteColumns("Name", "Ck2"),
+		tbl.Where(Eq("Pk1", 1), GTE("Ck1", 2), LT("Ck1", 4)).Delete(),
+		tbl.Where(Eq("Pk1", 1)).Delete().WithOptions(Options{Timestamp: ts}),
+		tbl.WithOptions(Options{TTL: time.Hour, Timestamp: ts}).Where(Eq("Pk1", 1), Eq("Ck1", 2)).Update(map[string]interface{}{"Name": "x"}),
+	}
+	for _, op := range ops {
+		if err := op.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"DELETE name, ck2 FROM some_ks." + tbl.Name() + " WHERE Pk1 = ? AND Ck1 = ?",
+		"DELETE FROM some_ks." + tbl.Name() + " WHERE Pk1 = ? AND Ck1 >= ? AND Ck1 < ?",
+		"DELETE FROM some_ks." + tbl.Name() + " USING TIMESTAMP 1600000000000005 WHERE Pk1 = ?",
+		"UPDATE some_ks." + tbl.Name() + " USING TTL 3600 AND TIMESTAMP 1600000000000005 SET name = ? WHERE Pk1 = ? AND Ck1 = ?",
+	}
+	if len(*qe.stmts) != len(expected) {
+		t.Fatalf("expected %d statements, got %v", len(expected), *qe.stmts)
+	}
+	for i, stmt := range *qe.stmts {
+		if !strings.EqualFold(stmt, expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], stmt)
+		}
+	}
+
+	if err := tbl.Where(Eq("Pk1", 1)).DeleteColumns("Name").Run(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a ValidationError deleting columns of a whole partition, got %v", err)
+	}
+	if err := tbl.Where(Eq("Pk1", 1), Eq("Ck1", 2)).DeleteColumns("Ck1").Run(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a ValidationError deleting a key column, got %v", err)
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	var errs []error
+	_, err := ks.TableE("tagged", 42, Keys{PartitionKeys: []string{"Tenant"}})
+	errs = append(errs, err)
+	_, err = ks.MapTableE("tagged", "nope", TaggedKeysStruct{})
+	errs = append(errs, err)
+	_, err = ks.MultimapTableE("tagged", "Tenant", "nope", TaggedKeysStruct{})
+	errs = append(errs, err)
+	_, err = ks.TimeSeriesTableE("tagged", "nope", "id", time.Hour, TaggedKeysStruct{})
+	errs = append(errs, err)
+	_, err = ks.TableFromTagsE("untagged", OpTestTypesStruct{})
+	errs = append(errs, err)
+	_, err = ks.TypeE("type", "not a struct")
+	errs = append(errs, err)
+	for i, err := range errs {
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%d: expected a ValidationError, got %v", i, err)
+		}
+	}
+
+	tbl, err := ks.MapTableE("tagged", "id", TaggedKeysStruct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tbl.Set(42).Run()
+	if verr := (ValidationError{}); !errors.As(err, &verr) {
+		t.Errorf("expected a ValidationError, got %v", err)
+	}
+
+	ts := ks.TimeSeriesTable("tagged", "region", "id", time.Hour, TaggedKeysStruct{})
+	err = ts.Set(TaggedKeysStruct{Id: "1"}).Run()
+	if verr := (ValidationError{}); !errors.As(err, &verr) || verr.Field != "region" {
+		t.Errorf("expected a ValidationError on region, got %v", err)
+	}
+}
+
+type keyTaggedStruct struct {
+	Id     string `cql:"id"`
+	UserId string `cql:"uid"`
+	Name   string
+}
+
+func TestTaggedKeyFields(t *testing.T) {
+	// Keys can be named by the Go name of a tagged field, in any case, as before they were validated
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	tbl := ks.MapTable("zz", "Id", keyTaggedStruct{})
+	if err := tbl.Set(keyTaggedStruct{Id: "a", Name: "A"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if stmt := (*qe.stmts)[0]; !strings.HasPrefix(stmt, "INSERT INTO some_ks.zz_map_Id ") {
+		t.Fatalf("unexpected statement %q", stmt)
+	}
+	if _, err := ks.MultimapTableE("zz", "UserId", "ID", keyTaggedStruct{}); err != nil {
+		t.Fatalf("expected the key named by its Go field name to be found, got %v", err)
+	}
+	if _, err := ks.MapTableE("zz", "Nope", keyTaggedStruct{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a key which is not a field, got %v", err)
+	}
+
+	mock := NewMockKeySpace().MapTable("zz", "Id", keyTaggedStruct{})
+	row := keyTaggedStruct{Id: "a", UserId: "u", Name: "A"}
+	if err := mock.Set(row).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var read keyTaggedStruct
+	if err := mock.Read("a", &read).Run(); err != nil || read != row {
+		t.Fatalf("expected %+v, got %+v, %v", row, read, err)
+	}
+}
@@ -0,0 +1,145 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// bucketsOp lists the rows of a time series in a range of buckets, with as many queries as Options.BucketsPerQuery
+// asks for, up to Options.ParallelQueries of them at once
+type bucketsOp struct {
+	table    string
+	where    func(relations ...Relation) Filter
+	bucketer Bucketer
+	// start and end are the first and last buckets of the time range
+	start, end int64
+	relations  []Relation // the time range
+	result     interface{}
+	options    Options
+}
+
+func (o *bucketsOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	buckets, _ := o.buckets()
+	chunks := o.chunks(buckets)
+	if len(chunks) == 1 {
+		return o.read(chunks[0], o.result).Run()
+	}
+
+	out := reflect.ValueOf(o.result).Elem()
+	pages := make([]reflect.Value, len(chunks))
+	errs := make([]error, len(chunks))
+	parallel := o.options.ParallelQueries
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range pages {
+		pages[i] = reflect.New(out.Type())
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = o.read(chunks[i], pages[i].Interface()).Run()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// The pages are in bucket order, which is the order of the time field unless it is descending
+	if len(o.options.ClusteringOrder) > 0 && o.options.ClusteringOrder[0].Direction == DESC {
+		for i, j := 0, len(pages)-1; i < j; i, j = i+1, j-1 {
+			pages[i], pages[j] = pages[j], pages[i]
+		}
+	}
+	rows := reflect.MakeSlice(out.Type(), 0, 0)
+	for _, page := range pages {
+		rows = reflect.AppendSlice(rows, page.Elem())
+	}
+	if o.options.Limit > 0 && rows.Len() > o.options.Limit {
+		rows = rows.Slice(0, o.options.Limit)
+	}
+	out.Set(rows)
+	return nil
+}
+
+func (o *bucketsOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *bucketsOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *bucketsOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.options = o.options.Merge(opts)
+	return &ret
+}
+
+func (o *bucketsOp) Preflight() error {
+	buckets, err := o.buckets()
+	if err != nil {
+		return err
+	}
+	chunks := o.chunks(buckets)
+	if rt := reflect.TypeOf(o.result); len(chunks) > 1 && (rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice) {
+		return ValidationError{Table: o.table, Reason: fmt.Sprintf("can not list into %T, a pointer to a slice is required", o.result)}
+	}
+	return o.read(chunks[0], o.result).Preflight()
+}
+
+// GenerateStatement returns the statement reading the first buckets
+func (o *bucketsOp) GenerateStatement() (string, []interface{}) {
+	buckets, _ := o.buckets()
+	return o.read(o.chunks(buckets)[0], o.result).GenerateStatement()
+}
+
+func (o *bucketsOp) QueryExecutor() QueryExecutor {
+	buckets, _ := o.buckets()
+	return o.read(o.chunks(buckets)[0], o.result).QueryExecutor()
+}
+
+// buckets returns the buckets of the time range. Once there are more than Options.MaxBuckets of them, it stops and
+// fails with a ValidationError, returning the buckets so far.
+func (o *bucketsOp) buckets() ([]interface{}, error) {
+	max := o.options.maxBuckets()
+	buckets := []interface{}{}
+	for b := o.start; b <= o.end; b = o.bucketer.Next(b) {
+		if max > 0 && len(buckets) == max {
+			return buckets, ValidationError{Table: o.table, Field: bucketFieldName, Reason: fmt.Sprintf("the range spans more than the maximum of %d buckets", max)}
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+func (o *bucketsOp) read(buckets []interface{}, pointerToASlice interface{}) Op {
+	relations := append([]Relation{In(bucketFieldName, buckets...)}, o.relations...)
+	return o.where(relations...).Read(pointerToASlice).WithOptions(o.options)
+}
+
+// chunks splits the buckets into the buckets of each query
+func (o *bucketsOp) chunks(buckets []interface{}) [][]interface{} {
+	size := o.options.BucketsPerQuery
+	if size < 1 || size >= len(buckets) {
+		return [][]interface{}{buckets}
+	}
+	var chunks [][]interface{}
+	for i := 0; i < len(buckets); i += size {
+		end := i + size
+		if end > len(buckets) {
+			end = len(buckets)
+		}
+		chunks = append(chunks, buckets[i:end])
+	}
+	return chunks
+}
@@ -0,0 +1,264 @@
+package gocassa
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Cursor marks where ListRange stopped reading a time series, so that a later ListRange can resume after it. It
+// encodes the bucket, the time and the id of the last row read, or the bucket to read next if the listing stopped
+// between buckets, and can be handed to clients as it is.
+type Cursor string
+
+type cursorPosition struct {
+	Bucket int64           `json:"b"`
+	Time   time.Time       `json:"t"`
+	Id     json.RawMessage `json:"i"`
+	// Start tells that the listing resumes at the start of the bucket rather than after a row of it
+	Start bool `json:"s,omitempty"`
+	id    interface{}
+}
+
+func newCursor(bucket int64, timeStamp time.Time, id interface{}) (Cursor, error) {
+	encodedId, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(cursorPosition{Bucket: bucket, Time: timeStamp, Id: encodedId})
+	if err != nil {
+		return "", err
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+// newBucketCursor returns the cursor resuming a listing at the start of a bucket
+func newBucketCursor(bucket int64) (Cursor, error) {
+	b, err := json.Marshal(cursorPosition{Bucket: bucket, Id: json.RawMessage("null"), Start: true})
+	if err != nil {
+		return "", err
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+// position decodes the cursor, with its id of type idType. It returns nil if the cursor is empty.
+func (c Cursor) position(idType reflect.Type) (*cursorPosition, error) {
+	if c == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return nil, err
+	}
+	pos := &cursorPosition{}
+	if err := json.Unmarshal(b, pos); err != nil {
+		return nil, err
+	}
+	id := reflect.New(idType)
+	if err := json.Unmarshal(pos.Id, id.Interface()); err != nil {
+		return nil, err
+	}
+	pos.id = id.Elem().Interface()
+	return pos, nil
+}
+
+// rangeOp lists the rows of a time series between two times, reading the buckets one at a time in the order of the
+// listing until it has read limit rows, or gone through Options.MaxBuckets buckets
+type rangeOp struct {
+	table      string
+	where      func(relations ...Relation) Filter
+	bucketer   Bucketer
+	timeField  string
+	idField    string
+	start, end time.Time
+	order      ColumnDirection
+	limit      int
+	cursor     Cursor
+	result     interface{}
+	next       *Cursor
+	options    Options
+}
+
+func (o *rangeOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	out := reflect.ValueOf(o.result).Elem()
+	pos, _ := o.cursor.position(o.idType())
+
+	rows := reflect.MakeSlice(out.Type(), 0, 0)
+	lastBucket := int64(0)
+	full, capped := false, false
+	max, walked := o.options.maxBuckets(), 0
+	bucket := o.firstBucket(pos)
+	for ; o.inRange(bucket) && !full; bucket = o.nextBucket(bucket) {
+		if max > 0 && walked == max {
+			capped = true
+			break
+		}
+		walked++
+		for _, relations := range o.relations(bucket, pos) {
+			page := reflect.New(out.Type())
+			if err := o.read(relations, rows.Len(), page.Interface()).Run(); err != nil {
+				return err
+			}
+			if page.Elem().Len() > 0 {
+				rows = reflect.AppendSlice(rows, page.Elem())
+				lastBucket = bucket
+			}
+			if o.limit > 0 && rows.Len() >= o.limit {
+				full = true
+				break
+			}
+		}
+		pos = nil
+	}
+	out.Set(rows)
+
+	if capped {
+		// Sparse rows could otherwise have the listing read an unbounded number of empty buckets, so it stops, and
+		// can resume at the bucket it stopped at
+		if o.next == nil {
+			return ValidationError{Table: o.table, Field: bucketFieldName, Reason: fmt.Sprintf("the listing went through the maximum of %d buckets before reaching the end of the range", max)}
+		}
+		next, err := newBucketCursor(bucket)
+		if err != nil {
+			return err
+		}
+		*o.next = next
+		return nil
+	}
+	if o.next == nil {
+		return nil
+	}
+	*o.next = ""
+	if !full {
+		return nil
+	}
+	last, ok := toMap(rows.Index(rows.Len() - 1).Interface())
+	if !ok {
+		return unrecognizedRowError(o.table, rows.Index(rows.Len()-1).Interface())
+	}
+	timeStamp, _ := columnValue(last, o.timeField).(time.Time)
+	next, err := newCursor(lastBucket, timeStamp, columnValue(last, o.idField))
+	if err != nil {
+		return ValidationError{Table: o.table, Field: o.idField, Reason: fmt.Sprintf("can not encode the cursor: %v", err)}
+	}
+	*o.next = next
+	return nil
+}
+
+func (o *rangeOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *rangeOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *rangeOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.options = o.options.Merge(opts)
+	return &ret
+}
+
+func (o *rangeOp) Preflight() error {
+	rt := reflect.TypeOf(o.result)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice {
+		return ValidationError{Table: o.table, Reason: fmt.Sprintf("can not list into %T, a pointer to a slice is required", o.result)}
+	}
+	if o.limit < 0 {
+		return ValidationError{Table: o.table, Reason: "the limit can not be negative"}
+	}
+	pos, err := o.cursor.position(o.idType())
+	if err != nil {
+		return ValidationError{Table: o.table, Reason: fmt.Sprintf("invalid cursor: %v", err)}
+	}
+	return o.firstRead(pos).Preflight()
+}
+
+// GenerateStatement returns the statement reading the first bucket
+func (o *rangeOp) GenerateStatement() (string, []interface{}) {
+	pos, _ := o.cursor.position(o.idType())
+	return o.firstRead(pos).GenerateStatement()
+}
+
+func (o *rangeOp) QueryExecutor() QueryExecutor {
+	return o.firstRead(nil).QueryExecutor()
+}
+
+func (o *rangeOp) firstRead(pos *cursorPosition) Op {
+	return o.read(o.relations(o.firstBucket(pos), pos)[0], 0, o.result)
+}
+
+// read reads the rows matching relations, in the order of the listing and up to the rows left to read
+func (o *rangeOp) read(relations []Relation, read int, pointerToASlice interface{}) Op {
+	opts := Options{ClusteringOrder: []ClusteringOrderColumn{{o.order, o.timeField}, {o.order, o.idField}}}
+	if o.limit > 0 {
+		opts.Limit = o.limit - read
+	}
+	return o.where(relations...).Read(pointerToASlice).WithOptions(o.options.Merge(opts))
+}
+
+// relations returns the relations of the reads of bucket. The bucket of the cursor is read in two steps: the rows
+// with the time of the cursor after its id, and the rows after its time.
+func (o *rangeOp) relations(bucket int64, pos *cursorPosition) [][]Relation {
+	b := Eq(bucketFieldName, bucket)
+	switch {
+	case pos == nil || pos.Bucket != bucket || pos.Start:
+		return [][]Relation{{b, GTE(o.timeField, o.start), LTE(o.timeField, o.end)}}
+	case o.order == DESC:
+		return [][]Relation{
+			{b, Eq(o.timeField, pos.Time), LT(o.idField, pos.id)},
+			{b, GTE(o.timeField, o.start), LT(o.timeField, pos.Time)},
+		}
+	}
+	return [][]Relation{
+		{b, Eq(o.timeField, pos.Time), GT(o.idField, pos.id)},
+		{b, GT(o.timeField, pos.Time), LTE(o.timeField, o.end)},
+	}
+}
+
+func (o *rangeOp) firstBucket(pos *cursorPosition) int64 {
+	switch {
+	case pos != nil:
+		return pos.Bucket
+	case o.order == DESC:
+		return o.bucketer.Bucket(o.end.Unix())
+	}
+	return o.bucketer.Bucket(o.start.Unix())
+}
+
+func (o *rangeOp) nextBucket(bucket int64) int64 {
+	if o.order == DESC {
+		return o.bucketer.Prev(bucket)
+	}
+	return o.bucketer.Next(bucket)
+}
+
+func (o *rangeOp) inRange(bucket int64) bool {
+	return bucket >= o.bucketer.Bucket(o.start.Unix()) && bucket <= o.bucketer.Bucket(o.end.Unix())
+}
+
+// idType returns the type of the id field of the rows listed
+func (o *rangeOp) idType() reflect.Type {
+	var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	rt := reflect.TypeOf(o.result)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice {
+		return interfaceType
+	}
+	elem := rt.Elem().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return interfaceType
+	}
+	m, ok := toMap(reflect.New(elem).Elem().Interface())
+	if v := columnValue(m, o.idField); ok && v != nil {
+		return reflect.TypeOf(v)
+	}
+	return interfaceType
+}
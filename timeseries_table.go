@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -8,18 +9,19 @@ const bucketFieldName = "bucket"
 
 type timeSeriesT struct {
 	Table
-	timeField  string
-	idField    string
-	bucketSize time.Duration
+	timeField string
+	idField   string
+	bucketer  Bucketer
+	options   Options
 }
 
 func (o *timeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toWriteMap(v, o.timeField, o.idField)
 	if !ok {
-		panic("Can't set: not able to convert")
+		return &badOp{unrecognizedRowError(o.Name(), v)}
 	}
 	if tim, ok := m[o.timeField].(time.Time); !ok {
-		panic("timeField is not actually a time.Time")
+		return &badOp{ValidationError{Table: o.Name(), Field: o.timeField, Reason: fmt.Sprintf("%T is not a time.Time", m[o.timeField])}}
 	} else {
 		m[bucketFieldName] = o.bucket(tim.Unix())
 	}
@@ -27,7 +29,7 @@ func (o *timeSeriesT) Set(v interface{}) Op {
 }
 
 func (o *timeSeriesT) bucket(secs int64) int64 {
-	return (secs - secs%int64(o.bucketSize/time.Second)) * 1000
+	return o.bucketer.Bucket(secs)
 }
 
 func (o *timeSeriesT) Update(timeStamp time.Time, id interface{}, m map[string]interface{}) Op {
@@ -46,22 +48,46 @@ func (o *timeSeriesT) Read(timeStamp time.Time, id, pointer interface{}) Op {
 }
 
 func (o *timeSeriesT) List(startTime time.Time, endTime time.Time, pointerToASlice interface{}) Op {
-	buckets := []interface{}{}
-	start := o.bucket(startTime.Unix())
-	for i := start; ; i += int64(o.bucketSize/time.Second) * 1000 {
-		if i >= endTime.Unix()*1000 {
-			break
-		}
-		buckets = append(buckets, i)
+	return &bucketsOp{
+		table:     o.Name(),
+		where:     o.Where,
+		bucketer:  o.bucketer,
+		start:     o.bucket(startTime.Unix()),
+		end:       o.bucket(endTime.Unix()),
+		relations: []Relation{GTE(o.timeField, startTime), LTE(o.timeField, endTime)},
+		result:    pointerToASlice,
+		options:   o.options,
 	}
-	return o.Where(In(bucketFieldName, buckets...), GTE(o.timeField, startTime), LTE(o.timeField, endTime)).Read(pointerToASlice)
+}
+
+func (o *timeSeriesT) ListRange(startTime, endTime time.Time, order ColumnDirection, limit int, cursor Cursor, pointerToASlice interface{}, next *Cursor) Op {
+	return &rangeOp{
+		table:     o.Name(),
+		where:     o.Where,
+		bucketer:  o.bucketer,
+		timeField: o.timeField,
+		idField:   o.idField,
+		start:     startTime,
+		end:       endTime,
+		order:     order,
+		limit:     limit,
+		cursor:    cursor,
+		result:    pointerToASlice,
+		next:      next,
+		options:   o.options,
+	}
+}
+
+func (o *timeSeriesT) ListLatest(since time.Time, limit int, pointerToASlice interface{}) Op {
+	return o.ListRange(since, time.Now(), DESC, limit, "", pointerToASlice, nil)
 }
 
 func (o *timeSeriesT) WithOptions(opt Options) TimeSeriesTable {
 	return &timeSeriesT{
-		Table:      o.Table.WithOptions(opt),
-		timeField:  o.timeField,
-		idField:    o.idField,
-		bucketSize: o.bucketSize,
+		Table:     o.Table.WithOptions(opt),
+		timeField: o.timeField,
+		idField:   o.idField,
+		bucketer:  o.bucketer,
+		options:   o.options.Merge(opt),
 	}
 }
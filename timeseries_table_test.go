@@ -1,7 +1,9 @@
 package gocassa
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -161,3 +163,73 @@ func TestPagingTimeSeries(t *testing.T) {
 		t.Logf("[%d] %#v", i, to)
 	}
 }
+
+func TestTimeSeriesListRangeStatement(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	tbl := ks.TimeSeriesTable("trips", "Time", "Id", time.Hour, Trip{})
+	start, end := parse("2006 Jan 2 15:04:05"), parse("2006 Jan 2 18:04:05")
+
+	stmt, vals := tbl.ListRange(start, end, DESC, 20, "", &[]Trip{}, nil).GenerateStatement()
+	expected := fmt.Sprintf("FROM some_ks.%s  WHERE bucket = ? AND Time >= ? AND Time <= ? ORDER BY Time DESC, Id DESC LIMIT ?", tbl.Name())
+	if !strings.HasSuffix(strings.ToLower(stmt), strings.ToLower(expected)) {
+		t.Fatalf("expected the latest bucket to be read first with %q, got %q", expected, stmt)
+	}
+	if bucket := parse("2006 Jan 2 18:00:00").UnixMilli(); vals[0] != bucket || vals[len(vals)-1] != 20 {
+		t.Fatalf("expected the bucket %v and the limit, got %v", bucket, vals)
+	}
+
+	cursor, err := newCursor(parse("2006 Jan 2 17:00:00").UnixMilli(), parse("2006 Jan 2 17:30:00"), "trip-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, vals = tbl.ListRange(start, end, ASC, 20, cursor, &[]Trip{}, nil).GenerateStatement()
+	expected = "WHERE bucket = ? AND Time = ? AND Id > ? ORDER BY Time ASC, Id ASC LIMIT ?"
+	if !strings.HasSuffix(strings.ToLower(stmt), strings.ToLower(expected)) || vals[2] != "trip-1" {
+		t.Fatalf("expected the listing to resume after the cursor with %q, got %q %v", expected, stmt, vals)
+	}
+}
+
+func TestTimeSeriesListBuckets(t *testing.T) {
+	ks := (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks")
+	tbl := ks.TimeSeriesTable("trips", "Time", "Id", time.Hour, Trip{})
+	start := parse("2006 Jan 2 00:00:00")
+	end := start.Add(365 * 24 * time.Hour)
+
+	_, vals := tbl.List(start, end, &[]Trip{}).GenerateStatement()
+	if buckets := vals[0].([]interface{}); len(buckets) != 8761 {
+		t.Fatalf("expected every bucket in one query, got %d", len(buckets))
+	}
+	_, vals = tbl.WithOptions(Options{BucketsPerQuery: 24}).List(start, end, &[]Trip{}).GenerateStatement()
+	if buckets := vals[0].([]interface{}); len(buckets) != 24 {
+		t.Fatalf("expected the first query to read 24 buckets, got %d", len(buckets))
+	}
+
+	err := tbl.WithOptions(Options{MaxBuckets: 24 * 7}).List(start, end, &[]Trip{}).Run()
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "maximum of 168 buckets") {
+		t.Fatalf("expected a ValidationError for the number of buckets, got %v", err)
+	}
+	if err := tbl.List(start, end, &[]Trip{}).WithOptions(Options{MaxBuckets: 24 * 366}).Preflight(); err != nil {
+		t.Fatalf("expected the range to be within the limit, got %v", err)
+	}
+
+	// The buckets are not enumerated past the limit
+	bucketer := &countingBucketer{Bucketer: &tsBucketer{bucketSize: time.Minute}}
+	flex := ks.FlexTimeSeriesTable("trips", "Time", "Id", bucketer, Trip{}).WithOptions(Options{MaxBuckets: 5})
+	if err := flex.List(time.Time{}, end, &[]Trip{}).Preflight(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for the number of buckets, got %v", err)
+	}
+	if bucketer.next > 5 {
+		t.Fatalf("expected the buckets to be enumerated up to the limit, got %d", bucketer.next)
+	}
+}
+
+// countingBucketer counts the buckets it is asked for the next one of
+type countingBucketer struct {
+	Bucketer
+	next int
+}
+
+func (b *countingBucketer) Next(bucket int64) int64 {
+	b.next++
+	return b.Bucketer.Next(bucket)
+}
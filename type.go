@@ -1,5 +1,9 @@
 package gocassa
 
+import (
+	r "github.com/gocassa/gocassa/reflect"
+)
+
 type udt struct {
 	keySpace *k
 	info     *typeInfo
@@ -10,6 +14,7 @@ type typeInfo struct {
 	keyspace, name string
 	marshalSource  interface{}
 	fieldSource    map[string]interface{}
+	fieldTypes     map[string]string   // CQL types declared in struct tags, keyed by field name
 	fieldNames     map[string]struct{} // This is here only to check containment
 	fields         []string
 	fieldValues    []interface{}
@@ -22,6 +27,7 @@ func newTypeInfo(keyspace, name string, entity interface{}, fieldSource map[stri
 		marshalSource: entity,
 		fieldSource:   fieldSource,
 	}
+	cinf.fieldTypes, _ = r.FieldTypes(entity)
 	fields := []string{}
 	values := []interface{}{}
 	for k, v := range fieldSource {
@@ -38,6 +44,9 @@ func newTypeInfo(keyspace, name string, entity interface{}, fieldSource map[stri
 }
 
 func (t *udt) Create() error {
+	if err := t.keySpace.createTypes(t.info.fieldValues); err != nil {
+		return err
+	}
 	if stmt, err := t.CreateStatement(); err != nil {
 		return err
 	} else {
@@ -46,6 +55,9 @@ func (t *udt) Create() error {
 }
 
 func (t *udt) CreateIfNotExist() error {
+	if err := t.keySpace.createTypes(t.info.fieldValues); err != nil {
+		return err
+	}
 	if stmt, err := t.CreateIfNotExistStatement(); err != nil {
 		return err
 	} else {
@@ -53,6 +65,34 @@ func (t *udt) CreateIfNotExist() error {
 	}
 }
 
+func (t *udt) Alter() error {
+	existing, err := t.keySpace.typeFields(t.Name())
+	if err != nil {
+		return err
+	}
+	stmts, err := t.AlterStatements(existing)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if err := t.keySpace.qe.Execute(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *udt) AlterStatements(existingFields []string) ([]string, error) {
+	return alterTypeAdd(t.keySpace.name,
+		t.keySpace.types,
+		t.Name(),
+		existingFields,
+		t.info.fields,
+		t.info.fieldValues,
+		t.info.fieldTypes,
+	)
+}
+
 func (t *udt) Recreate() error {
 	if ex, err := t.keySpace.ExistsType(t.Name()); ex && err == nil {
 		if err := t.keySpace.DropType(t.Name()); err != nil {
@@ -66,17 +106,21 @@ func (t *udt) Recreate() error {
 
 func (t *udt) CreateStatement() (string, error) {
 	return createType(t.keySpace.name,
+		t.keySpace.types,
 		t.Name(),
 		t.info.fields,
 		t.info.fieldValues,
+		t.info.fieldTypes,
 	)
 }
 
 func (t *udt) CreateIfNotExistStatement() (string, error) {
 	return createTypeIfNotExist(t.keySpace.name,
+		t.keySpace.types,
 		t.Name(),
 		t.info.fields,
 		t.info.fieldValues,
+		t.info.fieldTypes,
 	)
 }
 
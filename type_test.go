@@ -3,6 +3,8 @@ package gocassa
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -107,6 +109,78 @@ func TestCreateTableWithUdt(t *testing.T) {
 	}
 }
 
+type AddressUdt struct {
+	Street string
+	Geo    *GeoUdt
+}
+
+type GeoUdt struct {
+	Lat, Lng float64
+}
+
+type PersonUdt struct {
+	Name    string
+	Home    AddressUdt
+	Offices map[string]AddressUdt
+}
+
+type PersonWithUdt struct {
+	Id     string
+	Person PersonUdt
+}
+
+func TestCreateTableCreatesNestedUdts(t *testing.T) {
+	qe := newStatementRecordingQE()
+	ks := (&connection{q: qe}).KeySpace("some_ks")
+	// Registration order doesn't matter, types are created after the types they use
+	ks.Type("person", PersonUdt{})
+	ks.Type("address", AddressUdt{})
+	ks.Type("geo", GeoUdt{})
+	cs := ks.Table("people", PersonWithUdt{}, Keys{PartitionKeys: []string{"Id"}})
+
+	if err := cs.CreateIfNotExist(); err != nil {
+		t.Fatal(err)
+	}
+	stmts := *qe.stmts
+	prefixes := []string{
+		"CREATE TYPE IF NOT EXISTS some_ks.geo (",
+		"CREATE TYPE IF NOT EXISTS some_ks.address (",
+		"CREATE TYPE IF NOT EXISTS some_ks.person (",
+		"CREATE TABLE IF NOT EXISTS some_ks.people__Id__ (",
+	}
+	if len(stmts) != len(prefixes) {
+		t.Fatalf("Expected %d statements, got %v", len(prefixes), stmts)
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(stmts[i], prefix) {
+			t.Errorf("Expected statement %d to start with %q, got %s", i, prefix, stmts[i])
+		}
+	}
+	for _, col := range []string{"geo frozen<geo>", "home frozen<address>", "offices map<varchar, frozen<address>>"} {
+		if !strings.Contains(stmts[1]+stmts[2], "    "+col) {
+			t.Errorf("Expected field %q in %v", col, stmts[1:3])
+		}
+	}
+	if !strings.Contains(stmts[3], "    person frozen<person>") {
+		t.Errorf("Expected person column in %s", stmts[3])
+	}
+}
+
+func TestAlterTypeStatements(t *testing.T) {
+	ks := (&connection{q: newStatementRecordingQE()}).KeySpace("some_ks")
+	ks.Type("geo", GeoUdt{})
+	ts := ks.Type("address", AddressUdt{})
+
+	stmts, err := ts.AlterStatements([]string{"street"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ALTER TYPE some_ks.address ADD geo frozen<geo>"}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Fatalf("Expected %v, got %v", expected, stmts)
+	}
+}
+
 func validateTypeName(t *testing.T, tl TypeChanger, expected string) bool {
 	ok := tl.Name() == expected
 	if !ok {
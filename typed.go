@@ -0,0 +1,330 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	rreflect "github.com/gocassa/gocassa/reflect"
+)
+
+// ResultOp is an Op reading a value of type T. The value is available from Result once the Op has run, either on its
+// own or added to other Ops.
+type ResultOp[T any] struct {
+	Op
+	result *T
+}
+
+// Result returns the value read by the Op, or the zero value of T if it has not run yet
+func (o ResultOp[T]) Result() T {
+	return *o.result
+}
+
+func newResultOp[T any](read func(pointer interface{}) Op) ResultOp[T] {
+	result := new(T)
+	return ResultOp[T]{Op: read(result), result: result}
+}
+
+// run runs op and returns the value it read
+func run[T any](op ResultOp[T]) (T, error) {
+	if err := op.Run(); err != nil {
+		var zero T
+		return zero, err
+	}
+	return op.Result(), nil
+}
+
+// checkKeyType returns a ValidationError if the field of the rows of type V is not of type K, or of a type with the
+// same kind K converts to, eg. a named string type. Rows which are not structs, eg. maps, are not checked.
+func checkKeyType[K, V any](table, field string) error {
+	kt := reflect.TypeOf((*K)(nil)).Elem()
+	rt := reflect.TypeOf((*V)(nil)).Elem()
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return nil
+	}
+	index, ok := rreflect.FieldIndexes(rt)[strings.ToLower(field)]
+	if !ok {
+		return nil
+	}
+	ft := rt.FieldByIndex(index).Type
+	switch {
+	case kt.Kind() == reflect.Interface && ft.Implements(kt):
+		return nil
+	case kt.Kind() == ft.Kind() && kt.ConvertibleTo(ft):
+		return nil
+	}
+	return ValidationError{Table: table, Field: field, Reason: fmt.Sprintf("the field is a %v, not a %v", ft, kt)}
+}
+
+func toInterfaces[T any](vs []T) []interface{} {
+	ret := make([]interface{}, len(vs))
+	for i, v := range vs {
+		ret[i] = v
+	}
+	return ret
+}
+
+//
+// Map recipe
+//
+
+// TypedMapTable is a MapTable of rows of type V with keys of type K.
+type TypedMapTable[K, V any] struct {
+	TableChanger
+	t MapTable
+}
+
+// NewMapTable creates a MapTable of rows of type V, keyed by the partitionKey field of type K. It fails with a
+// ValidationError if the field is of another type.
+func NewMapTable[K, V any](ks KeySpace, name, partitionKey string) (*TypedMapTable[K, V], error) {
+	var row V
+	t, err := ks.MapTableE(name, partitionKey, row)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyType[K, V](t.Name(), partitionKey); err != nil {
+		return nil, err
+	}
+	return &TypedMapTable[K, V]{TableChanger: t, t: t}, nil
+}
+
+func (m *TypedMapTable[K, V]) Set(v V) Op {
+	return m.t.Set(v)
+}
+
+func (m *TypedMapTable[K, V]) Update(id K, ma map[string]interface{}) Op {
+	return m.t.Update(id, ma)
+}
+
+func (m *TypedMapTable[K, V]) Delete(id K) Op {
+	return m.t.Delete(id)
+}
+
+func (m *TypedMapTable[K, V]) Read(id K) (V, error) {
+	return run(m.ReadOp(id))
+}
+
+func (m *TypedMapTable[K, V]) ReadOp(id K) ResultOp[V] {
+	return newResultOp[V](func(pointer interface{}) Op {
+		return m.t.Read(id, pointer)
+	})
+}
+
+func (m *TypedMapTable[K, V]) MultiRead(ids ...K) ([]V, error) {
+	return run(m.MultiReadOp(ids...))
+}
+
+func (m *TypedMapTable[K, V]) MultiReadOp(ids ...K) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return m.t.MultiRead(toInterfaces(ids), pointer)
+	})
+}
+
+func (m *TypedMapTable[K, V]) WithOptions(o Options) *TypedMapTable[K, V] {
+	t := m.t.WithOptions(o)
+	return &TypedMapTable[K, V]{TableChanger: t, t: t}
+}
+
+// Untyped returns the MapTable wrapped by m
+func (m *TypedMapTable[K, V]) Untyped() MapTable {
+	return m.t
+}
+
+//
+// Multimap recipe
+//
+
+// TypedMultimapTable is a MultimapTable of rows of type V, indexed by a field of type P and identified by a field of
+// type ID.
+type TypedMultimapTable[P, ID, V any] struct {
+	TableChanger
+	t MultimapTable
+}
+
+// NewMultimapTable creates a MultimapTable of rows of type V, indexed by the fieldToIndexBy field of type P and
+// identified by the uniqueKey field of type ID. It fails with a ValidationError if the fields are of other types.
+func NewMultimapTable[P, ID, V any](ks KeySpace, name, fieldToIndexBy, uniqueKey string) (*TypedMultimapTable[P, ID, V], error) {
+	var row V
+	t, err := ks.MultimapTableE(name, fieldToIndexBy, uniqueKey, row)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyType[P, V](t.Name(), fieldToIndexBy); err != nil {
+		return nil, err
+	}
+	if err := checkKeyType[ID, V](t.Name(), uniqueKey); err != nil {
+		return nil, err
+	}
+	return &TypedMultimapTable[P, ID, V]{TableChanger: t, t: t}, nil
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) Set(v V) Op {
+	return mm.t.Set(v)
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) Update(v P, id ID, m map[string]interface{}) Op {
+	return mm.t.Update(v, id, m)
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) Delete(v P, id ID) Op {
+	return mm.t.Delete(v, id)
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) DeleteAll(v P) Op {
+	return mm.t.DeleteAll(v)
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) Read(v P, id ID) (V, error) {
+	return run(mm.ReadOp(v, id))
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) ReadOp(v P, id ID) ResultOp[V] {
+	return newResultOp[V](func(pointer interface{}) Op {
+		return mm.t.Read(v, id, pointer)
+	})
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) MultiRead(v P, ids ...ID) ([]V, error) {
+	return run(mm.MultiReadOp(v, ids...))
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) MultiReadOp(v P, ids ...ID) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return mm.t.MultiRead(v, toInterfaces(ids), pointer)
+	})
+}
+
+// List returns the first limit rows of v, or all of them if limit is 0
+func (mm *TypedMultimapTable[P, ID, V]) List(v P, limit int) ([]V, error) {
+	return run(mm.ListOp(v, limit))
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) ListOp(v P, limit int) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return mm.t.List(v, nil, limit, pointer)
+	})
+}
+
+// ListFrom is List starting at the row identified by startId
+func (mm *TypedMultimapTable[P, ID, V]) ListFrom(v P, startId ID, limit int) ([]V, error) {
+	return run(mm.ListFromOp(v, startId, limit))
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) ListFromOp(v P, startId ID, limit int) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return mm.t.List(v, startId, limit, pointer)
+	})
+}
+
+func (mm *TypedMultimapTable[P, ID, V]) WithOptions(o Options) *TypedMultimapTable[P, ID, V] {
+	t := mm.t.WithOptions(o)
+	return &TypedMultimapTable[P, ID, V]{TableChanger: t, t: t}
+}
+
+// Untyped returns the MultimapTable wrapped by mm
+func (mm *TypedMultimapTable[P, ID, V]) Untyped() MultimapTable {
+	return mm.t
+}
+
+//
+// TimeSeries recipe
+//
+
+// TypedTimeSeriesTable is a TimeSeriesTable of rows of type V identified by a field of type ID.
+type TypedTimeSeriesTable[ID, V any] struct {
+	TableChanger
+	t TimeSeriesTable
+}
+
+// NewTimeSeriesTable creates a TimeSeriesTable of rows of type V, with the time.Time field timeField and identified
+// by the idField field of type ID. It fails with a ValidationError if the id field is of another type.
+func NewTimeSeriesTable[ID, V any](ks KeySpace, name, timeField, idField string, bucketSize time.Duration) (*TypedTimeSeriesTable[ID, V], error) {
+	var row V
+	t, err := ks.TimeSeriesTableE(name, timeField, idField, bucketSize, row)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyType[ID, V](t.Name(), idField); err != nil {
+		return nil, err
+	}
+	return &TypedTimeSeriesTable[ID, V]{TableChanger: t, t: t}, nil
+}
+
+// NewFlexTimeSeriesTable is NewTimeSeriesTable with rows bucketed by bucketer, eg. MonthBucketer(loc).
+func NewFlexTimeSeriesTable[ID, V any](ks KeySpace, name, timeField, idField string, bucketer Bucketer) (*TypedTimeSeriesTable[ID, V], error) {
+	var row V
+	t, err := ks.FlexTimeSeriesTableE(name, timeField, idField, bucketer, row)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyType[ID, V](t.Name(), idField); err != nil {
+		return nil, err
+	}
+	return &TypedTimeSeriesTable[ID, V]{TableChanger: t, t: t}, nil
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) Set(v V) Op {
+	return ts.t.Set(v)
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) Update(timeStamp time.Time, id ID, m map[string]interface{}) Op {
+	return ts.t.Update(timeStamp, id, m)
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) Delete(timeStamp time.Time, id ID) Op {
+	return ts.t.Delete(timeStamp, id)
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) Read(timeStamp time.Time, id ID) (V, error) {
+	return run(ts.ReadOp(timeStamp, id))
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) ReadOp(timeStamp time.Time, id ID) ResultOp[V] {
+	return newResultOp[V](func(pointer interface{}) Op {
+		return ts.t.Read(timeStamp, id, pointer)
+	})
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) List(start, end time.Time) ([]V, error) {
+	return run(ts.ListOp(start, end))
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) ListOp(start, end time.Time) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return ts.t.List(start, end, pointer)
+	})
+}
+
+// ListRange lists up to limit rows between start and end in the given order, resuming after cursor if it is not
+// empty. It returns the cursor of the last row if there may be more to read.
+func (ts *TypedTimeSeriesTable[ID, V]) ListRange(start, end time.Time, order ColumnDirection, limit int, cursor Cursor) ([]V, Cursor, error) {
+	var next Cursor
+	rows, err := run(newResultOp[[]V](func(pointer interface{}) Op {
+		return ts.t.ListRange(start, end, order, limit, cursor, pointer, &next)
+	}))
+	return rows, next, err
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) ListLatest(since time.Time, limit int) ([]V, error) {
+	return run(ts.ListLatestOp(since, limit))
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) ListLatestOp(since time.Time, limit int) ResultOp[[]V] {
+	return newResultOp[[]V](func(pointer interface{}) Op {
+		return ts.t.ListLatest(since, limit, pointer)
+	})
+}
+
+func (ts *TypedTimeSeriesTable[ID, V]) WithOptions(o Options) *TypedTimeSeriesTable[ID, V] {
+	t := ts.t.WithOptions(o)
+	return &TypedTimeSeriesTable[ID, V]{TableChanger: t, t: t}
+}
+
+// Untyped returns the TimeSeriesTable wrapped by ts
+func (ts *TypedTimeSeriesTable[ID, V]) Untyped() TimeSeriesTable {
+	return ts.t
+}
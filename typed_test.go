@@ -0,0 +1,150 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypedMapTable(t *testing.T) {
+	users, err := NewMapTable[int, user](NewMockKeySpace(), "users", "Pk1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u1, u2 := user{Pk1: 1, Name: "Joe"}, user{Pk1: 2, Name: "Jane"}
+	if err := users.Set(u1).Add(users.Set(u2)).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := users.Read(1)
+	if err != nil || u != u1 {
+		t.Fatalf("Expected %+v, got %+v, %v", u1, u, err)
+	}
+	all, err := users.MultiRead(1, 2)
+	if err != nil || !reflect.DeepEqual(all, []user{u1, u2}) {
+		t.Fatalf("Expected both users, got %+v, %v", all, err)
+	}
+
+	read1, read2 := users.ReadOp(1), users.ReadOp(2)
+	if err := read1.Add(read2).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if read1.Result() != u1 || read2.Result() != u2 {
+		t.Fatalf("Expected the results of composed ops, got %+v and %+v", read1.Result(), read2.Result())
+	}
+
+	if err := users.Delete(1).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Read(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := NewMapTable[int, user](NewMockKeySpace(), "users", "Nope"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("Expected a validation error for an unknown key, got %v", err)
+	}
+}
+
+func TestTypedMultimapTable(t *testing.T) {
+	users, err := NewMultimapTable[int, int, user](NewMockKeySpace(), "users", "Pk1", "Pk2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := users.Set(user{Pk1: 1, Pk2: i}).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed, err := users.List(1, 0)
+	if err != nil || len(listed) != 3 {
+		t.Fatalf("Expected 3 users, got %+v, %v", listed, err)
+	}
+	listed, err = users.ListFrom(1, 2, 1)
+	if err != nil || !reflect.DeepEqual(listed, []user{{Pk1: 1, Pk2: 2}}) {
+		t.Fatalf("Expected the second user, got %+v, %v", listed, err)
+	}
+	u, err := users.Read(1, 3)
+	if err != nil || u.Pk2 != 3 {
+		t.Fatalf("Expected the third user, got %+v, %v", u, err)
+	}
+}
+
+func TestTypedTimeSeriesTable(t *testing.T) {
+	points, err := NewTimeSeriesTable[int, point](NewMockKeySpace(), "points", "Time", "Id", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := points.Set(point{Time: start.Add(time.Duration(i) * time.Hour), Id: i}).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed, err := points.List(start, start.Add(90*time.Minute))
+	if err != nil || len(listed) != 2 {
+		t.Fatalf("Expected 2 points, got %+v, %v", listed, err)
+	}
+	p, err := points.Read(start.Add(2*time.Hour), 2)
+	if err != nil || p.Id != 2 {
+		t.Fatalf("Expected the third point, got %+v, %v", p, err)
+	}
+
+	listed, cursor, err := points.ListRange(start, start.Add(2*time.Hour), DESC, 2, "")
+	if err != nil || len(listed) != 2 || listed[0].Id != 2 || cursor == "" {
+		t.Fatalf("Expected the last 2 points and a cursor, got %+v, %q, %v", listed, cursor, err)
+	}
+	listed, cursor, err = points.ListRange(start, start.Add(2*time.Hour), DESC, 2, cursor)
+	if err != nil || len(listed) != 1 || listed[0].Id != 0 || cursor != "" {
+		t.Fatalf("Expected the first point and no cursor, got %+v, %q, %v", listed, cursor, err)
+	}
+}
+
+func TestTypedTableKeyTypes(t *testing.T) {
+	ks := NewMockKeySpace()
+	for name, newTable := range map[string]func() error{
+		"map key": func() error {
+			_, err := NewMapTable[string, user](ks, "users", "Pk1")
+			return err
+		},
+		"multimap index": func() error {
+			_, err := NewMultimapTable[string, int, user](ks, "users", "Pk1", "Pk2")
+			return err
+		},
+		"multimap id": func() error {
+			_, err := NewMultimapTable[int, int64, user](ks, "users", "Pk1", "Pk2")
+			return err
+		},
+		"time series id": func() error {
+			_, err := NewTimeSeriesTable[string, point](ks, "points", "Time", "Id", time.Hour)
+			return err
+		},
+		"flex time series id": func() error {
+			_, err := NewFlexTimeSeriesTable[float64, point](ks, "points", "Time", "Id", DayBucketer(nil))
+			return err
+		},
+	} {
+		var validation ValidationError
+		if err := newTable(); !errors.As(err, &validation) || validation.Field == "" {
+			t.Errorf("%s: expected a ValidationError for the type of the key, got %v", name, err)
+		}
+	}
+
+	// Keys can be of a named type with the same kind as the field, or an interface it implements
+	type address struct {
+		Code PostalCode
+		Line string
+	}
+	if _, err := NewMapTable[string, address](ks, "addresses", "Code"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMultimapTable[interface{}, fmt.Stringer, user](ks, "users", "Pk1", "Pk2"); err == nil {
+		t.Fatal("expected a ValidationError for an interface the field does not implement")
+	}
+	if _, err := NewMultimapTable[interface{}, PostalCode, address](ks, "addresses", "Line", "Code"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,208 @@
+package gocassa
+
+import (
+	"errors"
+	"reflect"
+)
+
+type uniqueT struct {
+	Table
+	field      string
+	ownerField string
+}
+
+func (u *uniqueT) Claim(value, owner interface{}) Op {
+	set := u.Set(map[string]interface{}{u.field: value, u.ownerField: owner}).WithOptions(Options{IfNotExists: true})
+	return &claimOp{
+		Op: set,
+		ignore: func(err error) bool {
+			// Claiming a value again for its owner is not an error
+			var notApplied NotAppliedError
+			return errors.As(err, &notApplied) && sameValue(columnValue(notApplied.Current, u.ownerField), owner)
+		},
+	}
+}
+
+func (u *uniqueT) Release(value, owner interface{}) Op {
+	del := u.Where(Eq(u.field, value)).Delete().WithOptions(Options{Conditions: []Relation{Eq(u.ownerField, owner)}})
+	return &claimOp{
+		Op: del,
+		ignore: func(err error) bool {
+			// Releasing a value which is not claimed is not an error
+			var notApplied NotAppliedError
+			return errors.As(err, &notApplied) && columnValue(notApplied.Current, u.ownerField) == nil
+		},
+	}
+}
+
+func (u *uniqueT) Transfer(value, from, to interface{}) Op {
+	return u.Where(Eq(u.field, value)).
+		Update(map[string]interface{}{u.ownerField: to}).
+		WithOptions(Options{Conditions: []Relation{Eq(u.ownerField, from)}})
+}
+
+func (u *uniqueT) Owner(value, pointer interface{}) Op {
+	row := map[string]interface{}{}
+	return &ownerOp{
+		Op:         u.Where(Eq(u.field, value)).ReadOne(&row),
+		row:        &row,
+		ownerField: u.ownerField,
+		pointer:    pointer,
+	}
+}
+
+func (u *uniqueT) ClaimAnd(value, owner interface{}, op Op) Op {
+	return &claimAndOp{
+		claim:   u.Claim(value, owner).(*claimOp),
+		release: u.Release(value, owner),
+		op:      op,
+	}
+}
+
+func (u *uniqueT) WithOptions(o Options) UniqueIndex {
+	return &uniqueT{
+		Table:      u.Table.WithOptions(o),
+		field:      u.field,
+		ownerField: u.ownerField,
+	}
+}
+
+// sameValue tells whether a value read back from a table is the value written
+func sameValue(read, written interface{}) bool {
+	return reflect.DeepEqual(storedValue(read), storedValue(written))
+}
+
+// claimOp is a lightweight transaction whose ignored errors mean that it has nothing to do
+type claimOp struct {
+	Op
+	ignore func(error) bool
+}
+
+func (o *claimOp) Run() error {
+	if err := o.Op.Run(); err != nil && !o.ignore(err) {
+		return err
+	}
+	return nil
+}
+
+func (o *claimOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *claimOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *claimOp) WithOptions(opts Options) Op {
+	return &claimOp{Op: o.Op.WithOptions(opts), ignore: o.ignore}
+}
+
+// conditional makes multiOp refuse to batch the claim, as a lightweight transaction
+func (o *claimOp) conditional() bool {
+	return true
+}
+
+// ownerOp reads the owner of a value
+type ownerOp struct {
+	Op
+	row        *map[string]interface{}
+	ownerField string
+	pointer    interface{}
+}
+
+func (o *ownerOp) Run() error {
+	if err := o.Op.Run(); err != nil {
+		return err
+	}
+	return decodeResult(columnValue(*o.row, o.ownerField), o.pointer)
+}
+
+func (o *ownerOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o *ownerOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *ownerOp) WithOptions(opts Options) Op {
+	ret := *o
+	ret.Op = o.Op.WithOptions(opts)
+	return &ret
+}
+
+// claimAndOp claims a value, runs an op and releases the claim if the op fails
+type claimAndOp struct {
+	claim   *claimOp
+	release Op
+	op      Op
+	atomic  bool
+}
+
+func (o *claimAndOp) Run() error {
+	if err := o.Preflight(); err != nil {
+		return err
+	}
+	// The claim is only released on failure if this run made it, not if the owner held it already
+	claimed := true
+	if err := o.claim.Op.Run(); err != nil {
+		if !o.claim.ignore(err) {
+			return err
+		}
+		claimed = false
+	}
+	run := o.op.Run
+	if o.atomic {
+		run = o.op.RunAtomically
+	}
+	err := run()
+	if err == nil || !claimed {
+		return err
+	}
+	// If the release fails, the value stays claimed by the owner, who can release it or claim it again
+	if releaseErr := o.release.Run(); releaseErr != nil {
+		return ReleaseError{Err: err, ReleaseErr: releaseErr}
+	}
+	return err
+}
+
+// RunAtomically runs the op atomically once the value is claimed. The claim, a lightweight transaction, can't be part
+// of a batch.
+func (o *claimAndOp) RunAtomically() error {
+	ret := *o
+	ret.atomic = true
+	return ret.Run()
+}
+
+func (o *claimAndOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o *claimAndOp) WithOptions(opts Options) Op {
+	return &claimAndOp{
+		claim:   o.claim.WithOptions(opts).(*claimOp),
+		release: o.release.WithOptions(opts),
+		op:      o.op.WithOptions(opts),
+		atomic:  o.atomic,
+	}
+}
+
+func (o *claimAndOp) Preflight() error {
+	if err := o.claim.Preflight(); err != nil {
+		return err
+	}
+	return o.op.Preflight()
+}
+
+// GenerateStatement returns the statement of the claim, which runs first
+func (o *claimAndOp) GenerateStatement() (string, []interface{}) {
+	return o.claim.GenerateStatement()
+}
+
+func (o *claimAndOp) QueryExecutor() QueryExecutor {
+	return o.claim.QueryExecutor()
+}
+
+func (o *claimAndOp) conditional() bool {
+	return true
+}
@@ -0,0 +1,183 @@
+package gocassa
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type uniqueUser struct {
+	Id    string
+	Email string
+	Name  string
+}
+
+func TestUniqueIndex(t *testing.T) {
+	ks := NewMockKeySpace()
+	emails := ks.UniqueIndex("users", "Email", "Id", uniqueUser{})
+
+	if err := emails.Claim("a@example.com", "u1").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := emails.Claim("a@example.com", "u1").Run(); err != nil {
+		t.Fatalf("expected claiming a value again for its owner to succeed, got %v", err)
+	}
+	err := emails.Claim("a@example.com", "u2").Run()
+	var notApplied NotAppliedError
+	if !errors.As(err, &notApplied) || columnValue(notApplied.Current, "Id") != "u1" {
+		t.Fatalf("expected a NotAppliedError with the current owner, got %#v", err)
+	}
+	var owner string
+	if err := emails.Owner("a@example.com", &owner).Run(); err != nil || owner != "u1" {
+		t.Fatalf("expected u1 to own the value, got %q, %v", owner, err)
+	}
+
+	if err := emails.Release("a@example.com", "u2").Run(); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected another owner's release not to be applied, got %v", err)
+	}
+	if err := emails.Transfer("a@example.com", "u2", "u3").Run(); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a transfer from another owner not to be applied, got %v", err)
+	}
+	if err := emails.Transfer("a@example.com", "u1", "u2").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := emails.Owner("a@example.com", &owner).Run(); err != nil || owner != "u2" {
+		t.Fatalf("expected u2 to own the value, got %q, %v", owner, err)
+	}
+	if err := emails.Release("a@example.com", "u2").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := emails.Owner("a@example.com", &owner).Run(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a released value, got %v", err)
+	}
+	if err := emails.Release("a@example.com", "u2").Run(); err != nil {
+		t.Fatalf("expected releasing a value which isn't claimed to succeed, got %v", err)
+	}
+}
+
+func TestUniqueIndexClaimAnd(t *testing.T) {
+	ks := NewMockKeySpace()
+	users := ks.MapTable("users", "Id", uniqueUser{})
+	emails := ks.UniqueIndex("users", "Email", "Id", uniqueUser{})
+
+	user := uniqueUser{Id: "u1", Email: "a@example.com", Name: "A"}
+	if err := emails.ClaimAnd(user.Email, user.Id, users.Set(user)).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var read uniqueUser
+	if err := users.Read("u1", &read).Run(); err != nil || read != user {
+		t.Fatalf("expected the user to be written, got %+v, %v", read, err)
+	}
+
+	other := uniqueUser{Id: "u2", Email: "a@example.com"}
+	if err := emails.ClaimAnd(other.Email, other.Id, users.Set(other)).Run(); !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a duplicate email not to be applied, got %v", err)
+	}
+	if err := users.Read("u2", &read).Run(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the user with a duplicate email not to be written, got %+v, %v", read, err)
+	}
+
+	// The claim is released when the write fails
+	failing := &badOp{errors.New("write failed")}
+	if err := emails.ClaimAnd("b@example.com", "u2", failing).RunAtomically(); err == nil || err.Error() != "write failed" {
+		t.Fatalf("expected the error of the write, got %v", err)
+	}
+	if err := emails.Claim("b@example.com", "u3").Run(); err != nil {
+		t.Fatalf("expected the claim to be released, got %v", err)
+	}
+
+	// A claim the owner held already is kept when the write fails
+	if err := emails.ClaimAnd("b@example.com", "u3", failAfterOp{users.Set(uniqueUser{Id: "u3"})}).Run(); err == nil || err.Error() != "write failed" {
+		t.Fatalf("expected the error of the write, got %v", err)
+	}
+	var owner string
+	if err := emails.Owner("b@example.com", &owner).Run(); err != nil || owner != "u3" {
+		t.Fatalf("expected the value to stay claimed by its owner, got %q, %v", owner, err)
+	}
+
+	// The error of a failed release is returned along with the error of the write
+	err := emails.ClaimAnd("c@example.com", "u1", failAfterOp{emails.Transfer("c@example.com", "u1", "u2")}).Run()
+	var releaseErr ReleaseError
+	if !errors.As(err, &releaseErr) || releaseErr.Err.Error() != "write failed" || !errors.Is(err, ErrNotApplied) {
+		t.Fatalf("expected a ReleaseError with the errors of the write and the release, got %v", err)
+	}
+
+	if _, err := ks.UniqueIndexE("users", "Email", "Missing", uniqueUser{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a missing owner field, got %v", err)
+	}
+}
+
+// failAfterOp runs an op and fails
+type failAfterOp struct {
+	Op
+}
+
+func (o failAfterOp) Run() error {
+	o.Op.Run()
+	return errors.New("write failed")
+}
+
+// lwtQE records the statements of lightweight transactions, and returns rows as Cassandra does for them
+type lwtQE struct {
+	StatementRecordingQE
+	result map[string]interface{}
+}
+
+func (qe lwtQE) QueryWithOptions(opts Options, stmt string, params ...interface{}) ([]map[string]interface{}, error) {
+	*qe.stmts = append(*qe.stmts, stmt)
+	return []map[string]interface{}{qe.result}, nil
+}
+
+func TestUniqueIndexStatements(t *testing.T) {
+	qe := lwtQE{StatementRecordingQE: newStatementRecordingQE(), result: map[string]interface{}{"[applied]": true}}
+	emails := (&connection{q: qe}).KeySpace("some_ks").UniqueIndex("users", "Email", "Id", uniqueUser{})
+
+	expected := []string{
+		"INSERT INTO some_ks.users_unique_Email (email, id) VALUES (?, ?) IF NOT EXISTS",
+		"DELETE FROM some_ks.users_unique_Email WHERE email = ? IF id = ?",
+		"UPDATE some_ks.users_unique_Email SET Id = ? WHERE email = ? IF id = ?",
+	}
+	for i, op := range []Op{
+		emails.Claim("a@example.com", "u1"),
+		emails.Release("a@example.com", "u1"),
+		emails.Transfer("a@example.com", "u1", "u2"),
+	} {
+		if err := op.RunAtomically(); err != nil {
+			t.Fatal(err)
+		}
+		stmt := (*qe.stmts)[i]
+		if i == 0 {
+			// The order of the inserted columns is not fixed
+			stmt = strings.Replace(stmt, "(id, email)", "(email, id)", 1)
+		}
+		if stmt != expected[i] {
+			t.Fatalf("expected %q, got %q", expected[i], stmt)
+		}
+	}
+	if _, values := emails.Transfer("a@example.com", "u1", "u2").GenerateStatement(); !reflect.DeepEqual(values, []interface{}{"u2", "a@example.com", "u1"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	qe.result = map[string]interface{}{"[applied]": false, "email": "a@example.com", "id": "u2"}
+	emails = (&connection{q: qe}).KeySpace("some_ks").UniqueIndex("users", "Email", "Id", uniqueUser{})
+	err := emails.Claim("a@example.com", "u1").Run()
+	var notApplied NotAppliedError
+	if !errors.As(err, &notApplied) || notApplied.Current["id"] != "u2" || !strings.HasSuffix(notApplied.Statement, "IF NOT EXISTS") {
+		t.Fatalf("expected a NotAppliedError with the current row, got %#v", err)
+	}
+	if err := emails.Claim("a@example.com", "u2").Run(); err != nil {
+		t.Fatalf("expected claiming a value again for its owner to succeed, got %v", err)
+	}
+
+	if err := emails.Claim("a@example.com", "u1").Add(emails.Claim("b@example.com", "u1")).RunAtomically(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError batching lightweight transactions, got %v", err)
+	}
+	tbl := (&connection{q: qe}).KeySpace("some_ks").Table("users", uniqueUser{}, Keys{PartitionKeys: []string{"Id"}})
+	if err := tbl.Where(In("Id", "u1", "u2")).Delete().WithOptions(Options{IfExists: true}).Run(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a lightweight transaction on several rows, got %v", err)
+	}
+	if err := tbl.Where(Eq("Id", "u1")).Update(map[string]interface{}{"Name": "B"}).WithOptions(Options{Conditions: []Relation{Eq("Id", "u1")}}).Run(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a ValidationError for a condition on a key, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package gocassa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateRelations checks the relations of a query against the primary key of the table, following the rules
+// Cassandra applies, so that a bad query fails with a precise error before it is sent. Both the gocql backed and the
+// mock tables run it as their Preflight.
+//
+// The partition keys have to be restricted by EQ or IN relations, and the clustering columns restricted in order,
+// with only the last one restricted by a range. IN is only accepted on the last partition key and on the last
+// restricted clustering column. Other columns can only be restricted by reads with AllowFiltering. Updates and deletes
+// of specific columns have to restrict every clustering column by EQ or IN, unless they only touch static columns, in
+// which case they can't restrict any.
+func validateRelations(table string, keys Keys, rs []Relation, opType uint8, allowFiltering bool, m map[string]interface{}) error {
+	if opType == insertOpType {
+		return nil
+	}
+	invalid := func(field, format string, args ...interface{}) error {
+		return ValidationError{Table: table, Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	byColumn := map[string][]Relation{}
+	for _, r := range rs {
+		k := strings.ToLower(r.key)
+		byColumn[k] = append(byColumn[k], r)
+	}
+	for _, column := range byColumn {
+		if len(column) < 2 {
+			continue
+		}
+		for _, r := range column {
+			if r.op == equality || r.op == in {
+				return invalid(r.key, "can not be restricted by more than one relation if it includes an EQ or IN")
+			}
+		}
+	}
+	relationsOf := func(column string) []Relation {
+		return byColumn[strings.ToLower(column)]
+	}
+
+	isRead := opType == readOpType || opType == singleReadOpType
+	filtering := isRead && allowFiltering
+
+	// Partition keys
+	restricted := 0
+	for i, pk := range keys.PartitionKeys {
+		column := relationsOf(pk)
+		if len(column) == 0 {
+			continue
+		}
+		restricted++
+		switch r := column[0]; r.op {
+		case equality:
+		case in:
+			if i != len(keys.PartitionKeys)-1 {
+				return invalid(pk, "only the last partition key can be restricted by IN")
+			}
+		default:
+			return invalid(pk, "only EQ and IN relations are supported on the partition key")
+		}
+	}
+	if restricted < len(keys.PartitionKeys) {
+		switch {
+		case !isRead:
+			return invalid(missing(keys.PartitionKeys, relationsOf), "missing mandatory partition key")
+		case restricted > 0 && !filtering:
+			return invalid(missing(keys.PartitionKeys, relationsOf), "the partition key has to be fully restricted")
+		case restricted == 0 && len(rs) > 0 && !filtering:
+			return invalid("", "restricting columns without the partition key requires AllowFiltering")
+		}
+	}
+
+	// Deleted columns
+	deleteColumns := opType == deleteOpType && len(m) > 0
+	staticDelete := deleteColumns && staticOnly(keys, m)
+	if deleteColumns {
+		for column := range m {
+			if isKey(column, keys.PartitionKeys, keys.ClusteringColumns) {
+				return invalid(column, "primary key columns can not be deleted, delete the row instead")
+			}
+		}
+	}
+
+	// Clustering columns
+	staticUpdate := opType == updateOpType && staticOnly(keys, m)
+	var open string // the first clustering column not restricted by EQ, after which nothing else can be restricted
+	for i, cc := range keys.ClusteringColumns {
+		column := relationsOf(cc)
+		if len(column) == 0 {
+			if open == "" {
+				open = cc
+			}
+			if (opType == updateOpType && !staticUpdate) || (deleteColumns && !staticDelete) {
+				return invalid(cc, "missing mandatory clustering column")
+			}
+			continue
+		}
+		if staticDelete {
+			return invalid(cc, "can not be restricted when only static columns are deleted")
+		}
+		if open != "" && !filtering {
+			return invalid(cc, "can not be restricted because the preceding clustering column %s is not restricted by EQ", open)
+		}
+		switch r := column[0]; r.op {
+		case equality:
+		case in:
+			if i != len(keys.ClusteringColumns)-1 && len(relationsOf(keys.ClusteringColumns[i+1])) > 0 {
+				return invalid(cc, "only the last restricted clustering column can be restricted by IN")
+			}
+			if open == "" {
+				open = cc
+			}
+		default:
+			if opType == updateOpType {
+				return invalid(cc, "range relations are not supported when updating")
+			}
+			if deleteColumns {
+				return invalid(cc, "range relations are not supported when deleting specific columns")
+			}
+			if open == "" {
+				open = cc
+			}
+		}
+	}
+
+	// Other columns
+	for _, r := range rs {
+		if isKey(r.key, keys.PartitionKeys, keys.ClusteringColumns) {
+			continue
+		}
+		if !isRead {
+			return invalid(r.key, "non primary key columns can not be restricted when writing")
+		}
+		if !filtering {
+			return invalid(r.key, "restricting a non primary key column requires AllowFiltering")
+		}
+	}
+	return nil
+}
+
+// validateProjection checks the columns and relations of reads of distinct partition keys, which can only select the
+// partition keys and static columns, and only restrict the partition keys
+func validateProjection(table string, keys Keys, rs []Relation, p *projection) error {
+	if p == nil || !p.distinct {
+		return nil
+	}
+	for _, c := range p.columns {
+		if !isKey(c, keys.PartitionKeys, keys.StaticColumns) {
+			return ValidationError{Table: table, Field: c, Reason: "only partition keys and static columns can be selected as DISTINCT"}
+		}
+	}
+	for _, r := range rs {
+		if !isKey(r.key, keys.PartitionKeys) {
+			return ValidationError{Table: table, Field: r.key, Reason: "only the partition keys can be restricted when selecting DISTINCT partitions"}
+		}
+	}
+	return nil
+}
+
+// validateReadOptions checks the options of a read which depend on the table keys
+func validateReadOptions(table string, keys Keys, opts Options) error {
+	if opts.PerPartitionLimit < 0 {
+		return ValidationError{Table: table, Reason: "the per partition limit can not be negative"}
+	}
+	if len(opts.GroupBy) == 0 {
+		return nil
+	}
+	primaryKey := append(append([]string{}, keys.PartitionKeys...), keys.ClusteringColumns...)
+	if len(opts.GroupBy) < len(keys.PartitionKeys) || len(opts.GroupBy) > len(primaryKey) {
+		return ValidationError{Table: table, Reason: "GROUP BY has to include all the partition keys, followed by some of the clustering columns"}
+	}
+	for i, column := range opts.GroupBy {
+		if !strings.EqualFold(column, primaryKey[i]) {
+			return ValidationError{Table: table, Field: column, Reason: fmt.Sprintf("GROUP BY has to follow the order of the primary key, expected %s", primaryKey[i])}
+		}
+	}
+	return nil
+}
+
+// validateConditions checks that the writes made lightweight transactions by the options select a single row, and
+// that their conditions are on regular columns
+func validateConditions(table string, keys Keys, rs []Relation, opType uint8, opts Options) error {
+	if !opts.conditional() || opType == readOpType || opType == singleReadOpType {
+		return nil
+	}
+	switch {
+	case opts.IfNotExists && (opts.IfExists || len(opts.Conditions) > 0):
+		return ValidationError{Table: table, Reason: "a write can not be conditional on the row both existing and not existing"}
+	case opts.IfExists && len(opts.Conditions) > 0:
+		return ValidationError{Table: table, Reason: "a write can not have both IF EXISTS and conditions, which only hold on existing rows"}
+	case opts.IfNotExists && opType == deleteOpType:
+		return ValidationError{Table: table, Reason: "a delete can not be conditional on the row not existing"}
+	case !opts.IfNotExists && opType == insertOpType:
+		return ValidationError{Table: table, Reason: "an insert can only be conditional on the row not existing"}
+	}
+	for _, c := range opts.Conditions {
+		if isKey(c.key, keys.PartitionKeys, keys.ClusteringColumns) {
+			return ValidationError{Table: table, Field: c.key, Reason: "conditions can not be on primary key columns"}
+		}
+	}
+	if opType == insertOpType {
+		return nil
+	}
+	for _, k := range append(append([]string{}, keys.PartitionKeys...), keys.ClusteringColumns...) {
+		selected := false
+		for _, r := range rs {
+			if strings.EqualFold(r.key, k) && (r.op == equality || r.op == in && len(r.terms) == 1) {
+				selected = true
+			}
+		}
+		if !selected {
+			return ValidationError{Table: table, Field: k, Reason: "lightweight transactions have to select a single row, with an equality relation on every primary key column"}
+		}
+	}
+	return nil
+}
+
+// missing returns the first of the keys without relations
+func missing(keys []string, relationsOf func(string) []Relation) string {
+	for _, k := range keys {
+		if len(relationsOf(k)) == 0 {
+			return k
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package gocassa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateRelations(t *testing.T) {
+	keys := Keys{
+		PartitionKeys:     []string{"Pk1", "Pk2"},
+		ClusteringColumns: []string{"Ck1", "Ck2"},
+	}
+	for _, tc := range []struct {
+		name           string
+		opType         uint8
+		rs             []Relation
+		allowFiltering bool
+		field          string // the field of the expected error, "-" if none is expected
+	}{
+		{"full key", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1), Eq("Ck2", 1)}, false, "-"},
+		{"partition", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1)}, false, "-"},
+		{"no relations", readOpType, nil, false, "-"},
+		{"case insensitive", readOpType, []Relation{Eq("pk1", 1), Eq("PK2", 1)}, false, "-"},
+		{"IN on last partition key", readOpType, []Relation{Eq("Pk1", 1), In("Pk2", 1, 2)}, false, "-"},
+		{"IN on first partition key", readOpType, []Relation{In("Pk1", 1, 2), Eq("Pk2", 1)}, false, "Pk1"},
+		{"missing partition key", readOpType, []Relation{Eq("Pk1", 1)}, false, "Pk2"},
+		{"range on partition key", readOpType, []Relation{Eq("Pk1", 1), GT("Pk2", 1)}, false, "Pk2"},
+		{"range on clustering column", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), GT("Ck1", 1), LT("Ck1", 5)}, false, "-"},
+		{"range on last clustering column", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1), GTE("Ck2", 1)}, false, "-"},
+		{"clustering column skipped", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck2", 1)}, false, "Ck2"},
+		{"clustering column after range", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), GT("Ck1", 1), Eq("Ck2", 1)}, false, "Ck2"},
+		{"IN then EQ", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), In("Ck1", 1, 2), Eq("Ck2", 1)}, false, "Ck1"},
+		{"EQ twice", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk1", 2), Eq("Pk2", 1)}, false, "Pk1"},
+		{"non key column", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Name", "x")}, false, "Name"},
+		{"non key column filtering", readOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Name", "x")}, true, "-"},
+		{"only clustering", readOpType, []Relation{Eq("Ck1", 1)}, false, ""},
+		{"only clustering filtering", readOpType, []Relation{Eq("Ck1", 1)}, true, "-"},
+		{"update", updateOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1), In("Ck2", 1, 2)}, false, "-"},
+		{"update missing clustering", updateOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1)}, false, "Ck2"},
+		{"update range", updateOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1), GT("Ck2", 1)}, false, "Ck2"},
+		{"update non key", updateOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1), Eq("Ck2", 1), Eq("Name", "x")}, true, "Name"},
+		{"delete partition", deleteOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1)}, false, "-"},
+		{"delete range", deleteOpType, []Relation{Eq("Pk1", 1), Eq("Pk2", 1), GT("Ck1", 1)}, false, "-"},
+		{"delete missing partition key", deleteOpType, []Relation{Eq("Pk1", 1)}, true, "Pk2"},
+	} {
+		m := map[string]interface{}{"Name": "x"}
+		if tc.opType == deleteOpType {
+			m = nil
+		}
+		err := validateRelations("users", keys, tc.rs, tc.opType, tc.allowFiltering, m)
+		if tc.field == "-" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: expected a ValidationError, got %v", tc.name, err)
+		} else if verr.Field != tc.field {
+			t.Errorf("%s: expected an error on %q, got %v", tc.name, tc.field, err)
+		}
+	}
+}
+
+func TestValidateDeleteColumns(t *testing.T) {
+	keys := Keys{
+		PartitionKeys:     []string{"Pk"},
+		ClusteringColumns: []string{"Ck1", "Ck2"},
+		StaticColumns:     []string{"Static"},
+	}
+	for _, tc := range []struct {
+		name    string
+		columns []string
+		rs      []Relation
+		field   string // the field of the expected error, "-" if none is expected
+	}{
+		{"full key", []string{"Name"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1), In("Ck2", 1, 2)}, "-"},
+		{"missing clustering", []string{"Name"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1)}, "Ck2"},
+		{"range", []string{"Name"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1), GT("Ck2", 1)}, "Ck2"},
+		{"key column", []string{"Ck2"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1), Eq("Ck2", 1)}, "Ck2"},
+		{"static", []string{"Static"}, []Relation{Eq("Pk", 1)}, "-"},
+		{"static restricting clustering", []string{"Static"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1)}, "Ck1"},
+		{"static and regular", []string{"Static", "Name"}, []Relation{Eq("Pk", 1), Eq("Ck1", 1), Eq("Ck2", 1)}, "-"},
+	} {
+		err := validateRelations("users", keys, tc.rs, deleteOpType, false, columnSet(tc.columns))
+		if tc.field == "-" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: expected a ValidationError, got %v", tc.name, err)
+		} else if verr.Field != tc.field {
+			t.Errorf("%s: expected an error on %q, got %v", tc.name, tc.field, err)
+		}
+	}
+}
+
+func TestPreflightOnBothBackends(t *testing.T) {
+	for name, ks := range map[string]KeySpace{
+		"mock":  NewMockKeySpace(),
+		"gocql": (&connection{q: OptionCheckingQE{opts: &Options{}}}).KeySpace("some_ks"),
+	} {
+		tbl := ks.Table("users", user{}, Keys{
+			PartitionKeys:     []string{"Pk1", "Pk2"},
+			ClusteringColumns: []string{"Ck1", "Ck2"},
+		})
+		var users []user
+		op := tbl.Where(Eq("Pk1", 1)).Read(&users)
+		if err := op.Preflight(); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a ValidationError from Preflight, got %v", name, err)
+		}
+		if err := op.Run(); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a ValidationError from Run, got %v", name, err)
+		}
+
+		op = tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), Eq("Name", "x")).Read(&users)
+		if err := op.Run(); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a ValidationError without AllowFiltering, got %v", name, err)
+		}
+		if err := op.WithOptions(Options{AllowFiltering: true}).Run(); err != nil {
+			t.Errorf("%s: unexpected error with AllowFiltering: %v", name, err)
+		}
+
+		op = tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1)).Update(map[string]interface{}{"Name": "x"})
+		if err := op.Add(op).Run(); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a ValidationError for a batch, got %v", name, err)
+		}
+	}
+}